@@ -0,0 +1,301 @@
+package bouncer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/server"
+)
+
+var (
+	ErrorNoSuchUser = errors.New("bouncer: No such user")
+
+	ErrorUserExists = errors.New("bouncer: User already exists")
+)
+
+const DefaultBuffer = 1000
+
+const serverTimeFormat = "2006-01-02T15:04:05.000Z"
+
+type Config struct {
+	Buffer     int
+	ServerTime bool
+
+	Now func() time.Time
+}
+
+type Bouncer struct {
+	config  Config
+	manager *tightbeam.Manager
+
+	lock     sync.Mutex
+	users    map[string]*user
+	sessions map[*server.Session]*user
+}
+
+type user struct {
+	name string
+
+	lock     sync.Mutex
+	state    *state
+	buffer   []*tightbeam.Message
+	sessions map[*server.Session]struct{}
+}
+
+func New(config Config) *Bouncer {
+	if config.Buffer == 0 {
+		config.Buffer = DefaultBuffer
+	}
+
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	b := &Bouncer{
+		config:   config,
+		users:    map[string]*user{},
+		sessions: map[*server.Session]*user{},
+	}
+
+	b.manager = tightbeam.NewManager(b)
+
+	return b
+}
+
+func (b *Bouncer) Manager() *tightbeam.Manager {
+	return b.manager
+}
+
+func (b *Bouncer) AddUser(name string, network tightbeam.NetworkConfig) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.users[name]; ok {
+		return ErrorUserExists
+	}
+
+	network.Name = name
+	if err := b.manager.Add(network); err != nil {
+		return err
+	}
+
+	b.users[name] = &user{
+		name:     name,
+		state:    newState(),
+		sessions: map[*server.Session]struct{}{},
+	}
+
+	return nil
+}
+
+func (b *Bouncer) RemoveUser(name string) error {
+	b.lock.Lock()
+	u, ok := b.users[name]
+	delete(b.users, name)
+	b.lock.Unlock()
+
+	if !ok {
+		return ErrorNoSuchUser
+	}
+
+	b.manager.Remove(name)
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	for sess := range u.sessions {
+		sess.Close("User removed")
+	}
+
+	return nil
+}
+
+func (b *Bouncer) Start(ctx context.Context) {
+	b.manager.StartAll(ctx)
+}
+
+func (b *Bouncer) Stop() {
+	b.manager.StopAll()
+}
+
+func (b *Bouncer) user(name string) *user {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.users[name]
+}
+
+func (b *Bouncer) Attach(sess *server.Session) {
+	account := sess.Account()
+	if account == "" {
+		sess.Close("Authentication required")
+		return
+	}
+
+	u := b.user(account)
+	if u == nil {
+		sess.Close("No such user")
+		return
+	}
+
+	b.lock.Lock()
+	b.sessions[sess] = u
+	b.lock.Unlock()
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	nick := u.state.nick
+	if nick == "" {
+		nick = sess.Nick()
+	}
+	sess.SetNick(nick)
+
+	for _, m := range u.state.replay(nick) {
+		sess.Send(m)
+	}
+
+	for _, m := range u.buffer {
+		sess.Send(m)
+	}
+	u.buffer = nil
+
+	u.sessions[sess] = struct{}{}
+}
+
+func (b *Bouncer) Detach(sess *server.Session) {
+	b.lock.Lock()
+	u := b.sessions[sess]
+	delete(b.sessions, sess)
+	b.lock.Unlock()
+
+	if u == nil {
+		return
+	}
+
+	u.lock.Lock()
+	delete(u.sessions, sess)
+	u.lock.Unlock()
+}
+
+func (b *Bouncer) Handle(sess *server.Session, m *tightbeam.Message) {
+	b.lock.Lock()
+	u := b.sessions[sess]
+	b.lock.Unlock()
+
+	if u == nil {
+		return
+	}
+
+	m = m.Copy()
+	m.Prefix = nil
+
+	if m.Command == "JOIN" {
+		u.lock.Lock()
+		u.state.requestJoin(m)
+		u.lock.Unlock()
+	}
+
+	if b.manager.Send(u.name, m) != nil {
+		return
+	}
+
+	switch m.Command {
+	case "PRIVMSG", "NOTICE", "TAGMSG":
+	default:
+		return
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	echo := m.Copy()
+	echo.Prefix = u.state.prefix()
+
+	for other := range u.sessions {
+		if other != sess {
+			other.Send(echo)
+		}
+	}
+}
+
+func (b *Bouncer) HandleNetwork(network string, c *tightbeam.Client, m *tightbeam.Message) {
+	u := b.user(network)
+	if u == nil {
+		return
+	}
+
+	m = m.Copy()
+
+	u.lock.Lock()
+	u.state.update(m, b.config.Now())
+
+	var rejoin []*channel
+	if m.Command == tightbeam.RPL_ENDOFMOTD || m.Command == tightbeam.ERR_NOMOTD {
+		rejoin, u.state.rejoin = u.state.rejoin, nil
+	}
+
+	if m.Command == "NICK" && u.state.isSelf(&tightbeam.Prefix{Name: param(m, 0)}) {
+		for sess := range u.sessions {
+			sess.SetNick(u.state.nick)
+		}
+	}
+
+	switch {
+	case !fanout(m):
+	case len(u.sessions) > 0:
+		for sess := range u.sessions {
+			sess.Send(m)
+		}
+	case buffered(m):
+		b.buffer(u, m)
+	}
+	u.lock.Unlock()
+
+	if len(rejoin) > 0 {
+		if join, err := joinChannels(rejoin); err == nil {
+			c.Send(join)
+		}
+	}
+}
+
+func (b *Bouncer) buffer(u *user, m *tightbeam.Message) {
+	if b.config.Buffer < 0 {
+		return
+	}
+
+	if b.config.ServerTime {
+		if _, ok := m.Tags.GetTag("time"); !ok {
+			if m.Tags == nil {
+				m.Tags = tightbeam.Tags{}
+			}
+			m.Tags["time"] = tightbeam.TagVal(b.config.Now().UTC().Format(serverTimeFormat))
+		}
+	}
+
+	u.buffer = append(u.buffer, m)
+	if len(u.buffer) > b.config.Buffer {
+		u.buffer = append(u.buffer[:0], u.buffer[len(u.buffer)-b.config.Buffer:]...)
+	}
+}
+
+func fanout(m *tightbeam.Message) bool {
+	switch m.Command {
+	case "PING", "PONG", "CAP", "AUTHENTICATE":
+		return false
+	}
+
+	return true
+}
+
+func buffered(m *tightbeam.Message) bool {
+	switch m.Command {
+	case "PRIVMSG", "NOTICE", "TAGMSG", "INVITE":
+		return true
+	}
+
+	return false
+}
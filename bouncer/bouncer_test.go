@@ -0,0 +1,238 @@
+package bouncer_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	s "strings"
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/bouncer"
+	"github.com/SamStrongTalks/tightbeam/server"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+type downstream struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *tightbeam.Reader
+	writer *tightbeam.Writer
+}
+
+var errBadPassword = errors.New("bad password")
+
+func newServer(b *bouncer.Bouncer) *server.Server {
+	return server.New(server.Config{
+		Sessions: b,
+		Auth: server.AuthenticatorFunc(func(nick, user, pass string) (string, error) {
+			account, password, _ := s.Cut(pass, ":")
+			if password != "secret" {
+				return "", errBadPassword
+			}
+			return account, nil
+		}),
+	})
+}
+
+func connect(t *testing.T, srv *server.Server, user, pass string) *downstream {
+	t.Helper()
+
+	client, conn := net.Pipe()
+	go srv.ServeConn(conn)
+	t.Cleanup(func() { client.Close() })
+
+	d := &downstream{t: t, conn: client, reader: tightbeam.NewReader(client), writer: tightbeam.NewWriter(client)}
+	if pass != "" {
+		d.send("PASS " + pass)
+	}
+	d.send("NICK someone")
+	d.send("USER " + user + " 0 * :Real Name")
+
+	return d
+}
+
+func (d *downstream) send(line string) {
+	d.t.Helper()
+
+	d.conn.SetWriteDeadline(time.Now().Add(tightbeamtest.DefaultTimeout))
+	if err := d.writer.WriteLine(line); err != nil {
+		d.t.Fatalf("writing %q: %v", line, err)
+	}
+}
+
+func (d *downstream) expect(pattern string) *tightbeam.Message {
+	d.t.Helper()
+
+	d.conn.SetReadDeadline(time.Now().Add(tightbeamtest.DefaultTimeout))
+
+	line, err := d.reader.ReadLine()
+	if err != nil {
+		d.t.Fatalf("reading: %v", err)
+	}
+
+	m, err := tightbeam.ParseMessage(line)
+	if err != nil {
+		d.t.Fatalf("parsing %q: %v", line, err)
+	}
+
+	p, err := tightbeamtest.ParsePattern(pattern)
+	if err != nil {
+		d.t.Fatalf("bad pattern %q: %v", pattern, err)
+	}
+
+	if diff := p.Diff(m); diff != "" {
+		d.t.Fatalf("unexpected message\n  want: %s\n   got: %s\n%s", p, m, diff)
+	}
+
+	return m
+}
+
+func sync(up *tightbeamtest.Server) {
+	up.Send("PING :sync")
+	up.Expect("PONG sync")
+}
+
+func TestBouncer(t *testing.T) {
+	upstreams := make(chan *tightbeamtest.Server, 2)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	b := bouncer.New(bouncer.Config{
+		Buffer:     2,
+		ServerTime: true,
+		Now:        func() time.Time { return now },
+	})
+
+	err := b.AddUser("alice", tightbeam.NetworkConfig{
+		Connect: func(ctx context.Context) (tightbeam.Transport, error) {
+			up := tightbeamtest.NewServer(t)
+			upstreams <- up
+			return tightbeam.NewStreamTransport(up.Conn()), nil
+		},
+		Client:       tightbeam.ClientConfig{Nick: "alice"},
+		ReconnectMin: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.AddUser("alice", tightbeam.NetworkConfig{}); err != bouncer.ErrorUserExists {
+		t.Fatalf("duplicate AddUser: got %v", err)
+	}
+
+	b.Start(context.Background())
+	defer b.Stop()
+
+	srv := newServer(b)
+	defer srv.Close()
+
+	up := <-upstreams
+	up.Register("alice")
+	up.Send(":alice!a@host JOIN #chan")
+	up.Numeric(tightbeam.RPL_TOPIC, "alice", "#chan", "the topic")
+	up.Numeric(tightbeam.RPL_NAMREPLY, "alice", "=", "#chan", "alice @bob")
+	up.Numeric(tightbeam.RPL_ENDOFNAMES, "alice", "#chan", "End of /NAMES list")
+	up.Send(":carol!c@host JOIN #chan")
+	up.Send(":bob!b@host MODE #chan +v carol")
+	for _, text := range []string{"one", "two", "three"} {
+		up.Send(":bob!b@host PRIVMSG #chan :" + text)
+	}
+	sync(up)
+
+	d1 := connect(t, srv, "mallory", "alice:secret")
+	d1.expect(":irc.test 001 alice *")
+	d1.expect(":irc.test 005 alice CASEMAPPING=rfc1459 *")
+	d1.expect(":irc.test 422 alice *")
+	d1.expect(":alice!a@host JOIN #chan")
+	d1.expect(":irc.test 332 alice #chan :the topic")
+	d1.expect(":irc.test 353 alice = #chan :+carol @bob alice")
+	d1.expect(":irc.test 366 alice #chan *")
+	d1.expect("@time=2026-01-02T03:04:05.000Z :bob!b@host PRIVMSG #chan two")
+	d1.expect("@time=2026-01-02T03:04:05.000Z :bob!b@host PRIVMSG #chan three")
+
+	up.Send(":bob!b@host PRIVMSG #chan :live")
+	d1.expect(":bob!b@host PRIVMSG #chan live")
+
+	d2 := connect(t, srv, "alice", "alice:secret")
+	for _, pattern := range []string{"001 **", "005 **", "422 **", "JOIN **", "332 **", "353 **", "366 **"} {
+		d2.expect(pattern)
+	}
+
+	d1.send("PRIVMSG #chan :reply")
+	up.Expect("PRIVMSG #chan reply")
+	d2.expect(":alice!a@host PRIVMSG #chan reply")
+
+	d1.send("JOIN #secret,#open,#locked key1,,key2")
+	up.Expect("JOIN #secret,#open,#locked key1,,key2")
+	for _, name := range []string{"#secret", "#open", "#locked"} {
+		up.Send(":alice!a@host JOIN " + name)
+		d1.expect(":alice!a@host JOIN " + name)
+		d2.expect(":alice!a@host JOIN " + name)
+	}
+
+	up.Send(":bob!b@host MODE #chan +k chankey")
+	up.Send(":bob!b@host MODE #locked -k key2")
+	up.Send(":bob!b@host MODE #open +k *")
+	for _, d := range []*downstream{d1, d2} {
+		d.expect("MODE #chan +k chankey")
+		d.expect("MODE #locked -k key2")
+		d.expect("MODE #open +k *")
+	}
+
+	up.Send(":alice!a@host NICK alicia")
+	d1.expect(":alice!a@host NICK alicia")
+	d2.expect(":alice!a@host NICK alicia")
+
+	d1.send("QUIT")
+	d1.expect("ERROR *")
+
+	up.Close()
+	up = <-upstreams
+	up.Register("alice")
+	up.Expect("JOIN #chan,#secret,#locked,#open chankey,key1")
+
+	up.Send(":alice!a@host JOIN #secret")
+	sync(up)
+
+	up.Close()
+	up = <-upstreams
+	up.Register("alice")
+	up.Expect("JOIN #secret key1")
+}
+
+func TestBouncerAuth(t *testing.T) {
+	b := bouncer.New(bouncer.Config{})
+
+	for _, name := range []string{"alice", "a-very-long-account"} {
+		err := b.AddUser(name, tightbeam.NetworkConfig{
+			Connect: func(ctx context.Context) (tightbeam.Transport, error) {
+				return nil, errBadPassword
+			},
+			Client: tightbeam.ClientConfig{Nick: "alice"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	open := server.New(server.Config{Sessions: b})
+	defer open.Close()
+
+	anonymous := connect(t, open, "alice", "")
+	anonymous.expect("ERROR :Closing Link: Authentication required")
+
+	srv := newServer(b)
+	defer srv.Close()
+
+	wrong := connect(t, srv, "alice", "alice:wrong")
+	wrong.expect("464 **")
+	wrong.expect("ERROR *")
+
+	unknown := connect(t, srv, "alice", "mallory:secret")
+	unknown.expect("ERROR :Closing Link: No such user")
+
+	long := connect(t, srv, "a-very-long-account", "a-very-long-account:secret")
+	long.send("PING :sync")
+	long.expect("PONG * sync")
+}
@@ -0,0 +1,434 @@
+package bouncer
+
+import (
+	"sort"
+	"strconv"
+	s "strings"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+const namesLineLen = 400
+
+type channel struct {
+	name      string
+	status    string
+	topic     string
+	topicWho  string
+	topicTime string
+	key       string
+	members   map[string]*member
+	complete  bool
+}
+
+type member struct {
+	nick     string
+	prefixes string
+}
+
+type state struct {
+	nick     string
+	self     *tightbeam.Prefix
+	server   *tightbeam.Prefix
+	isupport tightbeam.ISupport
+
+	burst    []*tightbeam.Message
+	motd     []*tightbeam.Message
+	channels map[string]*channel
+	keys     map[string]string
+	rejoin   []*channel
+}
+
+func newState() *state {
+	return &state{
+		isupport: tightbeam.ISupport{},
+		channels: map[string]*channel{},
+		keys:     map[string]string{},
+	}
+}
+
+func (st *state) fold(name string) string {
+	return st.isupport.CaseMapping().Fold(name)
+}
+
+func (st *state) isSelf(p *tightbeam.Prefix) bool {
+	return p != nil && st.isupport.CaseMapping().Equal(p.Name, st.nick)
+}
+
+func (st *state) prefix() *tightbeam.Prefix {
+	if st.self != nil {
+		p := st.self.Copy()
+		p.Name = st.nick
+		return p
+	}
+
+	return &tightbeam.Prefix{Name: st.nick}
+}
+
+func (st *state) update(m *tightbeam.Message, now time.Time) {
+	switch m.Command {
+	case tightbeam.RPL_WELCOME:
+		st.rejoin = st.rejoin[:0]
+		for _, ch := range st.channels {
+			st.rejoin = append(st.rejoin, ch)
+		}
+		sort.Slice(st.rejoin, func(a, b int) bool {
+			if (st.rejoin[a].key == "") != (st.rejoin[b].key == "") {
+				return st.rejoin[a].key != ""
+			}
+			return st.rejoin[a].name < st.rejoin[b].name
+		})
+
+		st.keys = map[string]string{}
+		for _, ch := range st.rejoin {
+			if ch.key != "" {
+				st.keys[st.fold(ch.name)] = ch.key
+			}
+		}
+
+		st.nick = param(m, 0)
+		st.server = m.Prefix
+		st.isupport = tightbeam.ISupport{}
+		st.burst = []*tightbeam.Message{m}
+		st.motd = nil
+		st.channels = map[string]*channel{}
+	case tightbeam.RPL_YOURHOST, tightbeam.RPL_CREATED, tightbeam.RPL_MYINFO:
+		st.burst = append(st.burst, m)
+	case tightbeam.RPL_ISUPPORT:
+		st.isupport.Update(m)
+		st.burst = append(st.burst, m)
+	case tightbeam.RPL_MOTDSTART:
+		st.motd = []*tightbeam.Message{m}
+	case tightbeam.RPL_MOTD:
+		st.motd = append(st.motd, m)
+	case tightbeam.RPL_ENDOFMOTD:
+		st.motd = append(st.motd, m)
+	case tightbeam.ERR_NOMOTD:
+		st.motd = []*tightbeam.Message{m}
+	case "NICK":
+		st.nickChange(m)
+	case "JOIN":
+		st.join(m)
+	case "PART":
+		if m.Prefix != nil && len(m.Params) > 0 {
+			st.removeMember(m.Params[0], m.Prefix.Name)
+		}
+	case "KICK":
+		if len(m.Params) > 1 {
+			st.removeMember(m.Params[0], m.Params[1])
+		}
+	case "QUIT":
+		if m.Prefix != nil {
+			for _, ch := range st.channels {
+				delete(ch.members, st.fold(m.Prefix.Name))
+			}
+		}
+	case "MODE":
+		st.mode(m)
+	case "TOPIC":
+		if ch := st.channel(param(m, 0)); ch != nil && m.Prefix != nil && len(m.Params) > 1 {
+			ch.topic = m.Params[1]
+			ch.topicWho = m.Prefix.String()
+			ch.topicTime = strconv.FormatInt(now.Unix(), 10)
+		}
+	case tightbeam.RPL_TOPIC:
+		if ch := st.channel(param(m, 1)); ch != nil {
+			ch.topic = param(m, 2)
+		}
+	case tightbeam.RPL_TOPICWHOTIME:
+		if ch := st.channel(param(m, 1)); ch != nil {
+			ch.topicWho = param(m, 2)
+			ch.topicTime = param(m, 3)
+		}
+	case tightbeam.RPL_NAMREPLY:
+		st.names(m)
+	case tightbeam.RPL_ENDOFNAMES:
+		if ch := st.channel(param(m, 1)); ch != nil {
+			ch.complete = true
+		}
+	}
+}
+
+func (st *state) channel(name string) *channel {
+	if name == "" {
+		return nil
+	}
+
+	return st.channels[st.fold(name)]
+}
+
+func (st *state) nickChange(m *tightbeam.Message) {
+	if m.Prefix == nil || len(m.Params) < 1 {
+		return
+	}
+
+	old, nick := st.fold(m.Prefix.Name), m.Params[0]
+
+	for _, ch := range st.channels {
+		if mem, ok := ch.members[old]; ok {
+			delete(ch.members, old)
+			mem.nick = nick
+			ch.members[st.fold(nick)] = mem
+		}
+	}
+
+	if st.isSelf(m.Prefix) {
+		st.nick = nick
+	}
+}
+
+func (st *state) join(m *tightbeam.Message) {
+	if m.Prefix == nil || len(m.Params) < 1 {
+		return
+	}
+
+	ch := st.channel(m.Params[0])
+
+	if st.isSelf(m.Prefix) {
+		st.self = m.Prefix.Copy()
+
+		if ch == nil {
+			ch = &channel{name: m.Params[0], status: "=", members: map[string]*member{}, complete: true}
+			st.channels[st.fold(ch.name)] = ch
+		}
+
+		if key, ok := st.keys[st.fold(ch.name)]; ok {
+			ch.key = key
+			delete(st.keys, st.fold(ch.name))
+		}
+	}
+
+	if ch != nil {
+		ch.members[st.fold(m.Prefix.Name)] = &member{nick: m.Prefix.Name}
+	}
+}
+
+func (st *state) requestJoin(m *tightbeam.Message) {
+	if len(m.Params) < 2 {
+		return
+	}
+
+	keys := s.Split(m.Params[1], ",")
+	for n, name := range s.Split(m.Params[0], ",") {
+		if n < len(keys) && keys[n] != "" {
+			st.keys[st.fold(name)] = keys[n]
+		}
+	}
+}
+
+func (st *state) removeMember(channel, nick string) {
+	ch := st.channel(channel)
+	if ch == nil {
+		return
+	}
+
+	if st.isupport.CaseMapping().Equal(nick, st.nick) {
+		delete(st.channels, st.fold(channel))
+		return
+	}
+
+	delete(ch.members, st.fold(nick))
+}
+
+func (st *state) mode(m *tightbeam.Message) {
+	if len(m.Params) < 2 {
+		return
+	}
+
+	ch := st.channel(m.Params[0])
+	if ch == nil {
+		return
+	}
+
+	modes, symbols := st.isupport.PrefixModes()
+
+	for _, change := range st.isupport.ParseModes(true, m.Params[1], m.Params[2:]) {
+		if change.Mode == 'k' {
+			switch {
+			case !change.Add:
+				ch.key = ""
+			case change.Param != "" && change.Param != "*":
+				ch.key = change.Param
+			}
+			continue
+		}
+
+		n := s.IndexByte(modes, change.Mode)
+		if n < 0 {
+			continue
+		}
+
+		mem, ok := ch.members[st.fold(change.Param)]
+		if !ok {
+			continue
+		}
+
+		symbol := symbols[n]
+		if change.Add {
+			mem.prefixes = sortPrefixes(mem.prefixes+string(symbol), symbols)
+		} else {
+			mem.prefixes = s.ReplaceAll(mem.prefixes, string(symbol), "")
+		}
+	}
+}
+
+func (st *state) names(m *tightbeam.Message) {
+	if len(m.Params) < 4 {
+		return
+	}
+
+	ch := st.channel(m.Params[2])
+	if ch == nil {
+		return
+	}
+
+	if ch.complete {
+		ch.members = map[string]*member{}
+		ch.complete = false
+	}
+
+	ch.status = m.Params[1]
+
+	_, symbols := st.isupport.PrefixModes()
+
+	for _, name := range s.Fields(m.Params[3]) {
+		nick := s.TrimLeft(name, symbols)
+		if nick == "" {
+			continue
+		}
+
+		if n := s.IndexByte(nick, '!'); n >= 0 {
+			nick = nick[:n]
+		}
+
+		ch.members[st.fold(nick)] = &member{
+			nick:     nick,
+			prefixes: sortPrefixes(name[:len(name)-len(s.TrimLeft(name, symbols))], symbols),
+		}
+	}
+}
+
+func (st *state) replay(nick string) []*tightbeam.Message {
+	var ret []*tightbeam.Message
+
+	for _, m := range st.burst {
+		ret = append(ret, retarget(m, nick))
+	}
+
+	for _, m := range st.motd {
+		ret = append(ret, retarget(m, nick))
+	}
+
+	names := make([]string, 0, len(st.channels))
+	for key := range st.channels {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	for _, key := range names {
+		ret = append(ret, st.replayChannel(st.channels[key], nick)...)
+	}
+
+	return ret
+}
+
+func (st *state) replayChannel(ch *channel, nick string) []*tightbeam.Message {
+	self := st.prefix()
+	self.Name = nick
+
+	ret := []*tightbeam.Message{
+		{Prefix: self, Command: "JOIN", Params: []string{ch.name}},
+	}
+
+	if ch.topic != "" {
+		ret = append(ret, st.numeric(tightbeam.RPL_TOPIC, nick, ch.name, ch.topic))
+
+		if ch.topicWho != "" {
+			ret = append(ret, st.numeric(tightbeam.RPL_TOPICWHOTIME, nick, ch.name, ch.topicWho, ch.topicTime))
+		}
+	}
+
+	members := make([]string, 0, len(ch.members))
+	for _, mem := range ch.members {
+		name := mem.nick
+		if st.isupport.CaseMapping().Equal(name, st.nick) {
+			name = nick
+		}
+
+		if mem.prefixes != "" {
+			name = mem.prefixes[:1] + name
+		}
+
+		members = append(members, name)
+	}
+	sort.Strings(members)
+
+	for len(members) > 0 {
+		var line []string
+		length := 0
+
+		for len(members) > 0 && (len(line) == 0 || length+len(members[0])+1 <= namesLineLen) {
+			length += len(members[0]) + 1
+			line = append(line, members[0])
+			members = members[1:]
+		}
+
+		ret = append(ret, st.numeric(tightbeam.RPL_NAMREPLY, nick, ch.status, ch.name, s.Join(line, " ")))
+	}
+
+	ret = append(ret, st.numeric(tightbeam.RPL_ENDOFNAMES, nick, ch.name, "End of /NAMES list"))
+
+	return ret
+}
+
+func (st *state) numeric(command, nick string, params ...string) *tightbeam.Message {
+	return &tightbeam.Message{
+		Prefix:  st.server.Copy(),
+		Command: command,
+		Params:  append([]string{nick}, params...),
+	}
+}
+
+func joinChannels(channels []*channel) (*tightbeam.Message, error) {
+	names := make([]string, 0, len(channels))
+	var keys []string
+
+	for _, ch := range channels {
+		names = append(names, ch.name)
+		if ch.key != "" {
+			keys = append(keys, ch.key)
+		}
+	}
+
+	return tightbeam.Join(names, keys)
+}
+
+func retarget(m *tightbeam.Message, nick string) *tightbeam.Message {
+	m = m.Copy()
+	if len(m.Params) > 0 {
+		m.Params[0] = nick
+	}
+
+	return m
+}
+
+func param(m *tightbeam.Message, n int) string {
+	if n < len(m.Params) {
+		return m.Params[n]
+	}
+
+	return ""
+}
+
+func sortPrefixes(prefixes, symbols string) string {
+	var b s.Builder
+
+	for n := 0; n < len(symbols); n++ {
+		if s.IndexByte(prefixes, symbols[n]) >= 0 {
+			b.WriteByte(symbols[n])
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,245 @@
+package tightbeam
+
+import (
+	"errors"
+	s "strings"
+)
+
+const (
+	MaxLineLength = 512
+	MaxTagsLength = 8191
+)
+
+var (
+	ErrorInvalidCommand = errors.New("irc: Invalid command")
+
+	ErrorInvalidParam = errors.New("irc: Params may not contain CR, LF or NUL")
+
+	ErrorInvalidMiddleParam = errors.New("irc: Middle params may not be empty, contain spaces or start with ':'")
+
+	ErrorInvalidTagKey = errors.New("irc: Invalid tag key")
+
+	ErrorInvalidPrefix = errors.New("irc: Invalid prefix")
+
+	ErrorMessageTooLong = errors.New("irc: Message too long")
+
+	ErrorMissingTarget = errors.New("irc: Missing target")
+
+	ErrorMissingModes = errors.New("irc: Mode arguments given without modes")
+)
+
+func (m *Message) Validate() error {
+	if !validCommand(m.Command) {
+		return ErrorInvalidCommand
+	}
+
+	for n, param := range m.Params {
+		if s.ContainsAny(param, "\r\n\x00") {
+			return ErrorInvalidParam
+		}
+
+		if n < len(m.Params)-1 && (param == "" || s.ContainsRune(param, ' ') || param[0] == ':') {
+			return ErrorInvalidMiddleParam
+		}
+	}
+
+	for k := range m.Tags {
+		if !validTagKey(k) {
+			return ErrorInvalidTagKey
+		}
+	}
+
+	if m.Prefix != nil && m.Prefix.Name != "" {
+		if s.ContainsAny(m.Prefix.String(), " \r\n\x00") {
+			return ErrorInvalidPrefix
+		}
+	}
+
+	if len(m.Tags) > 0 && len(m.Tags.String())+2 > MaxTagsLength {
+		return ErrorMessageTooLong
+	}
+
+	rest := m.Copy()
+	rest.Tags = nil
+	if len(rest.String())+2 > MaxLineLength {
+		return ErrorMessageTooLong
+	}
+
+	return nil
+}
+
+func validCommand(cmd string) bool {
+	if cmd == "" {
+		return false
+	}
+
+	if cmd[0] >= '0' && cmd[0] <= '9' {
+		if len(cmd) != 3 {
+			return false
+		}
+
+		for n := 0; n < len(cmd); n++ {
+			if cmd[n] < '0' || cmd[n] > '9' {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for n := 0; n < len(cmd); n++ {
+		c := cmd[n]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func validTagKey(key string) bool {
+	key = s.TrimPrefix(key, "+")
+	if key == "" {
+		return false
+	}
+
+	for n := 0; n < len(key); n++ {
+		c := key[n]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '/') {
+			return false
+		}
+	}
+
+	return key[len(key)-1] != '/'
+}
+
+type Builder struct {
+	m *Message
+}
+
+func NewBuilder(command string) *Builder {
+	return &Builder{m: &Message{
+		Tags:    Tags{},
+		Command: s.ToUpper(command),
+	}}
+}
+
+func (b *Builder) Tag(key, value string) *Builder {
+	b.m.Tags[key] = TagVal(value)
+	return b
+}
+
+func (b *Builder) Tags(tags Tags) *Builder {
+	for k, v := range tags {
+		b.m.Tags[k] = v
+	}
+	return b
+}
+
+func (b *Builder) Prefix(p *Prefix) *Builder {
+	b.m.Prefix = p.Copy()
+	return b
+}
+
+func (b *Builder) Param(params ...string) *Builder {
+	b.m.Params = append(b.m.Params, params...)
+	return b
+}
+
+func (b *Builder) Build() (*Message, error) {
+	m := b.m.Copy()
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func Privmsg(target, text string) (*Message, error) {
+	return textMessage("PRIVMSG", target, text)
+}
+
+func Notice(target, text string) (*Message, error) {
+	return textMessage("NOTICE", target, text)
+}
+
+func textMessage(command, target, text string) (*Message, error) {
+	if target == "" {
+		return nil, ErrorMissingTarget
+	}
+
+	return NewBuilder(command).Param(target, text).Build()
+}
+
+func Join(channels []string, keys []string) (*Message, error) {
+	if len(channels) == 0 {
+		return nil, ErrorMissingTarget
+	}
+
+	b := NewBuilder("JOIN").Param(s.Join(channels, ","))
+	if len(keys) > 0 {
+		b.Param(s.Join(keys, ","))
+	}
+
+	return b.Build()
+}
+
+func Part(channels []string, reason string) (*Message, error) {
+	if len(channels) == 0 {
+		return nil, ErrorMissingTarget
+	}
+
+	b := NewBuilder("PART").Param(s.Join(channels, ","))
+	if reason != "" {
+		b.Param(reason)
+	}
+
+	return b.Build()
+}
+
+func Kick(channel, nick, reason string) (*Message, error) {
+	if channel == "" || nick == "" {
+		return nil, ErrorMissingTarget
+	}
+
+	b := NewBuilder("KICK").Param(channel, nick)
+	if reason != "" {
+		b.Param(reason)
+	}
+
+	return b.Build()
+}
+
+func Mode(target, modes string, args ...string) (*Message, error) {
+	if target == "" {
+		return nil, ErrorMissingTarget
+	}
+
+	if modes == "" && len(args) > 0 {
+		return nil, ErrorMissingModes
+	}
+
+	b := NewBuilder("MODE").Param(target)
+	if modes != "" {
+		b.Param(modes).Param(args...)
+	}
+
+	return b.Build()
+}
+
+func Topic(channel, topic string) (*Message, error) {
+	if channel == "" {
+		return nil, ErrorMissingTarget
+	}
+
+	return NewBuilder("TOPIC").Param(channel, topic).Build()
+}
+
+func Invite(nick, channel string) (*Message, error) {
+	if nick == "" || channel == "" {
+		return nil, ErrorMissingTarget
+	}
+
+	return NewBuilder("INVITE").Param(nick, channel).Build()
+}
@@ -0,0 +1,109 @@
+package tightbeam_test
+
+import (
+	s "strings"
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+func TestBuilder(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		b    *tightbeam.Builder
+		want string
+		err  error
+	}{
+		{"basic", tightbeam.NewBuilder("privmsg").Param("#c", "hi there"), "PRIVMSG #c :hi there", nil},
+		{"trailing colon", tightbeam.NewBuilder("PRIVMSG").Param("#c", ":)"), "PRIVMSG #c ::)", nil},
+		{"empty trailing", tightbeam.NewBuilder("TOPIC").Param("#c", ""), "TOPIC #c :", nil},
+		{"tags and prefix", tightbeam.NewBuilder("PRIVMSG").Tag("+draft/reply", "a b").Tags(tightbeam.Tags{"label": "1"}).Prefix(&tightbeam.Prefix{Name: "n", User: "u", Host: "h"}).Param("#c", "hi"), `@+draft/reply=a\sb;label=1 :n!u@h PRIVMSG #c hi`, nil},
+		{"numeric", tightbeam.NewBuilder("001").Param("me", "Welcome"), "001 me Welcome", nil},
+		{"empty command", tightbeam.NewBuilder(""), "", tightbeam.ErrorInvalidCommand},
+		{"space in command", tightbeam.NewBuilder("PRIV MSG"), "", tightbeam.ErrorInvalidCommand},
+		{"short numeric", tightbeam.NewBuilder("01"), "", tightbeam.ErrorInvalidCommand},
+		{"mixed numeric", tightbeam.NewBuilder("0a1"), "", tightbeam.ErrorInvalidCommand},
+		{"CR in trailing", tightbeam.NewBuilder("PRIVMSG").Param("#c", "a\rb"), "", tightbeam.ErrorInvalidParam},
+		{"LF in middle", tightbeam.NewBuilder("PRIVMSG").Param("#c\n", "a"), "", tightbeam.ErrorInvalidParam},
+		{"NUL", tightbeam.NewBuilder("PRIVMSG").Param("#c", "a\x00"), "", tightbeam.ErrorInvalidParam},
+		{"space in middle", tightbeam.NewBuilder("PRIVMSG").Param("#c d", "x"), "", tightbeam.ErrorInvalidMiddleParam},
+		{"colon middle", tightbeam.NewBuilder("PRIVMSG").Param(":c", "x"), "", tightbeam.ErrorInvalidMiddleParam},
+		{"empty middle", tightbeam.NewBuilder("PRIVMSG").Param("", "x"), "", tightbeam.ErrorInvalidMiddleParam},
+		{"bad tag key", tightbeam.NewBuilder("TAGMSG").Tag("bad key", "").Param("#c"), "", tightbeam.ErrorInvalidTagKey},
+		{"tag key slash", tightbeam.NewBuilder("TAGMSG").Tag("+draft/", "").Param("#c"), "", tightbeam.ErrorInvalidTagKey},
+		{"bad prefix", tightbeam.NewBuilder("PRIVMSG").Prefix(&tightbeam.Prefix{Name: "a b"}).Param("#c", "x"), "", tightbeam.ErrorInvalidPrefix},
+		{"long line", tightbeam.NewBuilder("PRIVMSG").Param("#c", s.Repeat("x", 500)), "", tightbeam.ErrorMessageTooLong},
+		{"long tags", tightbeam.NewBuilder("TAGMSG").Tag("+x", s.Repeat("x", 8200)).Param("#c"), "", tightbeam.ErrorMessageTooLong},
+		{"long tags short line", tightbeam.NewBuilder("TAGMSG").Tag("+x", s.Repeat("x", 4000)).Param("#c"), "@+x=" + s.Repeat("x", 4000) + " TAGMSG #c", nil},
+	} {
+		m, err := tt.b.Build()
+		if err != tt.err {
+			t.Errorf("%s: Build() error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+
+		if err == nil && m.String() != tt.want {
+			t.Errorf("%s: Build() = %q, want %q", tt.name, m, tt.want)
+		}
+	}
+}
+
+func TestBuilderCopies(t *testing.T) {
+	p := &tightbeam.Prefix{Name: "n"}
+	b := tightbeam.NewBuilder("PRIVMSG").Prefix(p).Param("#c", "one")
+
+	first, _ := b.Build()
+	p.Name = "changed"
+	b.Tag("label", "2")
+	second, _ := b.Build()
+
+	if first.String() != ":n PRIVMSG #c one" || second.String() != "@label=2 :n PRIVMSG #c one" {
+		t.Fatalf("Build() = %q then %q", first, second)
+	}
+}
+
+func TestCommandConstructors(t *testing.T) {
+	build := func(m *tightbeam.Message, err error) string {
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		return m.String()
+	}
+
+	errorf := func(err error) string {
+		return "error: " + err.Error()
+	}
+
+	for _, tt := range []struct {
+		got  string
+		want string
+	}{
+		{build(tightbeam.Privmsg("#c", "hi there")), "PRIVMSG #c :hi there"},
+		{build(tightbeam.Privmsg("", "hi")), errorf(tightbeam.ErrorMissingTarget)},
+		{build(tightbeam.Privmsg("#c", "a\nb")), errorf(tightbeam.ErrorInvalidParam)},
+		{build(tightbeam.Notice("bob", "psst")), "NOTICE bob psst"},
+		{build(tightbeam.Join([]string{"#a", "#b"}, nil)), "JOIN #a,#b"},
+		{build(tightbeam.Join([]string{"#a", "#b"}, []string{"k"})), "JOIN #a,#b k"},
+		{build(tightbeam.Join(nil, nil)), errorf(tightbeam.ErrorMissingTarget)},
+		{build(tightbeam.Part([]string{"#a"}, "")), "PART #a"},
+		{build(tightbeam.Part([]string{"#a", "#b"}, "bye now")), "PART #a,#b :bye now"},
+		{build(tightbeam.Part(nil, "bye")), errorf(tightbeam.ErrorMissingTarget)},
+		{build(tightbeam.Kick("#a", "n", "")), "KICK #a n"},
+		{build(tightbeam.Kick("#a", "n", "bye now")), "KICK #a n :bye now"},
+		{build(tightbeam.Kick("#a", "", "bye")), errorf(tightbeam.ErrorMissingTarget)},
+		{build(tightbeam.Mode("#a", "")), "MODE #a"},
+		{build(tightbeam.Mode("#a", "+ov", "a", "b")), "MODE #a +ov a b"},
+		{build(tightbeam.Mode("#a", "", "a")), errorf(tightbeam.ErrorMissingModes)},
+		{build(tightbeam.Mode("", "+o", "a")), errorf(tightbeam.ErrorMissingTarget)},
+		{build(tightbeam.Mode("#a", "+bo", "bad mask", "n")), errorf(tightbeam.ErrorInvalidMiddleParam)},
+		{build(tightbeam.Topic("#a", "")), "TOPIC #a :"},
+		{build(tightbeam.Topic("#a", "new topic")), "TOPIC #a :new topic"},
+		{build(tightbeam.Topic("", "x")), errorf(tightbeam.ErrorMissingTarget)},
+		{build(tightbeam.Invite("bob", "#a")), "INVITE bob #a"},
+		{build(tightbeam.Invite("bob", "")), errorf(tightbeam.ErrorMissingTarget)},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package tightbeam
+
+import (
+	s "strings"
+)
+
+type CaseMapping string
+
+const (
+	CaseMappingASCII         CaseMapping = "ascii"
+	CaseMappingRFC1459       CaseMapping = "rfc1459"
+	CaseMappingStrictRFC1459 CaseMapping = "strict-rfc1459"
+)
+
+func (i ISupport) CaseMapping() CaseMapping {
+	if v, ok := i.Get("CASEMAPPING"); ok && v != "" {
+		return CaseMapping(s.ToLower(v))
+	}
+
+	return CaseMappingRFC1459
+}
+
+func (c CaseMapping) Fold(v string) string {
+	var upper rune
+
+	switch c {
+	case CaseMappingASCII:
+		upper = 'Z'
+	case CaseMappingStrictRFC1459:
+		upper = ']'
+	case CaseMappingRFC1459:
+		upper = '^'
+	default:
+		return s.ToLower(v)
+	}
+
+	return s.Map(func(r rune) rune {
+		if r >= 'A' && r <= upper {
+			return r + 32
+		}
+		return r
+	}, v)
+}
+
+func (c CaseMapping) Equal(a, b string) bool {
+	return c.Fold(a) == c.Fold(b)
+}
@@ -0,0 +1,263 @@
+package tightbeam
+
+import (
+	s "strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type Charset interface {
+	Name() string
+	Decode(v string) string
+	Encode(v string) string
+}
+
+type singleByteCharset struct {
+	name   string
+	high   [128]rune
+	encode map[rune]byte
+}
+
+func newSingleByteCharset(name string, overrides map[byte]rune) *singleByteCharset {
+	c := &singleByteCharset{name: name, encode: map[rune]byte{}}
+
+	for n := range c.high {
+		r := rune(0x80 + n)
+		if o, ok := overrides[byte(0x80+n)]; ok {
+			r = o
+		}
+
+		c.high[n] = r
+		c.encode[r] = byte(0x80 + n)
+	}
+
+	return c
+}
+
+func (c *singleByteCharset) Name() string {
+	return c.name
+}
+
+func (c *singleByteCharset) Decode(v string) string {
+	buf := &s.Builder{}
+	buf.Grow(len(v))
+
+	for n := 0; n < len(v); n++ {
+		if b := v[n]; b < 0x80 {
+			buf.WriteByte(b)
+		} else {
+			buf.WriteRune(c.high[b-0x80])
+		}
+	}
+
+	return buf.String()
+}
+
+func (c *singleByteCharset) Encode(v string) string {
+	buf := &s.Builder{}
+	buf.Grow(len(v))
+
+	for _, r := range v {
+		switch b, ok := c.encode[r]; {
+		case r < 0x80:
+			buf.WriteByte(byte(r))
+		case ok:
+			buf.WriteByte(b)
+		default:
+			buf.WriteByte('?')
+		}
+	}
+
+	return buf.String()
+}
+
+var (
+	Latin1 Charset = newSingleByteCharset("iso-8859-1", nil)
+
+	CP1252 Charset = newSingleByteCharset("windows-1252", map[byte]rune{
+		0x80: 0x20ac, 0x82: 0x201a, 0x83: 0x0192, 0x84: 0x201e,
+		0x85: 0x2026, 0x86: 0x2020, 0x87: 0x2021, 0x88: 0x02c6,
+		0x89: 0x2030, 0x8a: 0x0160, 0x8b: 0x2039, 0x8c: 0x0152,
+		0x8e: 0x017d, 0x91: 0x2018, 0x92: 0x2019, 0x93: 0x201c,
+		0x94: 0x201d, 0x95: 0x2022, 0x96: 0x2013, 0x97: 0x2014,
+		0x98: 0x02dc, 0x99: 0x2122, 0x9a: 0x0161, 0x9b: 0x203a,
+		0x9c: 0x0153, 0x9e: 0x017e, 0x9f: 0x0178,
+	})
+)
+
+func LookupCharset(name string) (Charset, bool) {
+	switch s.ToLower(s.ReplaceAll(name, "_", "-")) {
+	case "latin1", "latin-1", "iso-8859-1", "iso8859-1", "l1":
+		return Latin1, true
+	case "cp1252", "windows-1252", "win1252":
+		return CP1252, true
+	}
+
+	return nil, false
+}
+
+type Charsets struct {
+	Fallback Charset
+
+	lock    sync.RWMutex
+	casemap CaseMapping
+	targets map[string]charsetTarget
+}
+
+type charsetTarget struct {
+	name    string
+	charset Charset
+}
+
+func NewCharsets(fallback Charset) *Charsets {
+	return &Charsets{
+		Fallback: fallback,
+		casemap:  CaseMappingRFC1459,
+		targets:  map[string]charsetTarget{},
+	}
+}
+
+func (c *Charsets) CaseMapping() CaseMapping {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.caseMapping()
+}
+
+func (c *Charsets) SetCaseMapping(casemap CaseMapping) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if casemap == c.caseMapping() {
+		return
+	}
+
+	c.casemap = casemap
+
+	targets := make(map[string]charsetTarget, len(c.targets))
+	for _, t := range c.targets {
+		targets[casemap.Fold(t.name)] = t
+	}
+	c.targets = targets
+}
+
+func (c *Charsets) caseMapping() CaseMapping {
+	if c.casemap == "" {
+		return CaseMappingRFC1459
+	}
+
+	return c.casemap
+}
+
+func (c *Charsets) SetTarget(target string, cs Charset) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.targets == nil {
+		c.targets = map[string]charsetTarget{}
+	}
+
+	key := c.caseMapping().Fold(target)
+
+	if cs == nil {
+		delete(c.targets, key)
+		return
+	}
+
+	c.targets[key] = charsetTarget{name: target, charset: cs}
+}
+
+func (c *Charsets) Target(target string) Charset {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.targets[c.caseMapping().Fold(target)].charset
+}
+
+func (c *Charsets) DecodeMessage(m *Message) *Message {
+	if messageValidUTF8(m) {
+		return m
+	}
+
+	var cs Charset
+	if len(m.Params) > 0 {
+		cs = c.Target(m.Params[0])
+	}
+
+	if cs == nil && m.Prefix != nil && m.Prefix.Name != "" {
+		cs = c.Target(m.Prefix.Name)
+	}
+
+	if cs == nil {
+		cs = c.Fallback
+	}
+
+	decode := func(v string) string {
+		if utf8.ValidString(v) {
+			return v
+		}
+
+		if cs == nil {
+			return s.ToValidUTF8(v, string(utf8.RuneError))
+		}
+
+		return cs.Decode(v)
+	}
+
+	ret := m.Copy()
+
+	for n, param := range ret.Params {
+		ret.Params[n] = decode(param)
+	}
+
+	for k, v := range ret.Tags {
+		ret.Tags[k] = TagVal(decode(string(v)))
+	}
+
+	if ret.Prefix != nil {
+		ret.Prefix.Name = decode(ret.Prefix.Name)
+		ret.Prefix.User = decode(ret.Prefix.User)
+		ret.Prefix.Host = decode(ret.Prefix.Host)
+	}
+
+	return ret
+}
+
+func (c *Charsets) EncodeMessage(m *Message, utf8Only bool) *Message {
+	if utf8Only || len(m.Params) < 2 {
+		return m
+	}
+
+	cs := c.Target(m.Params[0])
+	if cs == nil {
+		return m
+	}
+
+	ret := m.Copy()
+
+	for n := 1; n < len(ret.Params); n++ {
+		ret.Params[n] = cs.Encode(ret.Params[n])
+	}
+
+	return ret
+}
+
+func messageValidUTF8(m *Message) bool {
+	for _, param := range m.Params {
+		if !utf8.ValidString(param) {
+			return false
+		}
+	}
+
+	for _, v := range m.Tags {
+		if !utf8.ValidString(string(v)) {
+			return false
+		}
+	}
+
+	if m.Prefix != nil {
+		return utf8.ValidString(m.Prefix.Name) && utf8.ValidString(m.Prefix.User) && utf8.ValidString(m.Prefix.Host)
+	}
+
+	return true
+}
@@ -0,0 +1,200 @@
+package tightbeam_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+func TestCharsetDecodeEncode(t *testing.T) {
+	for _, tt := range []struct {
+		charset tightbeam.Charset
+		raw     string
+		text    string
+		encoded string
+	}{
+		{tightbeam.Latin1, "plain", "plain", "plain"},
+		{tightbeam.Latin1, "caf\xe9", "café", "caf\xe9"},
+		{tightbeam.Latin1, "\x80\x9f\xa0\xff", "\u0080\u009f ÿ", "\x80\x9f\xa0\xff"},
+		{tightbeam.CP1252, "caf\xe9 \x80", "café €", "caf\xe9 \x80"},
+		{tightbeam.CP1252, "\x93quoted\x94 \x85", "“quoted” …", "\x93quoted\x94 \x85"},
+		{tightbeam.CP1252, "\x81\x8d", "\u0081\u008d", "\x81\x8d"},
+	} {
+		if got := tt.charset.Decode(tt.raw); got != tt.text {
+			t.Errorf("%s.Decode(%q) = %q, want %q", tt.charset.Name(), tt.raw, got, tt.text)
+		}
+
+		if got := tt.charset.Encode(tt.text); got != tt.encoded {
+			t.Errorf("%s.Encode(%q) = %q, want %q", tt.charset.Name(), tt.text, got, tt.encoded)
+		}
+	}
+
+	for _, tt := range []struct {
+		charset tightbeam.Charset
+		text    string
+		want    string
+	}{
+		{tightbeam.Latin1, "€ ☃", "? ?"},
+		{tightbeam.CP1252, "\u0080 ☃", "? ?"},
+	} {
+		if got := tt.charset.Encode(tt.text); got != tt.want {
+			t.Errorf("%s.Encode(%q) = %q, want %q", tt.charset.Name(), tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLookupCharset(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want tightbeam.Charset
+	}{
+		{"latin1", tightbeam.Latin1},
+		{"ISO-8859-1", tightbeam.Latin1},
+		{"iso_8859_1", tightbeam.Latin1},
+		{"L1", tightbeam.Latin1},
+		{"cp1252", tightbeam.CP1252},
+		{"Windows-1252", tightbeam.CP1252},
+		{"windows_1252", tightbeam.CP1252},
+		{"utf-8", nil},
+		{"koi8-r", nil},
+	} {
+		got, ok := tightbeam.LookupCharset(tt.name)
+		if got != tt.want || ok != (tt.want != nil) {
+			t.Errorf("LookupCharset(%q) = %v, %v, want %v", tt.name, got, ok, tt.want)
+		}
+	}
+
+	if tightbeam.Latin1.Name() != "iso-8859-1" || tightbeam.CP1252.Name() != "windows-1252" {
+		t.Errorf("Name() = %q, %q", tightbeam.Latin1.Name(), tightbeam.CP1252.Name())
+	}
+}
+
+func TestCharsetsDecodeMessage(t *testing.T) {
+	cs := tightbeam.NewCharsets(tightbeam.CP1252)
+	cs.SetTarget("#Latin", tightbeam.Latin1)
+	cs.SetTarget("oldbot", tightbeam.Latin1)
+
+	for _, tt := range []struct {
+		line string
+		want string
+	}{
+		{":a!b@c PRIVMSG #chan :café", ":a!b@c PRIVMSG #chan café"},
+		{":a!b@c PRIVMSG #chan :caf\xe9 \x80", ":a!b@c PRIVMSG #chan :café €"},
+		{":a!b@c PRIVMSG #latin :\x80", ":a!b@c PRIVMSG #latin \u0080"},
+		{":oldbot!b@c PRIVMSG me :\x80", ":oldbot!b@c PRIVMSG me \u0080"},
+		{":OLDBOT!b@c PRIVMSG #latin2 :\x80", ":OLDBOT!b@c PRIVMSG #latin2 \u0080"},
+		{":a!b@c PRIVMSG #chan :ok \x80", ":a!b@c PRIVMSG #chan :ok €"},
+		{"@+draft/x=caf\xe9 :a!b@c TAGMSG #chan", "@+draft/x=café :a!b@c TAGMSG #chan"},
+		{":caf\xe9!b@c NICK new", ":café!b@c NICK new"},
+	} {
+		got := cs.DecodeMessage(tightbeam.MustParseMessage(tt.line))
+		if !got.Equal(tightbeam.MustParseMessage(tt.want)) {
+			t.Errorf("DecodeMessage(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	m := tightbeam.MustParseMessage("PRIVMSG #chan :valid")
+	if cs.DecodeMessage(m) != m {
+		t.Error("DecodeMessage() copied a valid UTF-8 message")
+	}
+
+	raw := tightbeam.MustParseMessage("PRIVMSG #chan :\xff")
+	if got := tightbeam.NewCharsets(nil).DecodeMessage(raw); got.Trailing() != "�" || raw.Trailing() != "\xff" {
+		t.Errorf("DecodeMessage() without fallback = %q, original %q", got.Trailing(), raw.Trailing())
+	}
+}
+
+func TestCharsetsEncodeMessage(t *testing.T) {
+	cs := tightbeam.NewCharsets(tightbeam.CP1252)
+	cs.SetTarget("#latin", tightbeam.Latin1)
+
+	for _, tt := range []struct {
+		line     string
+		utf8Only bool
+		want     string
+	}{
+		{"PRIVMSG #LATIN :café ☃", false, "PRIVMSG #LATIN :caf\xe9 ?"},
+		{"PRIVMSG #LATIN :café", true, "PRIVMSG #LATIN café"},
+		{"PRIVMSG #chan :café", false, "PRIVMSG #chan café"},
+		{"PART #latin", false, "PART #latin"},
+	} {
+		if got := cs.EncodeMessage(tightbeam.MustParseMessage(tt.line), tt.utf8Only).String(); got != tt.want {
+			t.Errorf("EncodeMessage(%q, %v) = %q, want %q", tt.line, tt.utf8Only, got, tt.want)
+		}
+	}
+
+	cs.SetTarget("#latin", nil)
+	if cs.Target("#latin") != nil {
+		t.Error("SetTarget(nil) did not remove the override")
+	}
+}
+
+func TestCharsetsCaseMapping(t *testing.T) {
+	cs := tightbeam.NewCharsets(nil)
+	cs.SetTarget("#a[b]", tightbeam.Latin1)
+
+	if cs.CaseMapping() != tightbeam.CaseMappingRFC1459 || cs.Target("#A{B}") != tightbeam.Latin1 {
+		t.Fatal("Target() does not fold with rfc1459 by default")
+	}
+
+	cs.SetCaseMapping(tightbeam.CaseMappingASCII)
+
+	if cs.Target("#A{B}") != nil || cs.Target("#A[B]") != tightbeam.Latin1 {
+		t.Fatal("Target() after SetCaseMapping(ascii) still folds {} to []")
+	}
+
+	var zero tightbeam.Charsets
+	zero.SetTarget("#X", tightbeam.CP1252)
+	if zero.Target("#x") != tightbeam.CP1252 {
+		t.Fatal("zero Charsets does not store targets")
+	}
+}
+
+func TestClientCharsets(t *testing.T) {
+	srv := tightbeamtest.NewServer(t)
+	received := make(chan *tightbeam.Message, 1)
+
+	cs := tightbeam.NewCharsets(tightbeam.CP1252)
+	cs.SetTarget("#latin{1}", tightbeam.Latin1)
+
+	c := tightbeam.NewClient(srv.Conn(), tightbeam.ClientConfig{
+		Nick:     "bot",
+		Charsets: cs,
+		Handler: tightbeam.HandlerFunc(func(c *tightbeam.Client, m *tightbeam.Message) {
+			if m.Command == "PRIVMSG" {
+				received <- m
+			}
+		}),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	srv.Register("bot")
+	srv.Numeric(tightbeam.RPL_ISUPPORT, "bot", "CASEMAPPING=ascii", "are supported by this server")
+
+	srv.Send(":alice!a@h PRIVMSG #chan :caf\xe9")
+	if m := <-received; m.Trailing() != "café" {
+		t.Fatalf("handler got %q", m.Trailing())
+	}
+
+	if cs.CaseMapping() != tightbeam.CaseMappingASCII {
+		t.Fatalf("CaseMapping() = %q after ISUPPORT", cs.CaseMapping())
+	}
+
+	go c.Send(&tightbeam.Message{Command: "PRIVMSG", Params: []string{"#latin{1}", "café"}})
+	srv.Expect("PRIVMSG #latin{1} caf\xe9")
+
+	go c.Send(&tightbeam.Message{Command: "PRIVMSG", Params: []string{"#latin[1]", "café"}})
+	srv.Expect("PRIVMSG #latin[1] café")
+
+	srv.Numeric(tightbeam.RPL_ISUPPORT, "bot", "UTF8ONLY", "are supported by this server")
+	srv.Send("PING :sync")
+	srv.Expect("PONG sync")
+
+	go c.Send(&tightbeam.Message{Command: "PRIVMSG", Params: []string{"#latin{1}", "café"}})
+	srv.Expect("PRIVMSG #latin{1} café")
+}
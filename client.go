@@ -0,0 +1,470 @@
+package tightbeam
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	s "strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrorClientClosed = errors.New("irc: Client closed")
+
+const lagPingPrefix = "tightbeam-lag-"
+
+type Handler interface {
+	Handle(c *Client, m *Message)
+}
+
+type HandlerFunc func(c *Client, m *Message)
+
+func (f HandlerFunc) Handle(c *Client, m *Message) {
+	f(c, m)
+}
+
+type ClientConfig struct {
+	Nick string
+	User string
+	Name string
+	Pass string
+
+	PingInterval time.Duration
+
+	Dialer    Dialer
+	TLSConfig *tls.Config
+
+	Charsets *Charsets
+
+	Multiline bool
+
+	Handler Handler
+	Logger  *slog.Logger
+	Metrics Metrics
+}
+
+type Client struct {
+	config ClientConfig
+
+	transport Transport
+
+	writeLock sync.Mutex
+	sendQueue int32
+	redactor  Redactor
+
+	lock       sync.Mutex
+	nick       string
+	isupport   ISupport
+	registered bool
+	lag        time.Duration
+	collectors map[*collector]struct{}
+	multiline  Multiline
+	capOffered map[string]bool
+
+	assembler MultilineAssembler
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type collector struct {
+	fn func(m *Message) bool
+}
+
+func NewClient(conn io.ReadWriteCloser, config ClientConfig) *Client {
+	return NewTransportClient(NewStreamTransport(conn), config)
+}
+
+func NewTransportClient(t Transport, config ClientConfig) *Client {
+	if config.User == "" {
+		config.User = config.Nick
+	}
+
+	if config.Name == "" {
+		config.Name = config.Nick
+	}
+
+	if config.Logger == nil {
+		config.Logger = discardLogger
+	}
+
+	if config.Metrics == nil {
+		config.Metrics = NopMetrics{}
+	}
+
+	return &Client{
+		config:     config,
+		transport:  t,
+		nick:       config.Nick,
+		isupport:   ISupport{},
+		collectors: map[*collector]struct{}{},
+		done:       make(chan struct{}),
+	}
+}
+
+func (c *Client) CurrentNick() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.nick
+}
+
+func (c *Client) ISupport() ISupport {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.isupport.Copy()
+}
+
+func (c *Client) Registered() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.registered
+}
+
+func (c *Client) Lag() time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.lag
+}
+
+func (c *Client) Send(m *Message) error {
+	c.config.Metrics.SendQueue(int(atomic.AddInt32(&c.sendQueue, 1)))
+	defer func() {
+		c.config.Metrics.SendQueue(int(atomic.AddInt32(&c.sendQueue, -1)))
+	}()
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	select {
+	case <-c.done:
+		return ErrorClientClosed
+	default:
+	}
+
+	if len(m.Tags.ClientTags()) > 0 {
+		c.lock.Lock()
+		deny := c.isupport.ClientTagDeny()
+		c.lock.Unlock()
+
+		if m = deny.Filter(m); m == nil {
+			return nil
+		}
+	}
+
+	if c.config.Charsets != nil {
+		c.lock.Lock()
+		utf8Only := c.isupport.Has("UTF8ONLY")
+		c.lock.Unlock()
+
+		m = c.config.Charsets.EncodeMessage(m, utf8Only)
+	}
+
+	line := m.String()
+	c.logLine("send", line)
+	c.config.Metrics.MessageOut(m.Command, len(line)+2)
+
+	return c.transport.WriteLine(line)
+}
+
+func (c *Client) Close() error {
+	var err error
+
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.transport.Close()
+	})
+
+	return err
+}
+
+func (c *Client) Run(ctx context.Context) error {
+	log := c.config.Logger
+
+	log.Info("irc: connection started", "nick", c.config.Nick)
+	defer c.Close()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-c.done:
+		}
+	}()
+
+	if err := c.register(); err != nil {
+		log.Info("irc: connection closed", "error", err)
+		return err
+	}
+
+	if c.config.PingInterval > 0 {
+		go c.pingLoop()
+	}
+
+	for {
+		line, err := c.transport.ReadLine()
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+
+			log.Info("irc: connection closed", "error", err)
+			return err
+		}
+
+		c.logLine("recv", line)
+
+		m, err := ParseMessage(line)
+		if err != nil {
+			log.Warn("irc: unparseable line", "error", err)
+			c.config.Metrics.ParseError()
+			continue
+		}
+
+		c.config.Metrics.MessageIn(m.Command, len(line)+2)
+
+		if c.config.Charsets != nil {
+			m = c.config.Charsets.DecodeMessage(m)
+		}
+
+		if c.config.Multiline {
+			var ok bool
+			if m, ok = c.assembler.Handle(m); !ok {
+				continue
+			}
+		}
+
+		c.handle(m)
+	}
+}
+
+func (c *Client) register() error {
+	c.config.Logger.Info("irc: registering", "nick", c.config.Nick, "user", c.config.User)
+
+	if c.config.Multiline {
+		if err := c.Send(&Message{Command: "CAP", Params: []string{"LS", "302"}}); err != nil {
+			return err
+		}
+	}
+
+	if c.config.Pass != "" {
+		if err := c.Send(&Message{Command: "PASS", Params: []string{c.config.Pass}}); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Send(&Message{Command: "NICK", Params: []string{c.config.Nick}}); err != nil {
+		return err
+	}
+
+	return c.Send(&Message{
+		Command: "USER",
+		Params:  []string{c.config.User, "0", "*", c.config.Name},
+	})
+}
+
+func (c *Client) handle(m *Message) {
+	switch m.Command {
+	case "PING":
+		c.Send(&Message{Command: "PONG", Params: m.Params})
+	case "PONG":
+		c.handlePong(m)
+	case RPL_WELCOME:
+		c.lock.Lock()
+		c.registered = true
+		if len(m.Params) > 0 {
+			c.nick = m.Params[0]
+		}
+		nick := c.nick
+		c.lock.Unlock()
+
+		c.config.Logger.Info("irc: registered", "nick", nick)
+	case RPL_ISUPPORT:
+		c.lock.Lock()
+		c.isupport.Update(m)
+		casemap := c.isupport.CaseMapping()
+		c.lock.Unlock()
+
+		if c.config.Charsets != nil {
+			c.config.Charsets.SetCaseMapping(casemap)
+		}
+	case ERR_NICKNAMEINUSE:
+		if !c.Registered() {
+			c.lock.Lock()
+			c.nick += "_"
+			nick := c.nick
+			c.lock.Unlock()
+
+			c.config.Logger.Info("irc: nickname in use, retrying", "nick", nick)
+
+			c.Send(&Message{Command: "NICK", Params: []string{nick}})
+		}
+	case "CAP":
+		if len(m.Params) > 1 {
+			c.config.Logger.Info("irc: capability negotiation", "subcommand", m.Params[1], "caps", m.Trailing())
+		}
+
+		if c.config.Multiline {
+			c.handleCap(m)
+		}
+	case "NICK":
+		c.lock.Lock()
+		if m.Prefix != nil && len(m.Params) > 0 && c.isupport.CaseMapping().Equal(m.Prefix.Name, c.nick) {
+			c.nick = m.Params[0]
+		}
+		c.lock.Unlock()
+	}
+
+	c.lock.Lock()
+	collectors := make([]*collector, 0, len(c.collectors))
+	for col := range c.collectors {
+		collectors = append(collectors, col)
+	}
+	c.lock.Unlock()
+
+	for _, col := range collectors {
+		if col.fn(m) {
+			c.removeCollector(col)
+		}
+	}
+
+	if c.config.Handler != nil {
+		start := time.Now()
+		c.config.Handler.Handle(c, m)
+		c.config.Metrics.HandlerLatency(m.Command, time.Since(start))
+	}
+}
+
+func (c *Client) SendMultiline(command, target, text string) error {
+	c.lock.Lock()
+	ml := c.multiline
+	c.lock.Unlock()
+
+	for _, m := range ml.Split(command, target, text) {
+		if err := c.Send(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) handleCap(m *Message) {
+	if len(m.Params) < 3 {
+		return
+	}
+
+	subcommand := s.ToUpper(m.Params[1])
+
+	c.lock.Lock()
+	c.multiline.HandleCap(m)
+
+	if subcommand == "LS" || subcommand == "NEW" {
+		if c.capOffered == nil {
+			c.capOffered = map[string]bool{}
+		}
+
+		for _, item := range s.Fields(m.Trailing()) {
+			name, _, _ := s.Cut(item, "=")
+			c.capOffered[name] = true
+		}
+	} else if subcommand == "DEL" {
+		for _, name := range s.Fields(m.Trailing()) {
+			delete(c.capOffered, name)
+		}
+	}
+
+	offered := c.capOffered[CapMultiline] && c.capOffered["batch"]
+	enabled := c.multiline.Enabled
+	registered := c.registered
+	c.lock.Unlock()
+
+	switch subcommand {
+	case "LS":
+		if m.Params[2] == "*" {
+			return
+		}
+
+		if offered {
+			c.Send(&Message{Command: "CAP", Params: []string{"REQ", "batch " + CapMultiline}})
+		} else if !registered {
+			c.Send(&Message{Command: "CAP", Params: []string{"END"}})
+		}
+	case "NEW":
+		if offered && !enabled {
+			c.Send(&Message{Command: "CAP", Params: []string{"REQ", "batch " + CapMultiline}})
+		}
+	case "ACK", "NAK":
+		if !registered {
+			c.Send(&Message{Command: "CAP", Params: []string{"END"}})
+		}
+	}
+}
+
+func (c *Client) pingLoop() {
+	ticker := time.NewTicker(c.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			token := lagPingPrefix + strconv.FormatInt(now.UnixNano(), 10)
+			if c.Send(&Message{Command: "PING", Params: []string{token}}) != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Client) handlePong(m *Message) {
+	token := m.Trailing()
+	if !s.HasPrefix(token, lagPingPrefix) {
+		return
+	}
+
+	sent, err := strconv.ParseInt(token[len(lagPingPrefix):], 10, 64)
+	if err != nil {
+		return
+	}
+
+	lag := time.Since(time.Unix(0, sent))
+
+	c.lock.Lock()
+	c.lag = lag
+	c.lock.Unlock()
+
+	c.config.Metrics.Lag(lag)
+}
+
+func (c *Client) addCollector(fn func(m *Message) bool) *collector {
+	col := &collector{fn: fn}
+
+	c.lock.Lock()
+	c.collectors[col] = struct{}{}
+	c.lock.Unlock()
+
+	return col
+}
+
+func (c *Client) removeCollector(col *collector) {
+	c.lock.Lock()
+	delete(c.collectors, col)
+	c.lock.Unlock()
+}
+
+func (c *Client) logLine(dir, line string) {
+	if !c.config.Logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	c.config.Logger.Debug("irc: "+dir, "line", c.redactor.RedactLine(line))
+}
@@ -0,0 +1,138 @@
+package tightbeam
+
+import (
+	s "strings"
+)
+
+const (
+	TagTyping = "+typing"
+	TagReact  = "+draft/react"
+	TagReply  = "+draft/reply"
+)
+
+type TypingState string
+
+const (
+	TypingActive TypingState = "active"
+	TypingPaused TypingState = "paused"
+	TypingDone   TypingState = "done"
+)
+
+func IsClientTag(key string) bool {
+	return s.HasPrefix(key, "+")
+}
+
+func SplitTagKey(key string) (clientOnly bool, vendor string, name string) {
+	clientOnly = IsClientTag(key)
+	name = s.TrimPrefix(key, "+")
+
+	if n := s.LastIndexByte(name, '/'); n >= 0 {
+		vendor, name = name[:n], name[n+1:]
+	}
+
+	return clientOnly, vendor, name
+}
+
+func (t Tags) ClientTags() Tags {
+	ret := Tags{}
+
+	for k, v := range t {
+		if IsClientTag(k) {
+			ret[k] = v
+		}
+	}
+
+	return ret
+}
+
+func (t Tags) ServerTags() Tags {
+	ret := Tags{}
+
+	for k, v := range t {
+		if !IsClientTag(k) {
+			ret[k] = v
+		}
+	}
+
+	return ret
+}
+
+func Typing(target string, state TypingState) *Message {
+	return &Message{
+		Tags:    Tags{TagTyping: TagVal(state)},
+		Command: "TAGMSG",
+		Params:  []string{target},
+	}
+}
+
+func React(target, msgid, reaction string) *Message {
+	return &Message{
+		Tags:    Tags{TagReply: TagVal(msgid), TagReact: TagVal(reaction)},
+		Command: "TAGMSG",
+		Params:  []string{target},
+	}
+}
+
+func Reply(target, msgid, text string) *Message {
+	return &Message{
+		Tags:    Tags{TagReply: TagVal(msgid)},
+		Command: "PRIVMSG",
+		Params:  []string{target, text},
+	}
+}
+
+type ClientTagDeny struct {
+	all    bool
+	denied map[string]bool
+}
+
+func ParseClientTagDeny(v string) ClientTagDeny {
+	ret := ClientTagDeny{denied: map[string]bool{}}
+
+	for _, name := range s.Split(v, ",") {
+		switch {
+		case name == "":
+		case name == "*":
+			ret.all = true
+		case name[0] == '-':
+			ret.denied[name[1:]] = false
+		default:
+			ret.denied[name] = true
+		}
+	}
+
+	return ret
+}
+
+func (i ISupport) ClientTagDeny() ClientTagDeny {
+	v, _ := i.Get("CLIENTTAGDENY")
+	return ParseClientTagDeny(v)
+}
+
+func (d ClientTagDeny) Allowed(key string) bool {
+	if !IsClientTag(key) {
+		return true
+	}
+
+	if denied, ok := d.denied[key[1:]]; ok {
+		return !denied
+	}
+
+	return !d.all
+}
+
+func (d ClientTagDeny) Filter(m *Message) *Message {
+	ret := m.Copy()
+
+	for k := range ret.Tags {
+		if !d.Allowed(k) {
+			delete(ret.Tags, k)
+		}
+	}
+
+	if ret.Command == "TAGMSG" && len(ret.Tags.ClientTags()) == 0 {
+		return nil
+	}
+
+	return ret
+}
@@ -0,0 +1,142 @@
+package tightbeam_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+func TestSplitTagKey(t *testing.T) {
+	for _, tt := range []struct {
+		key        string
+		clientOnly bool
+		vendor     string
+		name       string
+	}{
+		{"time", false, "", "time"},
+		{"+typing", true, "", "typing"},
+		{"+draft/react", true, "draft", "react"},
+		{"example.com/foo", false, "example.com", "foo"},
+		{"+example.com/a/b", true, "example.com/a", "b"},
+	} {
+		clientOnly, vendor, name := tightbeam.SplitTagKey(tt.key)
+		if clientOnly != tt.clientOnly || vendor != tt.vendor || name != tt.name {
+			t.Errorf("SplitTagKey(%q) = %v, %q, %q, want %v, %q, %q", tt.key, clientOnly, vendor, name, tt.clientOnly, tt.vendor, tt.name)
+		}
+	}
+}
+
+func TestClientServerTags(t *testing.T) {
+	tags := tightbeam.ParseTags("time=x;+typing=active;msgid=1;+draft/react=a")
+
+	if got := tags.ClientTags().String(); got != "+draft/react=a;+typing=active" {
+		t.Errorf("ClientTags() = %q", got)
+	}
+
+	if got := tags.ServerTags().String(); got != "msgid=1;time=x" {
+		t.Errorf("ServerTags() = %q", got)
+	}
+}
+
+func TestClientTagBuilders(t *testing.T) {
+	for _, tt := range []struct {
+		m    *tightbeam.Message
+		want string
+	}{
+		{tightbeam.Typing("#chan", tightbeam.TypingActive), "@+typing=active TAGMSG #chan"},
+		{tightbeam.Typing("bob", tightbeam.TypingDone), "@+typing=done TAGMSG bob"},
+		{tightbeam.React("#chan", "abc", "ok"), "@+draft/react=ok;+draft/reply=abc TAGMSG #chan"},
+		{tightbeam.Reply("#chan", "abc", "hi there"), "@+draft/reply=abc PRIVMSG #chan :hi there"},
+	} {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestClientTagDeny(t *testing.T) {
+	for _, tt := range []struct {
+		deny    string
+		allowed map[string]bool
+	}{
+		{"", map[string]bool{"+typing": true, "+draft/react": true, "time": true}},
+		{"typing", map[string]bool{"+typing": false, "+draft/react": true}},
+		{"*", map[string]bool{"+typing": false, "+draft/react": false, "time": true}},
+		{"*,-typing", map[string]bool{"+typing": true, "+draft/react": false}},
+		{"draft/react,,-typing", map[string]bool{"+typing": true, "+draft/react": false}},
+	} {
+		d := tightbeam.ParseClientTagDeny(tt.deny)
+
+		for key, want := range tt.allowed {
+			if got := d.Allowed(key); got != want {
+				t.Errorf("ParseClientTagDeny(%q).Allowed(%q) = %v, want %v", tt.deny, key, got, want)
+			}
+		}
+	}
+}
+
+func TestClientTagDenyFilter(t *testing.T) {
+	for _, tt := range []struct {
+		deny string
+		line string
+		want string
+	}{
+		{"", "@+typing=active TAGMSG #c", "@+typing=active TAGMSG #c"},
+		{"*", "@+typing=active TAGMSG #c", ""},
+		{"*,-typing", "@+draft/react=x;+typing=active TAGMSG #c", "@+typing=active TAGMSG #c"},
+		{"typing", "@+typing=active;label=1 TAGMSG #c", ""},
+		{"*", "@+draft/reply=1;label=2 PRIVMSG #c :hi there", "@label=2 PRIVMSG #c :hi there"},
+	} {
+		m := tightbeam.MustParseMessage(tt.line)
+
+		got := ""
+		if ret := tightbeam.ParseClientTagDeny(tt.deny).Filter(m); ret != nil {
+			got = ret.String()
+		}
+
+		if got != tt.want {
+			t.Errorf("Filter(%q) with %q = %q, want %q", tt.line, tt.deny, got, tt.want)
+		}
+
+		if m.String() != tt.line {
+			t.Errorf("Filter(%q) modified its argument to %q", tt.line, m.String())
+		}
+	}
+}
+
+func TestISupportClientTagDeny(t *testing.T) {
+	i := tightbeam.ISupport{}
+	i.Update(tightbeam.MustParseMessage(":srv 005 me CLIENTTAGDENY=*,-typing :are supported"))
+
+	if d := i.ClientTagDeny(); !d.Allowed("+typing") || d.Allowed("+draft/react") {
+		t.Errorf("ClientTagDeny() from %v denies the wrong tags", i)
+	}
+}
+
+func TestClientSendClientTagDeny(t *testing.T) {
+	srv := tightbeamtest.NewServer(t)
+	c := tightbeam.NewClient(srv.Conn(), tightbeam.ClientConfig{Nick: "bot"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	srv.Register("bot")
+	srv.Numeric(tightbeam.RPL_ISUPPORT, "bot", "CLIENTTAGDENY=*,-typing", "are supported by this server")
+	srv.Send("PING :sync")
+	srv.Expect("PONG sync")
+
+	if err := c.Send(tightbeam.React("#chan", "abc", "ok")); err != nil {
+		t.Fatalf("Send() of an emptied TAGMSG = %v", err)
+	}
+
+	go c.Send(tightbeam.Reply("#chan", "abc", "hi"))
+	if m := srv.Expect("PRIVMSG #chan :hi"); len(m.Tags) != 0 {
+		t.Fatalf("denied tags were sent: %v", m.Tags)
+	}
+
+	go c.Send(tightbeam.Typing("#chan", tightbeam.TypingActive))
+	srv.Expect("@+typing=active TAGMSG #chan")
+}
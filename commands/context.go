@@ -0,0 +1,98 @@
+package commands
+
+import (
+	s "strings"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+type Context struct {
+	Client  *tightbeam.Client
+	Event   *tightbeam.PrivmsgEvent
+	Command *Command
+	Args    []string
+
+	router  *Router
+	casemap tightbeam.CaseMapping
+	raw     []string
+	values  map[string]interface{}
+}
+
+func (c *Context) From() *tightbeam.Prefix {
+	return c.Event.From
+}
+
+func (c *Context) Account() string {
+	account, _ := c.Event.Tags.GetTag("account")
+	return account
+}
+
+func (c *Context) CaseMapping() tightbeam.CaseMapping {
+	return c.casemap
+}
+
+func (c *Context) Allowed(cmd *Command) bool {
+	return cmd.Permission == nil || cmd.Permission(c)
+}
+
+func (c *Context) Has(name string) bool {
+	_, ok := c.values[name]
+	return ok
+}
+
+func (c *Context) Value(name string) interface{} {
+	return c.values[name]
+}
+
+func (c *Context) String(name string) string {
+	v, _ := c.values[name].(string)
+	return v
+}
+
+func (c *Context) Int(name string) int {
+	v, _ := c.values[name].(int)
+	return v
+}
+
+func (c *Context) Float(name string) float64 {
+	v, _ := c.values[name].(float64)
+	return v
+}
+
+func (c *Context) Bool(name string) bool {
+	v, _ := c.values[name].(bool)
+	return v
+}
+
+func (c *Context) Duration(name string) time.Duration {
+	v, _ := c.values[name].(time.Duration)
+	return v
+}
+
+func (c *Context) Reply(text string) error {
+	if c.Command != nil && c.Command.Private || !c.Event.IsChannel {
+		return c.ReplyPrivate(text)
+	}
+
+	return c.send(c.Event.Target, text)
+}
+
+func (c *Context) ReplyPrivate(text string) error {
+	return c.send(c.Event.From.Name, text)
+}
+
+func (c *Context) send(target, text string) error {
+	for _, line := range s.Split(text, "\n") {
+		m, err := tightbeam.Privmsg(target, s.TrimRight(line, "\r"))
+		if err != nil {
+			return err
+		}
+
+		if err := c.Client.Send(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	s "strings"
+	"time"
+)
+
+var (
+	ErrorMissingArgument = errors.New("commands: Missing argument")
+
+	ErrorTooManyArguments = errors.New("commands: Too many arguments")
+)
+
+type ParamType int
+
+const (
+	String ParamType = iota
+	Int
+	Float
+	Bool
+	Duration
+	Rest
+)
+
+type Param struct {
+	Name     string
+	Type     ParamType
+	Optional bool
+}
+
+type ArgumentError struct {
+	Param *Param
+	Value string
+	Err   error
+}
+
+func (e *ArgumentError) Error() string {
+	if e.Value == "" {
+		return e.Err.Error() + ": " + e.Param.Name
+	}
+
+	return "commands: Invalid value " + strconv.Quote(e.Value) + " for " + e.Param.Name
+}
+
+func (e *ArgumentError) Unwrap() error {
+	return e.Err
+}
+
+func (p *Param) Usage() string {
+	name := p.Name
+	if p.Type == Rest {
+		name += "..."
+	}
+
+	if p.Optional {
+		return "[" + name + "]"
+	}
+
+	return "<" + name + ">"
+}
+
+func (p *Param) parse(v string) (interface{}, error) {
+	switch p.Type {
+	case Int:
+		return strconv.Atoi(v)
+	case Float:
+		return strconv.ParseFloat(v, 64)
+	case Bool:
+		switch s.ToLower(v) {
+		case "on", "yes", "y":
+			return true, nil
+		case "off", "no", "n":
+			return false, nil
+		}
+
+		return strconv.ParseBool(v)
+	case Duration:
+		return time.ParseDuration(v)
+	}
+
+	return v, nil
+}
+
+func bind(params []Param, args []string, raw []string) (map[string]interface{}, error) {
+	ret := map[string]interface{}{}
+
+	for n := range params {
+		p := &params[n]
+
+		if len(args) == 0 {
+			if !p.Optional {
+				return nil, &ArgumentError{Param: p, Err: ErrorMissingArgument}
+			}
+			continue
+		}
+
+		if p.Type == Rest {
+			if len(raw) > 0 {
+				ret[p.Name] = raw[0]
+			} else {
+				ret[p.Name] = s.Join(args, " ")
+			}
+			args = nil
+			continue
+		}
+
+		v, err := p.parse(args[0])
+		if err != nil {
+			return nil, &ArgumentError{Param: p, Value: args[0], Err: err}
+		}
+
+		ret[p.Name] = v
+		args = args[1:]
+		if len(raw) > 0 {
+			raw = raw[1:]
+		}
+	}
+
+	if len(args) > 0 {
+		return nil, ErrorTooManyArguments
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBind(t *testing.T) {
+	params := []Param{
+		{Name: "n", Type: Int},
+		{Name: "f", Type: Float, Optional: true},
+	}
+
+	flags := []Param{
+		{Name: "on", Type: Bool},
+		{Name: "wait", Type: Duration, Optional: true},
+	}
+
+	rest := []Param{
+		{Name: "target"},
+		{Name: "text", Type: Rest, Optional: true},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		params []Param
+		args   []string
+		raw    []string
+		want   map[string]interface{}
+		err    error
+	}{
+		{"int", params, []string{"3"}, nil, map[string]interface{}{"n": 3}, nil},
+		{"int float", params, []string{"3", "1.5"}, nil, map[string]interface{}{"n": 3, "f": 1.5}, nil},
+		{"missing", params, nil, nil, nil, ErrorMissingArgument},
+		{"bad int", params, []string{"x"}, nil, nil, invalid("x")},
+		{"too many", params, []string{"1", "2", "3"}, nil, nil, ErrorTooManyArguments},
+		{"bool yes", flags, []string{"yes"}, nil, map[string]interface{}{"on": true}, nil},
+		{"bool off", flags, []string{"OFF", "5s"}, nil, map[string]interface{}{"on": false, "wait": 5 * time.Second}, nil},
+		{"bad bool", flags, []string{"maybe"}, nil, nil, invalid("maybe")},
+		{"bad duration", flags, []string{"on", "soon"}, nil, nil, invalid("soon")},
+		{"rest raw", rest, []string{"#c", "hi", "there"}, []string{"#c  hi   there", "hi   there", "there"}, map[string]interface{}{"target": "#c", "text": "hi   there"}, nil},
+		{"rest quoted", rest, []string{"#c", "a b"}, []string{`#c "a b"`, `"a b"`}, map[string]interface{}{"target": "#c", "text": `"a b"`}, nil},
+		{"rest joined", rest, []string{"#c", "hi", "there"}, nil, map[string]interface{}{"target": "#c", "text": "hi there"}, nil},
+		{"rest empty", rest, []string{"#c"}, []string{"#c"}, map[string]interface{}{"target": "#c"}, nil},
+	} {
+		got, err := bind(tt.params, tt.args, tt.raw)
+		var want, argErr *ArgumentError
+		switch {
+		case errors.As(tt.err, &want):
+			if !errors.As(err, &argErr) || argErr.Value != want.Value {
+				t.Errorf("%s: bind() error = %v, want invalid value %q", tt.name, err, want.Value)
+			}
+		case !errors.Is(err, tt.err):
+			t.Errorf("%s: bind() error = %v, want %v", tt.name, err, tt.err)
+		case err == nil && !reflect.DeepEqual(got, tt.want):
+			t.Errorf("%s: bind() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func invalid(v string) error {
+	return &ArgumentError{Value: v}
+}
+
+func TestParamUsage(t *testing.T) {
+	cmd := &Command{Name: "say", Params: []Param{
+		{Name: "target"},
+		{Name: "count", Type: Int, Optional: true},
+		{Name: "text", Type: Rest},
+	}}
+
+	if got := cmd.Usage("!"); got != "!say <target> [count] <text...>" {
+		t.Fatalf("Usage() = %q", got)
+	}
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+type Permission func(ctx *Context) bool
+
+func Masks(masks ...string) Permission {
+	parsed := make([]*tightbeam.Mask, 0, len(masks))
+	for _, m := range masks {
+		parsed = append(parsed, tightbeam.ParseMask(m))
+	}
+
+	return func(ctx *Context) bool {
+		for _, m := range parsed {
+			if m.MatchCase(ctx.From(), ctx.CaseMapping()) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func Accounts(accounts ...string) Permission {
+	return func(ctx *Context) bool {
+		account := ctx.Account()
+		if account == "" {
+			return false
+		}
+
+		for _, a := range accounts {
+			if ctx.CaseMapping().Equal(a, account) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func ChannelOnly() Permission {
+	return func(ctx *Context) bool {
+		return ctx.Event.IsChannel
+	}
+}
+
+func AnyOf(perms ...Permission) Permission {
+	return func(ctx *Context) bool {
+		for _, p := range perms {
+			if p(ctx) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func AllOf(perms ...Permission) Permission {
+	return func(ctx *Context) bool {
+		for _, p := range perms {
+			if !p(ctx) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+func TestPermissions(t *testing.T) {
+	context := func(line string) *Context {
+		ev := tightbeam.DecodeEvent(tightbeam.MustParseMessage(line)).(*tightbeam.PrivmsgEvent)
+		return &Context{Event: ev, casemap: tightbeam.CaseMappingRFC1459}
+	}
+
+	admin := Masks("*!*@admin.example", "op!*@*")
+	staff := Accounts("Alice", "bob[1]")
+	both := AllOf(ChannelOnly(), AnyOf(admin, staff))
+
+	for _, tt := range []struct {
+		line  string
+		perm  Permission
+		allow bool
+	}{
+		{":n!u@admin.example PRIVMSG #c :!x", admin, true},
+		{":n!u@ADMIN.EXAMPLE PRIVMSG #c :!x", admin, true},
+		{":OP!u@h PRIVMSG #c :!x", admin, true},
+		{":n!u@h PRIVMSG #c :!x", admin, false},
+		{"@account=alice :n!u@h PRIVMSG #c :!x", staff, true},
+		{"@account=BOB{1} :n!u@h PRIVMSG #c :!x", staff, true},
+		{"@account=carol :n!u@h PRIVMSG #c :!x", staff, false},
+		{":n!u@h PRIVMSG #c :!x", staff, false},
+		{":n!u@h PRIVMSG #c :!x", ChannelOnly(), true},
+		{":n!u@h PRIVMSG bot :!x", ChannelOnly(), false},
+		{"@account=alice :n!u@h PRIVMSG #c :!x", both, true},
+		{":n!u@admin.example PRIVMSG #c :!x", both, true},
+		{"@account=alice :n!u@h PRIVMSG bot :!x", both, false},
+		{":n!u@h PRIVMSG #c :!x", both, false},
+		{":n!u@h PRIVMSG #c :!x", AnyOf(), false},
+		{":n!u@h PRIVMSG #c :!x", AllOf(), true},
+	} {
+		if got := tt.perm(context(tt.line)); got != tt.allow {
+			t.Errorf("%q: permission = %v, want %v", tt.line, got, tt.allow)
+		}
+	}
+}
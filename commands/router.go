@@ -0,0 +1,272 @@
+package commands
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	s "strings"
+	"sync"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+var (
+	ErrorPermissionDenied = errors.New("commands: Permission denied")
+
+	ErrorDuplicateCommand = errors.New("commands: Duplicate command name")
+)
+
+type Command struct {
+	Name    string
+	Aliases []string
+	Help    string
+	Params  []Param
+
+	Permission Permission
+	Private    bool
+
+	Run func(ctx *Context) error
+}
+
+func (c *Command) Usage(prefix string) string {
+	ret := prefix + c.Name
+	for n := range c.Params {
+		ret += " " + c.Params[n].Usage()
+	}
+
+	return ret
+}
+
+type Router struct {
+	Prefix  string
+	Mention bool
+
+	OnError func(ctx *Context, err error)
+	Logger  *slog.Logger
+
+	lock     sync.RWMutex
+	commands map[string]*Command
+}
+
+func NewRouter(prefix string) *Router {
+	r := &Router{
+		Prefix:   prefix,
+		Mention:  true,
+		commands: map[string]*Command{},
+	}
+
+	r.Add(&Command{
+		Name:   "help",
+		Help:   "List commands or show usage for one",
+		Params: []Param{{Name: "command", Optional: true}},
+		Run:    r.help,
+	})
+
+	return r
+}
+
+func (r *Router) Add(cmd *Command) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+	for _, name := range names {
+		if _, ok := r.commands[s.ToLower(name)]; ok {
+			return ErrorDuplicateCommand
+		}
+	}
+
+	for _, name := range names {
+		r.commands[s.ToLower(name)] = cmd
+	}
+
+	return nil
+}
+
+func (r *Router) Remove(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cmd, ok := r.commands[s.ToLower(name)]
+	if !ok {
+		return
+	}
+
+	for key, c := range r.commands {
+		if c == cmd {
+			delete(r.commands, key)
+		}
+	}
+}
+
+func (r *Router) Lookup(name string) *Command {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.commands[s.ToLower(name)]
+}
+
+func (r *Router) Commands() []*Command {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	seen := map[*Command]bool{}
+	ret := []*Command{}
+
+	for _, cmd := range r.commands {
+		if !seen[cmd] {
+			seen[cmd] = true
+			ret = append(ret, cmd)
+		}
+	}
+
+	sort.Slice(ret, func(a, b int) bool {
+		return ret[a].Name < ret[b].Name
+	})
+
+	return ret
+}
+
+func (r *Router) Parse(text string, nick string, casemap tightbeam.CaseMapping, private bool) (string, bool) {
+	if r.Prefix != "" && s.HasPrefix(text, r.Prefix) {
+		return text[len(r.Prefix):], true
+	}
+
+	if r.Mention && nick != "" && len(text) > len(nick) && casemap.Equal(text[:len(nick)], nick) {
+		rest := text[len(nick):]
+		if rest[0] == ':' || rest[0] == ',' {
+			return s.TrimLeft(rest[1:], " "), true
+		}
+	}
+
+	if private {
+		return text, true
+	}
+
+	return "", false
+}
+
+func (r *Router) Handle(c *tightbeam.Client, m *tightbeam.Message) {
+	if m.Command != "PRIVMSG" {
+		return
+	}
+
+	r.HandleEvent(c, c.ISupport().DecodeEvent(m))
+}
+
+func (r *Router) HandleEvent(c *tightbeam.Client, e tightbeam.Event) {
+	ev, ok := e.(*tightbeam.PrivmsgEvent)
+	if !ok || ev.IsAction || ev.From == nil {
+		return
+	}
+
+	casemap := c.ISupport().CaseMapping()
+	nick := c.CurrentNick()
+
+	if casemap.Equal(ev.From.Name, nick) {
+		return
+	}
+
+	text, ok := r.Parse(ev.Text, nick, casemap, !ev.IsChannel)
+	if !ok {
+		return
+	}
+
+	args, starts, err := tokenize(text)
+	if err != nil || len(args) == 0 {
+		return
+	}
+
+	cmd := r.Lookup(args[0])
+	if cmd == nil {
+		return
+	}
+
+	raw := make([]string, len(starts)-1)
+	for n := range raw {
+		raw[n] = s.TrimRight(text[starts[n+1]:], " \t")
+	}
+
+	ctx := &Context{
+		Client:  c,
+		Event:   ev,
+		Command: cmd,
+		Args:    args[1:],
+		router:  r,
+		casemap: casemap,
+		raw:     raw,
+	}
+
+	if err := r.run(ctx); err != nil {
+		r.fail(ctx, err)
+	}
+}
+
+func (r *Router) run(ctx *Context) error {
+	if ctx.Command.Permission != nil && !ctx.Command.Permission(ctx) {
+		return ErrorPermissionDenied
+	}
+
+	values, err := bind(ctx.Command.Params, ctx.Args, ctx.raw)
+	if err != nil {
+		return err
+	}
+	ctx.values = values
+
+	if ctx.Command.Run == nil {
+		return nil
+	}
+
+	return ctx.Command.Run(ctx)
+}
+
+func (r *Router) fail(ctx *Context, err error) {
+	if r.OnError != nil {
+		r.OnError(ctx, err)
+		return
+	}
+
+	var argErr *ArgumentError
+	switch {
+	case errors.Is(err, ErrorPermissionDenied):
+		ctx.ReplyPrivate("You are not allowed to use " + ctx.Command.Name)
+	case errors.As(err, &argErr), errors.Is(err, ErrorTooManyArguments):
+		ctx.Reply("Usage: " + ctx.Command.Usage(r.Prefix))
+	default:
+		logger := r.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		logger.Error("commands: command failed", "command", ctx.Command.Name, "from", ctx.From().String(), "error", err)
+		ctx.Reply("Command failed")
+	}
+}
+
+func (r *Router) help(ctx *Context) error {
+	if name := ctx.String("command"); name != "" {
+		cmd := r.Lookup(s.TrimPrefix(name, r.Prefix))
+		if cmd == nil || !ctx.Allowed(cmd) {
+			return ctx.ReplyPrivate("No such command: " + name)
+		}
+
+		lines := []string{"Usage: " + cmd.Usage(r.Prefix)}
+		if cmd.Help != "" {
+			lines = append(lines, cmd.Help)
+		}
+		if len(cmd.Aliases) > 0 {
+			lines = append(lines, "Aliases: "+s.Join(cmd.Aliases, ", "))
+		}
+
+		return ctx.ReplyPrivate(s.Join(lines, "\n"))
+	}
+
+	names := []string{}
+	for _, cmd := range r.Commands() {
+		if ctx.Allowed(cmd) {
+			names = append(names, r.Prefix+cmd.Name)
+		}
+	}
+
+	return ctx.ReplyPrivate("Commands: " + s.Join(names, " "))
+}
@@ -0,0 +1,124 @@
+package commands_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	s "strings"
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/commands"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+func TestRouter(t *testing.T) {
+	srv := tightbeamtest.NewServer(t)
+	logs := &bytes.Buffer{}
+
+	r := commands.NewRouter("!")
+	r.Logger = slog.New(slog.NewTextHandler(logs, nil))
+
+	r.Add(&commands.Command{
+		Name:    "say",
+		Aliases: []string{"echo"},
+		Params:  []commands.Param{{Name: "target"}, {Name: "text", Type: commands.Rest}},
+		Run: func(ctx *commands.Context) error {
+			return ctx.Reply(ctx.String("target") + ": " + ctx.String("text"))
+		},
+	})
+
+	r.Add(&commands.Command{
+		Name:       "shutdown",
+		Permission: commands.Masks("*!*@admin.example"),
+		Run: func(ctx *commands.Context) error {
+			return ctx.Reply("bye")
+		},
+	})
+
+	r.Add(&commands.Command{
+		Name: "db",
+		Run: func(ctx *commands.Context) error {
+			return errors.New("dial tcp 10.0.0.5:5432: connection refused")
+		},
+	})
+
+	if err := r.Add(&commands.Command{Name: "ECHO"}); err != commands.ErrorDuplicateCommand {
+		t.Fatalf("Add() duplicate alias = %v", err)
+	}
+
+	c := tightbeam.NewClient(srv.Conn(), tightbeam.ClientConfig{Nick: "bot", Handler: r})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	srv.Register("bot")
+
+	srv.Send(":a!u@h PRIVMSG #c :!say bob  hello   there  ")
+	srv.Expect("PRIVMSG #c :bob: hello   there")
+
+	srv.Send(":a!u@h PRIVMSG #c :bot, ECHO bob hi")
+	srv.Expect("PRIVMSG #c :bob: hi")
+
+	srv.Send(":a!u@h PRIVMSG bot :say bob psst")
+	srv.Expect("PRIVMSG a :bob: psst")
+
+	srv.Send(":a!u@h PRIVMSG #c :!say")
+	srv.Expect("PRIVMSG #c :Usage: !say <target> <text...>")
+
+	srv.Send(":a!u@h PRIVMSG #c :!shutdown")
+	srv.Expect("PRIVMSG a :You are not allowed to use shutdown")
+
+	srv.Send(":a!u@admin.example PRIVMSG #c :!shutdown")
+	srv.Expect("PRIVMSG #c bye")
+
+	srv.Send(":a!u@h PRIVMSG #c :!db")
+	srv.Expect("PRIVMSG #c :Command failed")
+
+	srv.Send(":a!u@h PRIVMSG bot :help")
+	srv.Expect("PRIVMSG a :Commands: !db !help !say")
+
+	srv.Send(":a!u@h PRIVMSG bot :help !say")
+	srv.Expect("PRIVMSG a :Usage: !say <target> <text...>")
+	srv.Expect("PRIVMSG a :Aliases: echo")
+
+	srv.Send(":a!u@h PRIVMSG #c :say bob hi")
+	srv.Send(":a!u@h PRIVMSG #c :!nope")
+	srv.Send(":a!u@h PRIVMSG #c :!say \"unterminated")
+	srv.Send(":bot!u@h PRIVMSG #c :!say bob hi")
+	srv.Send(":a!u@h PRIVMSG #c :\x01ACTION !say bob hi\x01")
+	srv.Send("PING :sync")
+	srv.Expect("PONG sync")
+
+	if line := logs.String(); !s.Contains(line, "connection refused") || !s.Contains(line, "command=db") {
+		t.Fatalf("log = %q", line)
+	}
+}
+
+func TestRouterOnError(t *testing.T) {
+	srv := tightbeamtest.NewServer(t)
+	failed := make(chan error, 1)
+
+	r := commands.NewRouter("!")
+	r.OnError = func(ctx *commands.Context, err error) {
+		failed <- err
+	}
+
+	r.Add(&commands.Command{Name: "n", Params: []commands.Param{{Name: "v", Type: commands.Int}}})
+
+	c := tightbeam.NewClient(srv.Conn(), tightbeam.ClientConfig{Nick: "bot", Handler: r})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	srv.Register("bot")
+	srv.Send(":a!u@h PRIVMSG #c :!n x")
+
+	var argErr *commands.ArgumentError
+	if err := <-failed; !errors.As(err, &argErr) || argErr.Value != "x" {
+		t.Fatalf("OnError got %v", err)
+	}
+}
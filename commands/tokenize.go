@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+)
+
+var ErrorUnterminatedQuote = errors.New("commands: Unterminated quote")
+
+func Tokenize(text string) ([]string, error) {
+	ret, _, err := tokenize(text)
+	return ret, err
+}
+
+func tokenize(text string) ([]string, []int, error) {
+	var ret []string
+	var starts []int
+
+	buf := &bytes.Buffer{}
+	inToken := false
+	var quote byte
+
+	for n := 0; n < len(text); n++ {
+		c := text[n]
+
+		switch {
+		case c == '\\' && n+1 < len(text) && quote != '\'':
+			if !inToken {
+				starts = append(starts, n)
+			}
+			n++
+			buf.WriteByte(text[n])
+			inToken = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			buf.WriteByte(c)
+		case c == '"' || c == '\'':
+			if !inToken {
+				starts = append(starts, n)
+			}
+			quote = c
+			inToken = true
+		case c == ' ' || c == '\t':
+			if inToken {
+				ret = append(ret, buf.String())
+				buf.Reset()
+				inToken = false
+			}
+		default:
+			if !inToken {
+				starts = append(starts, n)
+			}
+			buf.WriteByte(c)
+			inToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, nil, ErrorUnterminatedQuote
+	}
+
+	if inToken {
+		ret = append(ret, buf.String())
+	}
+
+	return ret, starts, nil
+}
+
+func Quote(v string) string {
+	if v == "" {
+		return `""`
+	}
+
+	needs := false
+	for n := 0; n < len(v); n++ {
+		switch v[n] {
+		case ' ', '\t', '"', '\'', '\\':
+			needs = true
+		}
+	}
+
+	if !needs {
+		return v
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte('"')
+	for n := 0; n < len(v); n++ {
+		if v[n] == '"' || v[n] == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(v[n])
+	}
+	buf.WriteByte('"')
+
+	return buf.String()
+}
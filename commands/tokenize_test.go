@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	for _, tt := range []struct {
+		text   string
+		want   []string
+		starts []int
+		err    error
+	}{
+		{"", nil, nil, nil},
+		{"   ", nil, nil, nil},
+		{"kick bob", []string{"kick", "bob"}, []int{0, 5}, nil},
+		{"  kick \t bob  ", []string{"kick", "bob"}, []int{2, 9}, nil},
+		{`say "hi  there" 'a b'`, []string{"say", "hi  there", "a b"}, []int{0, 4, 16}, nil},
+		{`a\ b c`, []string{"a b", "c"}, []int{0, 5}, nil},
+		{`\"x`, []string{`"x`}, []int{0}, nil},
+		{`"a\"b"`, []string{`a"b`}, []int{0}, nil},
+		{`'a\'`, []string{`a\`}, []int{0}, nil},
+		{`x "" y`, []string{"x", "", "y"}, []int{0, 2, 5}, nil},
+		{`pre"fix"ed`, []string{"prefixed"}, []int{0}, nil},
+		{`"open`, nil, nil, ErrorUnterminatedQuote},
+		{`'open`, nil, nil, ErrorUnterminatedQuote},
+	} {
+		got, starts, err := tokenize(tt.text)
+		if err != tt.err {
+			t.Errorf("tokenize(%q) error = %v, want %v", tt.text, err, tt.err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(starts, tt.starts) {
+			t.Errorf("tokenize(%q) = %q, %v, want %q, %v", tt.text, got, starts, tt.want, tt.starts)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, tt := range []struct {
+		v    string
+		want string
+	}{
+		{"plain", "plain"},
+		{"", `""`},
+		{"a b", `"a b"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`back\slash`, `"back\\slash"`},
+		{"it's", `"it's"`},
+	} {
+		got := Quote(tt.v)
+		if got != tt.want {
+			t.Errorf("Quote(%q) = %q, want %q", tt.v, got, tt.want)
+		}
+
+		if tokens, err := Tokenize(got); err != nil || len(tokens) != 1 || tokens[0] != tt.v {
+			t.Errorf("Tokenize(Quote(%q)) = %q, %v", tt.v, tokens, err)
+		}
+	}
+}
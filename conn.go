@@ -0,0 +1,92 @@
+package tightbeam
+
+import (
+	"bufio"
+	"io"
+	"net"
+	s "strings"
+)
+
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+func (r *Reader) ReadLine() (string, error) {
+	for {
+		line, err := r.r.ReadString('\n')
+		line = s.TrimRight(line, "\r\n")
+
+		if err != nil {
+			if err == io.EOF && line != "" {
+				return line, nil
+			}
+			return "", err
+		}
+
+		if line != "" {
+			return line, nil
+		}
+	}
+}
+
+func (r *Reader) ReadMessage() (*Message, error) {
+	line, err := r.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseMessage(line)
+}
+
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) WriteLine(line string) error {
+	_, err := io.WriteString(w.w, line+"\r\n")
+	return err
+}
+
+func (w *Writer) WriteMessage(m *Message) error {
+	return w.WriteLine(m.String())
+}
+
+type Transport interface {
+	ReadLine() (string, error)
+	WriteLine(line string) error
+	Close() error
+}
+
+type streamTransport struct {
+	*Reader
+	*Writer
+	rwc io.ReadWriteCloser
+}
+
+func NewStreamTransport(rwc io.ReadWriteCloser) Transport {
+	return &streamTransport{
+		Reader: NewReader(rwc),
+		Writer: NewWriter(rwc),
+		rwc:    rwc,
+	}
+}
+
+func (t *streamTransport) Close() error {
+	return t.rwc.Close()
+}
+
+func (t *streamTransport) RemoteAddr() net.Addr {
+	if nc, ok := t.rwc.(net.Conn); ok {
+		return nc.RemoteAddr()
+	}
+
+	return nil
+}
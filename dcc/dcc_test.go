@@ -0,0 +1,164 @@
+package dcc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	s "strings"
+	"testing"
+	"time"
+)
+
+func TestOfferPublicIP(t *testing.T) {
+	for _, bind := range []string{"", "0.0.0.0", "::"} {
+		cfg := &Config{BindIP: bind}
+		if _, _, err := cfg.Offer(context.Background(), TypeSend, "f", 1); err != ErrorNoPublicIP {
+			t.Fatalf("Offer() with BindIP %q = %v, want ErrorNoPublicIP", bind, err)
+		}
+	}
+
+	cfg := &Config{BindIP: "127.0.0.1", PublicIP: net.ParseIP("192.0.2.1")}
+	o, l, err := cfg.Offer(context.Background(), TypeSend, "f", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if !o.IP.Equal(cfg.PublicIP) {
+		t.Fatalf("Offer() IP = %s, want %s", o.IP, cfg.PublicIP)
+	}
+}
+
+func TestSendReceiveResume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data := []byte(s.Repeat("0123456789", 10000))
+	have := int64(12345)
+
+	sender := &Config{BindIP: "127.0.0.1"}
+	offer, l, err := sender.Offer(ctx, TypeSend, "file name.txt", int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received, err := ParseOffer(offer.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resume, err := ParseOffer(received.Resume(have).String())
+	if err != nil || resume.Type != TypeResume || resume.Port != offer.Port || resume.Position != have {
+		t.Fatalf("resume offer %+v, %v", resume, err)
+	}
+
+	accept, err := ParseOffer(resume.Accept().String())
+	if err != nil || accept.Type != TypeAccept || accept.Position != have {
+		t.Fatalf("accept offer %+v, %v", accept, err)
+	}
+
+	sent := make(chan error, 1)
+	go func() {
+		conn, err := sender.Accept(ctx, l)
+		if err != nil {
+			sent <- err
+			return
+		}
+
+		sent <- sender.Send(ctx, conn, bytes.NewReader(data), int64(len(data)), resume.Position)
+	}()
+
+	var last Progress
+	receiver := &Config{Progress: func(p Progress) { last = p }}
+
+	conn, err := receiver.Dial(ctx, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(append([]byte(nil), data[:have]...))
+	n, err := receiver.Receive(ctx, conn, buf, received.Size, accept.Position)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+
+	if n != int64(len(data))-have || !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("received %d bytes, data equal %v", n, bytes.Equal(buf.Bytes(), data))
+	}
+
+	if last.Transferred != int64(len(data)) || last.Size != int64(len(data)) {
+		t.Fatalf("last progress %+v", last)
+	}
+}
+
+func TestListenPortRange(t *testing.T) {
+	ctx := context.Background()
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	port := busy.Addr().(*net.TCPAddr).Port
+
+	for _, cfg := range []*Config{
+		{BindIP: "127.0.0.1", PortMin: port, PortMax: port},
+		{BindIP: "127.0.0.1", PortMin: port, PortMax: port - 1},
+	} {
+		if _, _, err := cfg.Listen(ctx); err != ErrorNoPorts {
+			t.Fatalf("Listen() on a busy range %d-%d = %v, want ErrorNoPorts", cfg.PortMin, cfg.PortMax, err)
+		}
+	}
+
+	cfg := &Config{BindIP: "127.0.0.1", PortMin: port, PortMax: port + 10}
+	l, got, err := cfg.Listen(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if got <= port || got > port+10 || l.Addr().(*net.TCPAddr).Port != got {
+		t.Fatalf("Listen() port = %d, listener %s, want in %d-%d", got, l.Addr(), port+1, port+10)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{Timeout: 50 * time.Millisecond}
+	data := []byte(s.Repeat("x", 3*bufferSize))
+
+	a, b := net.Pipe()
+	if err := cfg.Send(ctx, a, bytes.NewReader(data), int64(len(data)), 0); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Send() to a peer that does not read = %v", err)
+	}
+	b.Close()
+
+	a, b = net.Pipe()
+	go io.Copy(io.Discard, b)
+	if err := cfg.Send(ctx, a, bytes.NewReader(data), int64(len(data)), 0); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Send() to a peer that does not ack = %v", err)
+	}
+
+	a, b = net.Pipe()
+	if _, err := cfg.Receive(ctx, a, io.Discard, 10, 0); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Receive() from a peer that does not send = %v", err)
+	}
+	b.Close()
+
+	a, b = net.Pipe()
+	go b.Write([]byte("abc"))
+	n, err := cfg.Receive(ctx, a, io.Discard, 10, 0)
+	if n != 3 || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Receive() from a peer that does not read acks = %d, %v", n, err)
+	}
+	b.Close()
+}
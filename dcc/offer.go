@@ -0,0 +1,232 @@
+package dcc
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	s "strings"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+const (
+	TypeSend   = "SEND"
+	TypeChat   = "CHAT"
+	TypeResume = "RESUME"
+	TypeAccept = "ACCEPT"
+)
+
+var (
+	ErrorNotDCC = errors.New("dcc: Not a DCC request")
+
+	ErrorBadOffer = errors.New("dcc: Malformed DCC offer")
+
+	ErrorUnknownType = errors.New("dcc: Unknown DCC type")
+)
+
+type Offer struct {
+	Type     string
+	Filename string
+	IP       net.IP
+	Port     int
+	Size     int64
+	Position int64
+	Token    string
+}
+
+func Decode(m *tightbeam.Message) (*Offer, error) {
+	if m.Command != "PRIVMSG" || len(m.Params) < 2 {
+		return nil, ErrorNotDCC
+	}
+
+	cmd, args, ok := tightbeam.ParseCTCP(m.Params[1])
+	if !ok || cmd != "DCC" {
+		return nil, ErrorNotDCC
+	}
+
+	return ParseOffer(args)
+}
+
+func ParseOffer(v string) (*Offer, error) {
+	typ, rest, _ := s.Cut(v, " ")
+	o := &Offer{Type: s.ToUpper(typ), Size: -1}
+
+	name, rest, err := splitFilename(rest)
+	if err != nil {
+		return nil, err
+	}
+	o.Filename = name
+
+	args := s.Fields(rest)
+
+	switch o.Type {
+	case TypeSend, TypeChat:
+		if len(args) < 2 {
+			return nil, ErrorBadOffer
+		}
+
+		if o.IP, err = parseIP(args[0]); err != nil {
+			return nil, err
+		}
+
+		if o.Port, err = parsePort(args[1]); err != nil {
+			return nil, err
+		}
+
+		args = args[2:]
+
+		if o.Type == TypeSend && len(args) > 0 {
+			if o.Size, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+				return nil, ErrorBadOffer
+			}
+			args = args[1:]
+		}
+	case TypeResume, TypeAccept:
+		if len(args) < 2 {
+			return nil, ErrorBadOffer
+		}
+
+		if o.Port, err = parsePort(args[0]); err != nil {
+			return nil, err
+		}
+
+		if o.Position, err = strconv.ParseInt(args[1], 10, 64); err != nil || o.Position < 0 {
+			return nil, ErrorBadOffer
+		}
+
+		args = args[2:]
+	default:
+		return nil, ErrorUnknownType
+	}
+
+	if len(args) > 0 {
+		o.Token = args[0]
+	}
+
+	return o, nil
+}
+
+func splitFilename(v string) (string, string, error) {
+	v = s.TrimLeft(v, " ")
+
+	if s.HasPrefix(v, `"`) {
+		end := s.IndexByte(v[1:], '"')
+		if end < 0 {
+			return "", "", ErrorBadOffer
+		}
+
+		return v[1 : end+1], v[end+2:], nil
+	}
+
+	name, rest, _ := s.Cut(v, " ")
+	if name == "" {
+		return "", "", ErrorBadOffer
+	}
+
+	return name, rest, nil
+}
+
+func parseIP(v string) (net.IP, error) {
+	if n, err := strconv.ParseUint(v, 10, 32); err == nil {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, uint32(n))
+		return ip, nil
+	}
+
+	ip := net.ParseIP(v)
+	if ip == nil {
+		return nil, ErrorBadOffer
+	}
+
+	return ip, nil
+}
+
+func formatIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return strconv.FormatUint(uint64(binary.BigEndian.Uint32(ip4)), 10)
+	}
+
+	if ip == nil {
+		return "0"
+	}
+
+	return ip.String()
+}
+
+func parsePort(v string) (int, error) {
+	port, err := strconv.Atoi(v)
+	if err != nil || port < 0 || port > 65535 {
+		return 0, ErrorBadOffer
+	}
+
+	return port, nil
+}
+
+func (o *Offer) Passive() bool {
+	return o.Port == 0 && o.Token != ""
+}
+
+func (o *Offer) Addr() string {
+	return net.JoinHostPort(o.IP.String(), strconv.Itoa(o.Port))
+}
+
+func (o *Offer) String() string {
+	name := o.Filename
+	if name == "" && o.Type == TypeChat {
+		name = "chat"
+	}
+	if s.ContainsAny(name, " \"") {
+		name = `"` + s.ReplaceAll(name, `"`, "'") + `"`
+	}
+
+	parts := []string{o.Type, name}
+
+	switch o.Type {
+	case TypeResume, TypeAccept:
+		parts = append(parts, strconv.Itoa(o.Port), strconv.FormatInt(o.Position, 10))
+	default:
+		parts = append(parts, formatIP(o.IP), strconv.Itoa(o.Port))
+		if o.Type == TypeSend && (o.Size >= 0 || o.Token != "") {
+			size := o.Size
+			if size < 0 {
+				size = 0
+			}
+			parts = append(parts, strconv.FormatInt(size, 10))
+		}
+	}
+
+	if o.Token != "" {
+		parts = append(parts, o.Token)
+	}
+
+	return s.Join(parts, " ")
+}
+
+func (o *Offer) Message(target string) (*tightbeam.Message, error) {
+	return tightbeam.Privmsg(target, tightbeam.CTCP("DCC", o.String()))
+}
+
+func (o *Offer) Resume(position int64) *Offer {
+	return &Offer{
+		Type:     TypeResume,
+		Filename: o.Filename,
+		Port:     o.Port,
+		Position: position,
+		Token:    o.Token,
+	}
+}
+
+func (o *Offer) Accept() *Offer {
+	ret := *o
+	ret.Type = TypeAccept
+
+	return &ret
+}
+
+func (o *Offer) Reply(ip net.IP, port int) *Offer {
+	ret := *o
+	ret.IP, ret.Port = ip, port
+
+	return &ret
+}
@@ -0,0 +1,136 @@
+package dcc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+func TestParseOffer(t *testing.T) {
+	for _, tt := range []struct {
+		v    string
+		want Offer
+		str  string
+	}{
+		{"SEND file.txt 3232235777 5000 1024", Offer{Type: TypeSend, Filename: "file.txt", IP: net.ParseIP("192.168.1.1"), Port: 5000, Size: 1024}, ""},
+		{`SEND "my file.txt" 3232235777 5000 1024`, Offer{Type: TypeSend, Filename: "my file.txt", IP: net.ParseIP("192.168.1.1"), Port: 5000, Size: 1024}, ""},
+		{"SEND file.txt 2001:db8::1 5000 1024", Offer{Type: TypeSend, Filename: "file.txt", IP: net.ParseIP("2001:db8::1"), Port: 5000, Size: 1024}, ""},
+		{"SEND file.txt 192.168.1.1 5000 1024", Offer{Type: TypeSend, Filename: "file.txt", IP: net.ParseIP("192.168.1.1"), Port: 5000, Size: 1024}, "SEND file.txt 3232235777 5000 1024"},
+		{"SEND file.txt 3232235777 0 1024 42", Offer{Type: TypeSend, Filename: "file.txt", IP: net.ParseIP("192.168.1.1"), Size: 1024, Token: "42"}, ""},
+		{"SEND file.txt 3232235777 5000", Offer{Type: TypeSend, Filename: "file.txt", IP: net.ParseIP("192.168.1.1"), Port: 5000, Size: -1}, ""},
+		{"send  file.txt  3232235777  5000  1024", Offer{Type: TypeSend, Filename: "file.txt", IP: net.ParseIP("192.168.1.1"), Port: 5000, Size: 1024}, "SEND file.txt 3232235777 5000 1024"},
+		{"CHAT chat 2130706433 4000", Offer{Type: TypeChat, Filename: "chat", IP: net.ParseIP("127.0.0.1"), Port: 4000, Size: -1}, ""},
+		{"CHAT chat ::1 0 7", Offer{Type: TypeChat, Filename: "chat", IP: net.ParseIP("::1"), Size: -1, Token: "7"}, ""},
+		{"RESUME file.txt 5000 512", Offer{Type: TypeResume, Filename: "file.txt", Port: 5000, Size: -1, Position: 512}, ""},
+		{"ACCEPT file.txt 0 512 42", Offer{Type: TypeAccept, Filename: "file.txt", Size: -1, Position: 512, Token: "42"}, ""},
+	} {
+		o, err := ParseOffer(tt.v)
+		if err != nil {
+			t.Errorf("ParseOffer(%q): %v", tt.v, err)
+			continue
+		}
+
+		if o.Type != tt.want.Type || o.Filename != tt.want.Filename || !o.IP.Equal(tt.want.IP) || o.Port != tt.want.Port || o.Size != tt.want.Size || o.Position != tt.want.Position || o.Token != tt.want.Token {
+			t.Errorf("ParseOffer(%q) = %+v, want %+v", tt.v, o, tt.want)
+		}
+
+		str := tt.str
+		if str == "" {
+			str = tt.v
+		}
+
+		if got := o.String(); got != str {
+			t.Errorf("ParseOffer(%q).String() = %q, want %q", tt.v, got, str)
+		}
+	}
+}
+
+func TestParseOfferErrors(t *testing.T) {
+	for _, tt := range []struct {
+		v   string
+		err error
+	}{
+		{"", ErrorBadOffer},
+		{"FOO x 1 2", ErrorUnknownType},
+		{"SEND", ErrorBadOffer},
+		{"SEND x", ErrorBadOffer},
+		{"SEND x 1", ErrorBadOffer},
+		{`SEND "open 1 2`, ErrorBadOffer},
+		{"SEND x nope 2", ErrorBadOffer},
+		{"SEND x 1 70000", ErrorBadOffer},
+		{"SEND x 1 -1", ErrorBadOffer},
+		{"SEND x 1 2 big", ErrorBadOffer},
+		{"RESUME x 2", ErrorBadOffer},
+		{"RESUME x 2 -5", ErrorBadOffer},
+		{"ACCEPT x port 5", ErrorBadOffer},
+	} {
+		if _, err := ParseOffer(tt.v); err != tt.err {
+			t.Errorf("ParseOffer(%q) = %v, want %v", tt.v, err, tt.err)
+		}
+	}
+}
+
+func TestOfferString(t *testing.T) {
+	for _, tt := range []struct {
+		o    *Offer
+		want string
+	}{
+		{&Offer{Type: TypeSend, Filename: `a "b" c`, IP: net.ParseIP("10.0.0.1"), Port: 1, Size: 2}, `SEND "a 'b' c" 167772161 1 2`},
+		{&Offer{Type: TypeChat, IP: net.ParseIP("10.0.0.1"), Port: 1, Size: -1}, "CHAT chat 167772161 1"},
+		{&Offer{Type: TypeSend, Filename: "f", Size: -1, Token: "t"}, "SEND f 0 0 0 t"},
+		{&Offer{Type: TypeSend, Filename: "f", IP: net.ParseIP("2001:db8::2"), Port: 9, Size: 3}, "SEND f 2001:db8::2 9 3"},
+	} {
+		if got := tt.o.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestPassiveReply(t *testing.T) {
+	o, err := ParseOffer("SEND f 3232235777 0 10 tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !o.Passive() {
+		t.Fatal("Passive() = false for port 0 with a token")
+	}
+
+	reply := o.Reply(net.ParseIP("2001:db8::1"), 5000)
+	if reply.Passive() || reply.String() != "SEND f 2001:db8::1 5000 10 tok" || reply.Addr() != "[2001:db8::1]:5000" {
+		t.Fatalf("Reply() = %q, %q", reply, reply.Addr())
+	}
+
+	if resume := o.Resume(4).String(); resume != "RESUME f 0 4 tok" {
+		t.Fatalf("Resume() = %q", resume)
+	}
+
+	if (&Offer{}).Passive() || (&Offer{Port: 1, Token: "t"}).Passive() {
+		t.Fatal("Passive() without a token or with a port")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	o := &Offer{Type: TypeSend, Filename: "my file.txt", IP: net.ParseIP("192.168.1.1"), Port: 5000, Size: 1024}
+	m, err := o.Message("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Decode(tightbeam.MustParseMessage(m.String()))
+	if err != nil || got.String() != o.String() {
+		t.Fatalf("Decode(%q) = %v, %v", m, got, err)
+	}
+
+	for _, line := range []string{
+		"PRIVMSG bob :hello",
+		"PRIVMSG bob :\x01VERSION\x01",
+		"NOTICE bob :\x01DCC SEND f 1 2 3\x01",
+		"PRIVMSG bob",
+	} {
+		if _, err := Decode(tightbeam.MustParseMessage(line)); err != ErrorNotDCC {
+			t.Errorf("Decode(%q) = %v, want ErrorNotDCC", line, err)
+		}
+	}
+}
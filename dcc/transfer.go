@@ -0,0 +1,297 @@
+package dcc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+var (
+	ErrorNoPorts = errors.New("dcc: No free port in range")
+
+	ErrorShortTransfer = errors.New("dcc: Transfer ended before expected size")
+
+	ErrorTooLarge = errors.New("dcc: Transfer larger than expected size")
+
+	ErrorBadPosition = errors.New("dcc: Resume position beyond file size")
+
+	ErrorNoPublicIP = errors.New("dcc: No public IP to advertise")
+)
+
+const bufferSize = 16 * 1024
+
+type Progress struct {
+	Transferred int64
+	Size        int64
+}
+
+type Config struct {
+	BindIP   string
+	PublicIP net.IP
+
+	PortMin int
+	PortMax int
+
+	Timeout  time.Duration
+	MaxSize  int64
+	Progress func(p Progress)
+}
+
+func (c *Config) Listen(ctx context.Context) (net.Listener, int, error) {
+	lc := net.ListenConfig{}
+
+	if c.PortMin == 0 && c.PortMax == 0 {
+		l, err := lc.Listen(ctx, "tcp", net.JoinHostPort(c.BindIP, "0"))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return l, l.Addr().(*net.TCPAddr).Port, nil
+	}
+
+	max := c.PortMax
+	if max < c.PortMin {
+		max = c.PortMin
+	}
+
+	for port := c.PortMin; port <= max; port++ {
+		l, err := lc.Listen(ctx, "tcp", net.JoinHostPort(c.BindIP, strconv.Itoa(port)))
+		if err == nil {
+			return l, port, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+	}
+
+	return nil, 0, ErrorNoPorts
+}
+
+func (c *Config) Offer(ctx context.Context, typ string, filename string, size int64) (*Offer, net.Listener, error) {
+	if bind := net.ParseIP(c.BindIP); c.PublicIP == nil && (c.BindIP == "" || bind != nil && bind.IsUnspecified()) {
+		return nil, nil, ErrorNoPublicIP
+	}
+
+	l, port, err := c.Listen(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ip := c.PublicIP
+	if ip == nil {
+		ip = l.Addr().(*net.TCPAddr).IP
+	}
+
+	return &Offer{Type: typ, Filename: filename, IP: ip, Port: port, Size: size}, l, nil
+}
+
+func (c *Config) Accept(ctx context.Context, l net.Listener) (net.Conn, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.Close()
+		case <-done:
+		}
+	}()
+
+	conn, err := l.Accept()
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return conn, err
+}
+
+func (c *Config) Dial(ctx context.Context, o *Offer) (net.Conn, error) {
+	d := net.Dialer{Timeout: c.Timeout}
+	return d.DialContext(ctx, "tcp", o.Addr())
+}
+
+func Chat(conn net.Conn) tightbeam.Transport {
+	return tightbeam.NewStreamTransport(conn)
+}
+
+func (c *Config) Send(ctx context.Context, conn net.Conn, r io.ReadSeeker, size int64, position int64) error {
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	if position > size {
+		return ErrorBadPosition
+	}
+
+	if _, err := r.Seek(position, io.SeekStart); err != nil {
+		return err
+	}
+
+	acks := make(chan error, 1)
+	go func() {
+		acks <- c.readAcks(conn, size)
+	}()
+
+	buf := make([]byte, bufferSize)
+	sent := position
+
+	for sent < size {
+		n, err := r.Read(buf[:min64(int64(len(buf)), size-sent)])
+		if n > 0 {
+			conn.SetWriteDeadline(c.deadline())
+			if _, werr := conn.Write(buf[:n]); werr != nil {
+				return ctxErr(ctx, werr)
+			}
+
+			sent += int64(n)
+			c.progress(sent, size)
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if sent < size {
+		return ErrorShortTransfer
+	}
+
+	select {
+	case err := <-acks:
+		return ctxErr(ctx, err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Config) readAcks(conn net.Conn, size int64) error {
+	buf := make([]byte, 4)
+
+	for {
+		conn.SetReadDeadline(c.deadline())
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		if binary.BigEndian.Uint32(buf) == uint32(size) {
+			return nil
+		}
+	}
+}
+
+func (c *Config) Receive(ctx context.Context, conn net.Conn, w io.Writer, size int64, position int64) (int64, error) {
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	if c.MaxSize > 0 && size > c.MaxSize {
+		return 0, ErrorTooLarge
+	}
+
+	buf := make([]byte, bufferSize)
+	ack := make([]byte, 4)
+	received := position
+
+	for size < 0 || received < size {
+		conn.SetReadDeadline(c.deadline())
+		n, err := conn.Read(buf)
+		if n > 0 {
+			received += int64(n)
+
+			if size >= 0 && received > size || c.MaxSize > 0 && received > c.MaxSize {
+				return received - position, ErrorTooLarge
+			}
+
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return received - position, werr
+			}
+
+			binary.BigEndian.PutUint32(ack, uint32(received))
+			conn.SetWriteDeadline(c.deadline())
+			if _, werr := conn.Write(ack); werr != nil {
+				return received - position, ctxErr(ctx, werr)
+			}
+
+			c.progress(received, size)
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return received - position, ctxErr(ctx, err)
+		}
+	}
+
+	if size >= 0 && received < size {
+		return received - position, ErrorShortTransfer
+	}
+
+	return received - position, nil
+}
+
+func (c *Config) deadline() time.Time {
+	if c.Timeout <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(c.Timeout)
+}
+
+func (c *Config) progress(transferred, size int64) {
+	if c.Progress != nil {
+		c.Progress(Progress{Transferred: transferred, Size: size})
+	}
+}
+
+func closeOnDone(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
+
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -3,6 +3,7 @@ package tightbeam
 import (
 	"bytes"
 	"errors"
+	"sort"
 	s "strings"
 )
 
@@ -37,27 +38,23 @@ type TagVal string
 func ParseTagVal(v string) TagVal {
 	ret := &bytes.Buffer{}
 
-	input := bytes.NewBufferString(v)
+	for i := 0; i < len(v); i++ {
+		c := v[i]
 
-	for {
-		c, _, err := input.ReadRune()
-		if err != nil {
+		if c != '\\' {
+			ret.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i >= len(v) {
 			break
 		}
 
-		if c == '\\' {
-			c2, _, err := input.ReadRune()
-			if err != nil {
-				break
-			}
-			if rep, ok := tagEscapeDecodeMap[c2]; ok {
-				ret.WriteRune(rep)
-			} else {
-				ret.WriteRune(c2)
-			}
+		if rep, ok := tagEscapeDecodeMap[rune(v[i])]; ok {
+			ret.WriteRune(rep)
 		} else {
-			ret.WriteRune(c)
-
+			ret.WriteByte(v[i])
 		}
 	}
 
@@ -67,11 +64,11 @@ func ParseTagVal(v string) TagVal {
 func (v TagVal) Encode() string {
 	ret := &bytes.Buffer{}
 
-	for _, c := range v {
-		if rep, ok := tagEscapeEncodeMap[c]; ok {
+	for i := 0; i < len(v); i++ {
+		if rep, ok := tagEscapeEncodeMap[rune(v[i])]; ok {
 			ret.WriteString(rep)
 		} else {
-			ret.WriteRune(c)
+			ret.WriteByte(v[i])
 		}
 	}
 
@@ -86,7 +83,11 @@ func ParseTags(line string) Tags {
 	tags := s.Split(line, ";")
 	for _, tag := range tags {
 		parts := s.SplitN(tag, "=", 2)
-		if len(parts) > 2 {
+		if parts[0] == "" {
+			continue
+		}
+
+		if len(parts) < 2 {
 			ret[parts[0]] = ""
 			continue
 		}
@@ -112,10 +113,37 @@ func (t Tags) Copy() Tags {
 	return ret
 }
 
+func (t Tags) Keys() []string {
+	ret := make([]string, 0, len(t))
+
+	for k := range t {
+		ret = append(ret, k)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+func (t Tags) Equal(o Tags) bool {
+	if len(t) != len(o) {
+		return false
+	}
+
+	for k, v := range t {
+		if ov, ok := o[k]; !ok || ov != v {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (t Tags) String() string {
 	buf := &bytes.Buffer{}
 
-	for k, v := range t {
+	for _, k := range t.Keys() {
+		v := t[k]
 		buf.WriteByte(';')
 		buf.WriteString(k)
 		if v != "" {
@@ -165,6 +193,14 @@ func (p *Prefix) Copy() *Prefix {
 	return newPrefix
 }
 
+func (p *Prefix) Equal(o *Prefix) bool {
+	if p == nil || p.Name == "" || o == nil || o.Name == "" {
+		return (p == nil || p.Name == "") && (o == nil || o.Name == "")
+	}
+
+	return *p == *o
+}
+
 func (p *Prefix) String() string {
 	buf := &bytes.Buffer{}
 
@@ -216,7 +252,10 @@ func ParseMessage(line string) (*Message, error) {
 		}
 
 		c.Tags = ParseTags(split[0][1:])
-		line = split[1]
+		line = s.TrimLeft(split[1], " ")
+		if len(line) == 0 {
+			return nil, ErrorNoDataAfterTags
+		}
 	}
 
 	if line[0] == ':' {
@@ -226,7 +265,10 @@ func ParseMessage(line string) (*Message, error) {
 		}
 
 		c.Prefix = ParsePrefix(split[0][1:])
-		line = split[1]
+		line = s.TrimLeft(split[1], " ")
+		if len(line) == 0 {
+			return nil, ErrorNothingAfterPrefix
+		}
 	}
 
 	split := s.SplitN(line, " :", 2)
@@ -278,6 +320,40 @@ func (m *Message) Copy() *Message {
 	return newMessage
 }
 
+func (m *Message) Canonical() *Message {
+	ret := m.Copy()
+
+	ret.Command = s.ToUpper(ret.Command)
+
+	if len(ret.Tags) == 0 {
+		ret.Tags = nil
+	}
+
+	if ret.Prefix != nil && ret.Prefix.Name == "" {
+		ret.Prefix = nil
+	}
+
+	return ret
+}
+
+func (m *Message) Equal(o *Message) bool {
+	if m == nil || o == nil {
+		return m == o
+	}
+
+	if s.ToUpper(m.Command) != s.ToUpper(o.Command) || len(m.Params) != len(o.Params) {
+		return false
+	}
+
+	for n := range m.Params {
+		if m.Params[n] != o.Params[n] {
+			return false
+		}
+	}
+
+	return m.Tags.Equal(o.Tags) && m.Prefix.Equal(o.Prefix)
+}
+
 func (m *Message) String() string {
 	buf := bytes.Buffer{}
 
@@ -0,0 +1,66 @@
+package tightbeam_test
+
+import (
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+func FuzzParseMessage(f *testing.F) {
+	for _, seed := range []string{
+		"PING",
+		"CMD :",
+		":nick!user@host PRIVMSG #chan :hello world",
+		"@time=2020-01-01T00:00:00Z;+draft/react=\\:\\s :a!b@c TAGMSG #x",
+		"@a;;b=\\ CMD",
+		":srv 005 me A=1 B :are supported",
+		"cmd  a   b  :c  d",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		m, err := tightbeam.ParseMessage(line)
+		if err != nil || m.Validate() != nil {
+			return
+		}
+
+		out := m.String()
+
+		again, err := tightbeam.ParseMessage(out)
+		if err != nil {
+			t.Fatalf("%q serialized to unparseable %q: %v", line, out, err)
+		}
+
+		if !m.Equal(again) {
+			t.Fatalf("%q serialized to %q which parses differently", line, out)
+		}
+
+		if again.String() != out {
+			t.Fatalf("%q serialized to %q then %q", line, out, again.String())
+		}
+	})
+}
+
+func FuzzTagVal(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"a=b;c;d=\\s\\:\\\\",
+		"trailing\\",
+		"a=1;a=2;=x",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, v string) {
+		tv := tightbeam.TagVal(v)
+		if got := tightbeam.ParseTagVal(tv.Encode()); got != tv {
+			t.Fatalf("%q encoded to %q and parsed back as %q", v, tv.Encode(), got)
+		}
+
+		tags := tightbeam.ParseTags(v)
+		if !tightbeam.ParseTags(tags.String()).Equal(tags) {
+			t.Fatalf("%q parsed to tags that serialize to %q", v, tags.String())
+		}
+	})
+}
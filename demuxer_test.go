@@ -0,0 +1,78 @@
+package tightbeam_test
+
+import (
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+func TestTagsString(t *testing.T) {
+	for _, tt := range []struct {
+		tags tightbeam.Tags
+		want string
+	}{
+		{nil, ""},
+		{tightbeam.Tags{"b": "2", "a": "1", "c": ""}, "a=1;b=2;c"},
+		{tightbeam.Tags{"+z": "x y", "time": "t;", "+a": "\\"}, "+a=\\\\;+z=x\\sy;time=t\\:"},
+	} {
+		for n := 0; n < 5; n++ {
+			if got := tt.tags.String(); got != tt.want {
+				t.Fatalf("%v.String() = %q, want %q", tt.tags, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestMessageCanonical(t *testing.T) {
+	for _, tt := range []struct {
+		m    *tightbeam.Message
+		want string
+	}{
+		{tightbeam.MustParseMessage("privmsg #c :hi there"), "PRIVMSG #c :hi there"},
+		{tightbeam.MustParseMessage("@b=2;a=1 :n!u@h notice #c x"), "@a=1;b=2 :n!u@h NOTICE #c x"},
+		{&tightbeam.Message{Tags: tightbeam.Tags{}, Prefix: &tightbeam.Prefix{}, Command: "ping", Params: []string{"x"}}, "PING x"},
+	} {
+		c := tt.m.Canonical()
+
+		if got := c.String(); got != tt.want {
+			t.Errorf("Canonical() = %q, want %q", got, tt.want)
+		}
+
+		if c.Tags != nil && len(c.Tags) == 0 || c.Prefix != nil && c.Prefix.Name == "" {
+			t.Errorf("Canonical() of %q kept empty tags or prefix", tt.want)
+		}
+
+		if !c.Equal(tt.m) {
+			t.Errorf("Canonical() of %q is not Equal to the original", tt.want)
+		}
+	}
+}
+
+func TestMessageEqual(t *testing.T) {
+	for _, tt := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{"PRIVMSG #c :hi", "privmsg #c hi", true},
+		{"@a=1;b=2 PRIVMSG #c hi", "@b=2;a=1 PRIVMSG #c hi", true},
+		{":n!u@h PRIVMSG #c hi", ":n!u@h PRIVMSG #c hi", true},
+		{":n!u@h PRIVMSG #c hi", ":n!u@other PRIVMSG #c hi", false},
+		{":n!u@h PRIVMSG #c hi", "PRIVMSG #c hi", false},
+		{"PRIVMSG #c hi", "PRIVMSG #C hi", false},
+		{"PRIVMSG #c hi", "PRIVMSG #c hi x", false},
+		{"@a=1 PRIVMSG #c hi", "PRIVMSG #c hi", false},
+		{"@a=1 PRIVMSG #c hi", "@a=2 PRIVMSG #c hi", false},
+		{"@a PRIVMSG #c hi", "@a= PRIVMSG #c hi", true},
+	} {
+		a, b := tightbeam.MustParseMessage(tt.a), tightbeam.MustParseMessage(tt.b)
+
+		if a.Equal(b) != tt.equal || b.Equal(a) != tt.equal {
+			t.Errorf("%q.Equal(%q) = %v, want %v", tt.a, tt.b, a.Equal(b), tt.equal)
+		}
+	}
+
+	var nilMessage *tightbeam.Message
+	if !nilMessage.Equal(nil) || nilMessage.Equal(tightbeam.MustParseMessage("PING")) {
+		t.Error("nil Message Equal")
+	}
+}
@@ -0,0 +1,52 @@
+package tightbeam
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+)
+
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func DialConn(ctx context.Context, addr string, dialer Dialer, tlsConfig *tls.Config) (net.Conn, error) {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig == nil {
+		return conn, nil
+	}
+
+	config := tlsConfig.Clone()
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		config.ServerName = host
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+func Dial(ctx context.Context, addr string, config ClientConfig) (*Client, error) {
+	conn, err := DialConn(ctx, addr, config.Dialer, config.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(conn, config), nil
+}
@@ -0,0 +1,485 @@
+package tightbeam
+
+import (
+	s "strings"
+	"sync"
+)
+
+type Event interface {
+	Raw() *Message
+}
+
+type PrivmsgEvent struct {
+	*Message
+	From      *Prefix
+	Target    string
+	Text      string
+	IsAction  bool
+	IsChannel bool
+}
+
+type NoticeEvent struct {
+	*Message
+	From      *Prefix
+	Target    string
+	Text      string
+	IsChannel bool
+}
+
+type CTCPEvent struct {
+	*Message
+	From      *Prefix
+	Target    string
+	Type      string
+	Args      string
+	IsReply   bool
+	IsChannel bool
+}
+
+type TagmsgEvent struct {
+	*Message
+	From      *Prefix
+	Target    string
+	IsChannel bool
+}
+
+type JoinEvent struct {
+	*Message
+	From     *Prefix
+	Channel  string
+	Account  string
+	Realname string
+}
+
+type PartEvent struct {
+	*Message
+	From    *Prefix
+	Channel string
+	Reason  string
+}
+
+type KickEvent struct {
+	*Message
+	From    *Prefix
+	Channel string
+	Nick    string
+	Reason  string
+}
+
+type QuitEvent struct {
+	*Message
+	From   *Prefix
+	Reason string
+}
+
+type NickEvent struct {
+	*Message
+	From *Prefix
+	Old  string
+	New  string
+}
+
+type ModeEvent struct {
+	*Message
+	From      *Prefix
+	Target    string
+	IsChannel bool
+	Changes   []ModeChange
+}
+
+type TopicEvent struct {
+	*Message
+	From    *Prefix
+	Channel string
+	Topic   string
+}
+
+type InviteEvent struct {
+	*Message
+	From    *Prefix
+	Nick    string
+	Channel string
+}
+
+type AwayEvent struct {
+	*Message
+	From   *Prefix
+	Away   bool
+	Reason string
+}
+
+type AccountEvent struct {
+	*Message
+	From    *Prefix
+	Account string
+}
+
+type ChgHostEvent struct {
+	*Message
+	From    *Prefix
+	NewUser string
+	NewHost string
+}
+
+type PingEvent struct {
+	*Message
+	Token string
+}
+
+type ErrorEvent struct {
+	*Message
+	Reason string
+}
+
+type NumericEvent struct {
+	*Message
+	From   *Prefix
+	Code   string
+	Target string
+	Args   []string
+}
+
+type UnknownEvent struct {
+	*Message
+}
+
+func (e *PrivmsgEvent) Raw() *Message { return e.Message }
+func (e *NoticeEvent) Raw() *Message  { return e.Message }
+func (e *CTCPEvent) Raw() *Message    { return e.Message }
+func (e *TagmsgEvent) Raw() *Message  { return e.Message }
+func (e *JoinEvent) Raw() *Message    { return e.Message }
+func (e *PartEvent) Raw() *Message    { return e.Message }
+func (e *KickEvent) Raw() *Message    { return e.Message }
+func (e *QuitEvent) Raw() *Message    { return e.Message }
+func (e *NickEvent) Raw() *Message    { return e.Message }
+func (e *ModeEvent) Raw() *Message    { return e.Message }
+func (e *TopicEvent) Raw() *Message   { return e.Message }
+func (e *InviteEvent) Raw() *Message  { return e.Message }
+func (e *AwayEvent) Raw() *Message    { return e.Message }
+func (e *AccountEvent) Raw() *Message { return e.Message }
+func (e *ChgHostEvent) Raw() *Message { return e.Message }
+func (e *PingEvent) Raw() *Message    { return e.Message }
+func (e *ErrorEvent) Raw() *Message   { return e.Message }
+func (e *NumericEvent) Raw() *Message { return e.Message }
+func (e *UnknownEvent) Raw() *Message { return e.Message }
+
+func DecodeEvent(m *Message) Event {
+	return ISupport{}.DecodeEvent(m)
+}
+
+func (i ISupport) DecodeEvent(m *Message) Event {
+	from := m.Prefix
+	if from != nil && from.Name == "" {
+		from = nil
+	}
+
+	param := func(n int) string {
+		if n < len(m.Params) {
+			return m.Params[n]
+		}
+		return ""
+	}
+
+	switch m.Command {
+	case "PRIVMSG", "NOTICE":
+		if len(m.Params) < 2 {
+			break
+		}
+
+		target, text := m.Params[0], m.Params[1]
+		isChannel := i.IsChannel(target)
+
+		if cmd, args, ok := ParseCTCP(text); ok {
+			if cmd == "ACTION" && m.Command == "PRIVMSG" {
+				return &PrivmsgEvent{m, from, target, args, true, isChannel}
+			}
+
+			return &CTCPEvent{m, from, target, cmd, args, m.Command == "NOTICE", isChannel}
+		}
+
+		if m.Command == "NOTICE" {
+			return &NoticeEvent{m, from, target, text, isChannel}
+		}
+
+		return &PrivmsgEvent{m, from, target, text, false, isChannel}
+	case "TAGMSG":
+		if len(m.Params) < 1 {
+			break
+		}
+
+		return &TagmsgEvent{m, from, m.Params[0], i.IsChannel(m.Params[0])}
+	case "JOIN":
+		if len(m.Params) < 1 {
+			break
+		}
+
+		account := param(1)
+		if account == "*" {
+			account = ""
+		}
+
+		return &JoinEvent{m, from, m.Params[0], account, param(2)}
+	case "PART":
+		if len(m.Params) < 1 {
+			break
+		}
+
+		return &PartEvent{m, from, m.Params[0], param(1)}
+	case "KICK":
+		if len(m.Params) < 2 {
+			break
+		}
+
+		return &KickEvent{m, from, m.Params[0], m.Params[1], param(2)}
+	case "QUIT":
+		return &QuitEvent{m, from, param(0)}
+	case "NICK":
+		if len(m.Params) < 1 || from == nil {
+			break
+		}
+
+		return &NickEvent{m, from, from.Name, m.Params[0]}
+	case "MODE":
+		if len(m.Params) < 2 {
+			break
+		}
+
+		target := m.Params[0]
+		isChannel := i.IsChannel(target)
+
+		return &ModeEvent{m, from, target, isChannel, i.ParseModes(isChannel, m.Params[1], m.Params[2:])}
+	case "TOPIC":
+		if len(m.Params) < 1 {
+			break
+		}
+
+		return &TopicEvent{m, from, m.Params[0], param(1)}
+	case "INVITE":
+		if len(m.Params) < 2 {
+			break
+		}
+
+		return &InviteEvent{m, from, m.Params[0], m.Params[1]}
+	case "AWAY":
+		return &AwayEvent{m, from, len(m.Params) > 0 && m.Params[0] != "", param(0)}
+	case "ACCOUNT":
+		if len(m.Params) < 1 {
+			break
+		}
+
+		account := m.Params[0]
+		if account == "*" {
+			account = ""
+		}
+
+		return &AccountEvent{m, from, account}
+	case "CHGHOST":
+		if len(m.Params) < 2 {
+			break
+		}
+
+		return &ChgHostEvent{m, from, m.Params[0], m.Params[1]}
+	case "PING":
+		return &PingEvent{m, m.Trailing()}
+	case "ERROR":
+		return &ErrorEvent{m, param(0)}
+	}
+
+	if isNumeric(m.Command) {
+		var args []string
+		if len(m.Params) > 1 {
+			args = m.Params[1:]
+		}
+
+		return &NumericEvent{m, from, m.Command, param(0), args}
+	}
+
+	return &UnknownEvent{m}
+}
+
+func isNumeric(cmd string) bool {
+	if len(cmd) != 3 {
+		return false
+	}
+
+	for n := 0; n < 3; n++ {
+		if cmd[n] < '0' || cmd[n] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func ParseCTCP(text string) (command string, args string, ok bool) {
+	if len(text) < 2 || text[0] != '\x01' {
+		return "", "", false
+	}
+
+	text = s.TrimSuffix(text[1:], "\x01")
+
+	parts := s.SplitN(text, " ", 2)
+	if parts[0] == "" {
+		return "", "", false
+	}
+
+	if len(parts) == 2 {
+		args = parts[1]
+	}
+
+	return s.ToUpper(parts[0]), args, true
+}
+
+func CTCP(command string, args string) string {
+	if args == "" {
+		return "\x01" + command + "\x01"
+	}
+
+	return "\x01" + command + " " + args + "\x01"
+}
+
+type ModeChange struct {
+	Add   bool
+	Mode  byte
+	Param string
+}
+
+func (i ISupport) ChanTypes() string {
+	if v, ok := i.Get("CHANTYPES"); ok {
+		return v
+	}
+
+	return "#&"
+}
+
+func (i ISupport) IsChannel(target string) bool {
+	return target != "" && s.IndexByte(i.ChanTypes(), target[0]) >= 0
+}
+
+func (i ISupport) PrefixModes() (modes string, symbols string) {
+	v, ok := i.Get("PREFIX")
+	if !ok {
+		return "ov", "@+"
+	}
+
+	if len(v) == 0 || v[0] != '(' {
+		return "", ""
+	}
+
+	parts := s.SplitN(v[1:], ")", 2)
+	if len(parts) < 2 || len(parts[0]) != len(parts[1]) {
+		return "", ""
+	}
+
+	return parts[0], parts[1]
+}
+
+func (i ISupport) ChanModes() [4]string {
+	ret := [4]string{"beI", "k", "l", "imnpst"}
+
+	if v, ok := i.Get("CHANMODES"); ok {
+		ret = [4]string{}
+		copy(ret[:], s.SplitN(v, ",", 4))
+	}
+
+	return ret
+}
+
+func (i ISupport) ParseModes(isChannel bool, modes string, params []string) []ModeChange {
+	var chanModes [4]string
+	var prefixModes string
+
+	if isChannel {
+		chanModes = i.ChanModes()
+		prefixModes, _ = i.PrefixModes()
+	}
+
+	var ret []ModeChange
+
+	add := true
+	for n := 0; n < len(modes); n++ {
+		c := modes[n]
+
+		switch c {
+		case '+':
+			add = true
+			continue
+		case '-':
+			add = false
+			continue
+		}
+
+		change := ModeChange{Add: add, Mode: c}
+
+		takesParam := s.IndexByte(prefixModes, c) >= 0 ||
+			s.IndexByte(chanModes[0], c) >= 0 ||
+			s.IndexByte(chanModes[1], c) >= 0 ||
+			add && s.IndexByte(chanModes[2], c) >= 0
+
+		if takesParam && len(params) > 0 {
+			change.Param, params = params[0], params[1:]
+		}
+
+		ret = append(ret, change)
+	}
+
+	return ret
+}
+
+type EventHandlerFunc func(c *Client, e Event)
+
+func (f EventHandlerFunc) Handle(c *Client, m *Message) {
+	f(c, clientDecodeEvent(c, m))
+}
+
+type EventMux struct {
+	lock     sync.RWMutex
+	handlers []func(c *Client, e Event)
+}
+
+func NewEventMux() *EventMux {
+	return &EventMux{}
+}
+
+func OnEvent[E Event](mux *EventMux, fn func(c *Client, e E)) {
+	mux.lock.Lock()
+	defer mux.lock.Unlock()
+
+	mux.handlers = append(mux.handlers, func(c *Client, e Event) {
+		if ev, ok := e.(E); ok {
+			fn(c, ev)
+		}
+	})
+}
+
+func (mux *EventMux) OnAny(fn func(c *Client, e Event)) {
+	mux.lock.Lock()
+	defer mux.lock.Unlock()
+
+	mux.handlers = append(mux.handlers, fn)
+}
+
+func (mux *EventMux) Handle(c *Client, m *Message) {
+	mux.HandleEvent(c, clientDecodeEvent(c, m))
+}
+
+func (mux *EventMux) HandleEvent(c *Client, e Event) {
+	mux.lock.RLock()
+	handlers := mux.handlers
+	mux.lock.RUnlock()
+
+	for _, fn := range handlers {
+		fn(c, e)
+	}
+}
+
+func clientDecodeEvent(c *Client, m *Message) Event {
+	if c == nil {
+		return DecodeEvent(m)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.isupport.DecodeEvent(m)
+}
@@ -0,0 +1,248 @@
+package tightbeam_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	from := tightbeam.ParsePrefix("alice!a@host")
+
+	for _, tt := range []struct {
+		line string
+		want func(m *tightbeam.Message) tightbeam.Event
+	}{
+		{":alice!a@host PRIVMSG #chan :hi there", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.PrivmsgEvent{Message: m, From: from, Target: "#chan", Text: "hi there", IsChannel: true}
+		}},
+		{":alice!a@host PRIVMSG me :\x01ACTION waves\x01", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.PrivmsgEvent{Message: m, From: from, Target: "me", Text: "waves", IsAction: true}
+		}},
+		{":alice!a@host NOTICE &local :notice", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.NoticeEvent{Message: m, From: from, Target: "&local", Text: "notice", IsChannel: true}
+		}},
+		{":alice!a@host PRIVMSG me :\x01version\x01", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.CTCPEvent{Message: m, From: from, Target: "me", Type: "VERSION"}
+		}},
+		{":alice!a@host NOTICE me :\x01VERSION tb 1.0\x01", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.CTCPEvent{Message: m, From: from, Target: "me", Type: "VERSION", Args: "tb 1.0", IsReply: true}
+		}},
+		{":alice!a@host NOTICE #chan :\x01ACTION waves\x01", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.CTCPEvent{Message: m, From: from, Target: "#chan", Type: "ACTION", Args: "waves", IsReply: true, IsChannel: true}
+		}},
+		{"@+typing=active :alice!a@host TAGMSG #chan", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.TagmsgEvent{Message: m, From: from, Target: "#chan", IsChannel: true}
+		}},
+		{":alice!a@host JOIN #chan", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.JoinEvent{Message: m, From: from, Channel: "#chan"}
+		}},
+		{":alice!a@host JOIN #chan acct :Alice Liddell", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.JoinEvent{Message: m, From: from, Channel: "#chan", Account: "acct", Realname: "Alice Liddell"}
+		}},
+		{":alice!a@host JOIN #chan * :Alice", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.JoinEvent{Message: m, From: from, Channel: "#chan", Realname: "Alice"}
+		}},
+		{":alice!a@host PART #chan :bye", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.PartEvent{Message: m, From: from, Channel: "#chan", Reason: "bye"}
+		}},
+		{":alice!a@host KICK #chan bob", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.KickEvent{Message: m, From: from, Channel: "#chan", Nick: "bob"}
+		}},
+		{":alice!a@host QUIT", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.QuitEvent{Message: m, From: from}
+		}},
+		{":alice!a@host NICK alicia", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.NickEvent{Message: m, From: from, Old: "alice", New: "alicia"}
+		}},
+		{":alice!a@host MODE #chan +o bob", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.ModeEvent{Message: m, From: from, Target: "#chan", IsChannel: true, Changes: []tightbeam.ModeChange{{Add: true, Mode: 'o', Param: "bob"}}}
+		}},
+		{":alice!a@host MODE alice -i", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.ModeEvent{Message: m, From: from, Target: "alice", Changes: []tightbeam.ModeChange{{Mode: 'i'}}}
+		}},
+		{":alice!a@host TOPIC #chan :", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.TopicEvent{Message: m, From: from, Channel: "#chan"}
+		}},
+		{":alice!a@host INVITE bob #chan", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.InviteEvent{Message: m, From: from, Nick: "bob", Channel: "#chan"}
+		}},
+		{":alice!a@host AWAY :gone", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.AwayEvent{Message: m, From: from, Away: true, Reason: "gone"}
+		}},
+		{":alice!a@host AWAY", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.AwayEvent{Message: m, From: from}
+		}},
+		{":alice!a@host ACCOUNT acct", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.AccountEvent{Message: m, From: from, Account: "acct"}
+		}},
+		{":alice!a@host ACCOUNT *", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.AccountEvent{Message: m, From: from}
+		}},
+		{":alice!a@host CHGHOST ~al new.host", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.ChgHostEvent{Message: m, From: from, NewUser: "~al", NewHost: "new.host"}
+		}},
+		{"PING :token here", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.PingEvent{Message: m, Token: "token here"}
+		}},
+		{"ERROR :Closing Link", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.ErrorEvent{Message: m, Reason: "Closing Link"}
+		}},
+		{":srv 001 me :Welcome", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.NumericEvent{Message: m, From: tightbeam.ParsePrefix("srv"), Code: "001", Target: "me", Args: []string{"Welcome"}}
+		}},
+		{":srv 999", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.NumericEvent{Message: m, From: tightbeam.ParsePrefix("srv"), Code: "999"}
+		}},
+		{":alice!a@host PRIVMSG #chan", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.UnknownEvent{Message: m}
+		}},
+		{"NICK alicia", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.UnknownEvent{Message: m}
+		}},
+		{":alice!a@host FROB x", func(m *tightbeam.Message) tightbeam.Event {
+			return &tightbeam.UnknownEvent{Message: m}
+		}},
+	} {
+		m := tightbeam.MustParseMessage(tt.line)
+		got, want := tightbeam.DecodeEvent(m), tt.want(m)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("DecodeEvent(%q) = %#v, want %#v", tt.line, got, want)
+		}
+
+		if got.Raw() != m {
+			t.Errorf("DecodeEvent(%q).Raw() is not the decoded message", tt.line)
+		}
+	}
+}
+
+func TestDecodeEventISupport(t *testing.T) {
+	i := tightbeam.ISupport{"CHANTYPES": "#", "PREFIX": "(qaohv)~&@%+", "CHANMODES": "beI,k,l,imnst"}
+
+	if e := i.DecodeEvent(tightbeam.MustParseMessage(":a PRIVMSG &local :hi")).(*tightbeam.PrivmsgEvent); e.IsChannel {
+		t.Error("&local is a channel with CHANTYPES=#")
+	}
+
+	e := i.DecodeEvent(tightbeam.MustParseMessage(":op MODE #x +qk-lb+l nick key *!*@* 10")).(*tightbeam.ModeEvent)
+	want := []tightbeam.ModeChange{{Add: true, Mode: 'q', Param: "nick"}, {Add: true, Mode: 'k', Param: "key"}, {Mode: 'l'}, {Mode: 'b', Param: "*!*@*"}, {Add: true, Mode: 'l', Param: "10"}}
+
+	if !reflect.DeepEqual(e.Changes, want) {
+		t.Errorf("Changes = %+v, want %+v", e.Changes, want)
+	}
+}
+
+func TestParseModes(t *testing.T) {
+	for _, tt := range []struct {
+		isupport  tightbeam.ISupport
+		isChannel bool
+		modes     string
+		params    []string
+		want      []tightbeam.ModeChange
+	}{
+		{tightbeam.ISupport{}, true, "+nt", nil, []tightbeam.ModeChange{{Add: true, Mode: 'n'}, {Add: true, Mode: 't'}}},
+		{tightbeam.ISupport{}, true, "+ov-v", []string{"a", "b", "c"}, []tightbeam.ModeChange{{Add: true, Mode: 'o', Param: "a"}, {Add: true, Mode: 'v', Param: "b"}, {Mode: 'v', Param: "c"}}},
+		{tightbeam.ISupport{}, true, "-k+l", []string{"key", "5"}, []tightbeam.ModeChange{{Mode: 'k', Param: "key"}, {Add: true, Mode: 'l', Param: "5"}}},
+		{tightbeam.ISupport{}, true, "-l", []string{"extra"}, []tightbeam.ModeChange{{Mode: 'l'}}},
+		{tightbeam.ISupport{}, true, "b", nil, []tightbeam.ModeChange{{Add: true, Mode: 'b'}}},
+		{tightbeam.ISupport{}, true, "+oo", []string{"a"}, []tightbeam.ModeChange{{Add: true, Mode: 'o', Param: "a"}, {Add: true, Mode: 'o'}}},
+		{tightbeam.ISupport{}, false, "+ov", []string{"a"}, []tightbeam.ModeChange{{Add: true, Mode: 'o'}, {Add: true, Mode: 'v'}}},
+		{tightbeam.ISupport{"PREFIX": "(qo)~@"}, true, "+qv", []string{"a", "b"}, []tightbeam.ModeChange{{Add: true, Mode: 'q', Param: "a"}, {Add: true, Mode: 'v'}}},
+		{tightbeam.ISupport{"PREFIX": ""}, true, "+o", []string{"a"}, []tightbeam.ModeChange{{Add: true, Mode: 'o'}}},
+		{tightbeam.ISupport{"CHANMODES": "Z,Y,X,W"}, true, "+ZYXWb-X", []string{"z", "y", "x", "w"}, []tightbeam.ModeChange{{Add: true, Mode: 'Z', Param: "z"}, {Add: true, Mode: 'Y', Param: "y"}, {Add: true, Mode: 'X', Param: "x"}, {Add: true, Mode: 'W'}, {Add: true, Mode: 'b'}, {Mode: 'X'}}},
+		{tightbeam.ISupport{"CHANMODES": "b"}, true, "+bk", []string{"m", "k"}, []tightbeam.ModeChange{{Add: true, Mode: 'b', Param: "m"}, {Add: true, Mode: 'k'}}},
+		{tightbeam.ISupport{}, true, "", nil, nil},
+	} {
+		got := tt.isupport.ParseModes(tt.isChannel, tt.modes, tt.params)
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v.ParseModes(%v, %q, %q) = %+v, want %+v", tt.isupport, tt.isChannel, tt.modes, tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestParseCTCP(t *testing.T) {
+	for _, tt := range []struct {
+		text    string
+		command string
+		args    string
+		ok      bool
+	}{
+		{"\x01ACTION waves\x01", "ACTION", "waves", true},
+		{"\x01ping 123", "PING", "123", true},
+		{"\x01VERSION\x01", "VERSION", "", true},
+		{"\x01\x01", "", "", false},
+		{"\x01", "", "", false},
+		{"plain", "", "", false},
+	} {
+		command, args, ok := tightbeam.ParseCTCP(tt.text)
+		if command != tt.command || args != tt.args || ok != tt.ok {
+			t.Errorf("ParseCTCP(%q) = %q, %q, %v, want %q, %q, %v", tt.text, command, args, ok, tt.command, tt.args, tt.ok)
+		}
+	}
+
+	if got := tightbeam.CTCP("ACTION", "waves"); got != "\x01ACTION waves\x01" {
+		t.Errorf("CTCP() = %q", got)
+	}
+
+	if got := tightbeam.CTCP("VERSION", ""); got != "\x01VERSION\x01" {
+		t.Errorf("CTCP() = %q", got)
+	}
+}
+
+func TestEventMux(t *testing.T) {
+	mux := tightbeam.NewEventMux()
+
+	var got []string
+	tightbeam.OnEvent(mux, func(c *tightbeam.Client, e *tightbeam.PrivmsgEvent) {
+		got = append(got, "privmsg "+e.Text)
+	})
+	tightbeam.OnEvent(mux, func(c *tightbeam.Client, e *tightbeam.JoinEvent) {
+		got = append(got, "join "+e.Channel)
+	})
+	mux.OnAny(func(c *tightbeam.Client, e tightbeam.Event) {
+		got = append(got, "any "+e.Raw().Command)
+	})
+
+	mux.Handle(nil, tightbeam.MustParseMessage(":a!b@c PRIVMSG #chan :hi"))
+	mux.Handle(nil, tightbeam.MustParseMessage(":a!b@c JOIN #chan"))
+	mux.Handle(nil, tightbeam.MustParseMessage(":a!b@c PART #chan"))
+
+	want := []string{"privmsg hi", "any PRIVMSG", "join #chan", "any JOIN", "any PART"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("handled %q, want %q", got, want)
+	}
+}
+
+func TestClientEvents(t *testing.T) {
+	srv := tightbeamtest.NewServer(t)
+	received := make(chan *tightbeam.PrivmsgEvent, 2)
+
+	mux := tightbeam.NewEventMux()
+	tightbeam.OnEvent(mux, func(c *tightbeam.Client, e *tightbeam.PrivmsgEvent) {
+		received <- e
+	})
+
+	c := tightbeam.NewClient(srv.Conn(), tightbeam.ClientConfig{Nick: "bot", Handler: mux})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	srv.Register("bot")
+	srv.Send(":alice!a@h PRIVMSG &local :hi")
+
+	if e := <-received; !e.IsChannel {
+		t.Fatal("&local is not a channel with default CHANTYPES")
+	}
+
+	srv.Numeric(tightbeam.RPL_ISUPPORT, "bot", "CHANTYPES=#", "are supported by this server")
+	srv.Send(":alice!a@h PRIVMSG &local :hi")
+
+	if e := <-received; e.IsChannel {
+		t.Fatal("&local is a channel after CHANTYPES=#")
+	}
+}
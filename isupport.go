@@ -0,0 +1,90 @@
+package tightbeam
+
+import (
+	"bytes"
+	"strconv"
+	s "strings"
+)
+
+type ISupport map[string]string
+
+func (i ISupport) Update(m *Message) {
+	if m.Command != RPL_ISUPPORT || len(m.Params) < 2 {
+		return
+	}
+
+	for _, token := range m.Params[1 : len(m.Params)-1] {
+		if token == "" {
+			continue
+		}
+
+		if token[0] == '-' {
+			delete(i, s.ToUpper(token[1:]))
+			continue
+		}
+
+		parts := s.SplitN(token, "=", 2)
+		if len(parts) < 2 {
+			i[s.ToUpper(parts[0])] = ""
+			continue
+		}
+
+		i[s.ToUpper(parts[0])] = decodeISupportValue(parts[1])
+	}
+}
+
+func (i ISupport) Get(key string) (string, bool) {
+	ret, ok := i[s.ToUpper(key)]
+	return ret, ok
+}
+
+func (i ISupport) Has(key string) bool {
+	_, ok := i[s.ToUpper(key)]
+	return ok
+}
+
+func (i ISupport) Int(key string, def int) int {
+	v, ok := i.Get(key)
+	if !ok || v == "" {
+		return def
+	}
+
+	ret, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+
+	return ret
+}
+
+func (i ISupport) Copy() ISupport {
+	ret := ISupport{}
+
+	for k, v := range i {
+		ret[k] = v
+	}
+
+	return ret
+}
+
+func decodeISupportValue(v string) string {
+	if !s.Contains(v, "\\x") {
+		return v
+	}
+
+	ret := &bytes.Buffer{}
+
+	for n := 0; n < len(v); n++ {
+		if v[n] == '\\' && n+3 < len(v) && v[n+1] == 'x' {
+			if b, err := strconv.ParseUint(v[n+2:n+4], 16, 8); err == nil {
+				ret.WriteByte(byte(b))
+				n += 3
+				continue
+			}
+		}
+
+		ret.WriteByte(v[n])
+	}
+
+	return ret.String()
+}
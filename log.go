@@ -0,0 +1,145 @@
+package tightbeam
+
+import (
+	"context"
+	"log/slog"
+	s "strings"
+	"sync"
+)
+
+const redacted = "<redacted>"
+
+var nickServCommands = map[string]bool{
+	"IDENTIFY": true,
+	"REGISTER": true,
+	"GHOST":    true,
+	"RECOVER":  true,
+	"RELEASE":  true,
+	"REGAIN":   true,
+	"SET":      true,
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discardLogger = slog.New(discardHandler{})
+
+func RedactLine(line string) string {
+	m, err := ParseMessage(line)
+	if err != nil {
+		return line
+	}
+
+	if r := Redact(m); r != m {
+		return r.String()
+	}
+
+	return line
+}
+
+func Redact(m *Message) *Message {
+	if len(m.Params) == 0 {
+		return m
+	}
+
+	switch m.Command {
+	case "PASS":
+		ret := m.Copy()
+		for n := range ret.Params {
+			ret.Params[n] = redacted
+		}
+		return ret
+	case "OPER":
+		if len(m.Params) < 2 {
+			return m
+		}
+		ret := m.Copy()
+		ret.Params[1] = redacted
+		return ret
+	case "AUTHENTICATE":
+		if v := m.Params[0]; v == "+" || v == "*" {
+			return m
+		}
+		ret := m.Copy()
+		ret.Params[0] = redacted
+		return ret
+	case "NICKSERV", "NS":
+		if len(m.Params) < 2 {
+			return redactNickServ(m, 0)
+		}
+		if !nickServCommands[s.ToUpper(m.Params[0])] {
+			return m
+		}
+		ret := m.Copy()
+		ret.Params = []string{ret.Params[0], redacted}
+		return ret
+	case "PRIVMSG", "NOTICE":
+		if len(m.Params) < 2 || !isNickServ(m.Params[0]) {
+			return m
+		}
+		return redactNickServ(m, 1)
+	}
+
+	return m
+}
+
+func redactNickServ(m *Message, n int) *Message {
+	fields := s.SplitN(m.Params[n], " ", 2)
+	if len(fields) < 2 || !nickServCommands[s.ToUpper(fields[0])] {
+		return m
+	}
+
+	ret := m.Copy()
+	ret.Params[n] = fields[0] + " " + redacted
+	return ret
+}
+
+type Redactor struct {
+	lock           sync.Mutex
+	authenticating bool
+}
+
+func (r *Redactor) RedactLine(line string) string {
+	m, err := ParseMessage(line)
+	if err != nil {
+		return line
+	}
+
+	if ret := r.Redact(m); ret != m {
+		return ret.String()
+	}
+
+	return line
+}
+
+func (r *Redactor) Redact(m *Message) *Message {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	switch {
+	case isNumeric(m.Command) && m.Command >= "900" && m.Command <= "908":
+		r.authenticating = false
+	case m.Command == "AUTHENTICATE" && len(m.Params) > 0:
+		switch m.Params[0] {
+		case "*":
+			r.authenticating = false
+		case "+":
+		default:
+			if !r.authenticating {
+				r.authenticating = true
+				return m
+			}
+		}
+	}
+
+	return Redact(m)
+}
+
+func isNickServ(target string) bool {
+	name := s.SplitN(target, "@", 2)[0]
+	return s.EqualFold(name, "NickServ")
+}
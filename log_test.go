@@ -0,0 +1,128 @@
+package tightbeam_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	s "strings"
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+func TestRedactLine(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want string
+	}{
+		{"PASS hunter2", "PASS <redacted>"},
+		{"PASS user:hunter2 extra", "PASS <redacted> <redacted>"},
+		{"PASS", "PASS"},
+		{"OPER admin hunter2", "OPER admin <redacted>"},
+		{"OPER admin", "OPER admin"},
+		{"AUTHENTICATE Zm9vAGZvbwBiYXI=", "AUTHENTICATE <redacted>"},
+		{"AUTHENTICATE +", "AUTHENTICATE +"},
+		{"AUTHENTICATE *", "AUTHENTICATE *"},
+		{"NICKSERV IDENTIFY hunter2", "NICKSERV IDENTIFY <redacted>"},
+		{"NICKSERV IDENTIFY acct hunter2", "NICKSERV IDENTIFY <redacted>"},
+		{"NS register hunter2 me@example.com", "NS register <redacted>"},
+		{"NICKSERV INFO bob", "NICKSERV INFO bob"},
+		{"NICKSERV :IDENTIFY hunter2", "NICKSERV :IDENTIFY <redacted>"},
+		{"NICKSERV :IDENTIFY acct hunter2", "NICKSERV :IDENTIFY <redacted>"},
+		{"NS :ghost bob hunter2", "NS :ghost <redacted>"},
+		{"NICKSERV :INFO bob", "NICKSERV :INFO bob"},
+		{"NICKSERV IDENTIFY", "NICKSERV IDENTIFY"},
+		{"PRIVMSG NickServ :IDENTIFY acct hunter2", "PRIVMSG NickServ :IDENTIFY <redacted>"},
+		{"PRIVMSG nickserv@services.example :identify hunter2", "PRIVMSG nickserv@services.example :identify <redacted>"},
+		{"NOTICE NickServ :SET PASSWORD hunter2", "NOTICE NickServ :SET <redacted>"},
+		{"PRIVMSG NickServ :INFO bob", "PRIVMSG NickServ :INFO bob"},
+		{"PRIVMSG #chan :IDENTIFY hunter2", "PRIVMSG #chan :IDENTIFY hunter2"},
+		{"PRIVMSG NickServ", "PRIVMSG NickServ"},
+		{"@time=x :me!u@h PRIVMSG NickServ :REGAIN me hunter2", "@time=x :me!u@h PRIVMSG NickServ :REGAIN <redacted>"},
+		{"PRIVMSG #chan :hello", "PRIVMSG #chan :hello"},
+		{"not a :valid\x00 line", "not a :valid\x00 line"},
+	} {
+		if got := tightbeam.RedactLine(tt.line); got != tt.want {
+			t.Errorf("RedactLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestRedactUnchanged(t *testing.T) {
+	m := tightbeam.MustParseMessage("PRIVMSG #chan :hello")
+	if tightbeam.Redact(m) != m {
+		t.Error("Redact() copied a message without secrets")
+	}
+
+	m = tightbeam.MustParseMessage("PASS hunter2")
+	if tightbeam.Redact(m) == m || m.Params[0] != "hunter2" {
+		t.Error("Redact() modified its argument")
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	r := &tightbeam.Redactor{}
+
+	for _, tt := range []struct {
+		line string
+		want string
+	}{
+		{"AUTHENTICATE PLAIN", "AUTHENTICATE PLAIN"},
+		{"AUTHENTICATE +", "AUTHENTICATE +"},
+		{"AUTHENTICATE Zm9vAGZvbwBiYXI=", "AUTHENTICATE <redacted>"},
+		{"AUTHENTICATE EXTERNAL", "AUTHENTICATE <redacted>"},
+		{":srv 904 me :SASL authentication failed", ":srv 904 me :SASL authentication failed"},
+		{"AUTHENTICATE SCRAM-SHA-256", "AUTHENTICATE SCRAM-SHA-256"},
+		{"AUTHENTICATE biwsbj1tZQ==", "AUTHENTICATE <redacted>"},
+		{"AUTHENTICATE *", "AUTHENTICATE *"},
+		{"AUTHENTICATE PLAIN", "AUTHENTICATE PLAIN"},
+		{"AUTHENTICATE Zm9v", "AUTHENTICATE <redacted>"},
+		{":srv 903 me :SASL authentication successful", ":srv 903 me :SASL authentication successful"},
+		{"AUTHENTICATE EXTERNAL", "AUTHENTICATE EXTERNAL"},
+		{"AUTHENTICATE +", "AUTHENTICATE +"},
+		{":srv 900 me me!u@h acct :You are now logged in", ":srv 900 me me!u@h acct :You are now logged in"},
+		{"PASS hunter2", "PASS <redacted>"},
+		{"NICKSERV IDENTIFY hunter2", "NICKSERV IDENTIFY <redacted>"},
+	} {
+		if got := r.RedactLine(tt.line); got != tt.want {
+			t.Errorf("RedactLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestClientLogRedacts(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	buf := &bytes.Buffer{}
+	c := tightbeam.NewClient(a, tightbeam.ClientConfig{
+		Nick:   "me",
+		Pass:   "hunter2",
+		Logger: slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+
+	done := make(chan struct{})
+	go func() {
+		c.Run(context.Background())
+		close(done)
+	}()
+
+	r, w := tightbeam.NewReader(b), tightbeam.NewWriter(b)
+	if line, _ := r.ReadLine(); line != "PASS hunter2" {
+		t.Fatalf("read %q, want PASS hunter2", line)
+	}
+	r.ReadLine()
+	r.ReadLine()
+
+	w.WriteLine(":srv 001 me :Welcome")
+	w.WriteLine("PING :sync")
+	r.ReadLine()
+
+	c.Close()
+	<-done
+
+	if log := buf.String(); s.Contains(log, "hunter2") || !s.Contains(log, "PASS <redacted>") {
+		t.Fatalf("log does not redact PASS:\n%s", log)
+	}
+}
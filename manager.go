@@ -0,0 +1,423 @@
+package tightbeam
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrorNoSuchNetwork = errors.New("irc: No such network")
+
+	ErrorNetworkExists = errors.New("irc: Network already exists")
+
+	ErrorNotConnected = errors.New("irc: Network not connected")
+)
+
+const (
+	DefaultReconnectMin = 5 * time.Second
+	DefaultReconnectMax = 5 * time.Minute
+)
+
+type NetworkHandler interface {
+	HandleNetwork(network string, c *Client, m *Message)
+}
+
+type NetworkHandlerFunc func(network string, c *Client, m *Message)
+
+func (f NetworkHandlerFunc) HandleNetwork(network string, c *Client, m *Message) {
+	f(network, c, m)
+}
+
+type NetworkConfig struct {
+	Name string
+	Addr string
+
+	Client  ClientConfig
+	Connect func(ctx context.Context) (Transport, error)
+
+	ReconnectMin time.Duration
+	ReconnectMax time.Duration
+}
+
+type NetworkState int
+
+const (
+	NetworkStopped NetworkState = iota
+	NetworkConnecting
+	NetworkConnected
+	NetworkRegistered
+	NetworkBackoff
+)
+
+func (s NetworkState) String() string {
+	switch s {
+	case NetworkConnecting:
+		return "connecting"
+	case NetworkConnected:
+		return "connected"
+	case NetworkRegistered:
+		return "registered"
+	case NetworkBackoff:
+		return "backoff"
+	}
+
+	return "stopped"
+}
+
+type NetworkHealth struct {
+	Network    string
+	State      NetworkState
+	Since      time.Time
+	LastError  error
+	Reconnects int
+	Lag        time.Duration
+}
+
+type Manager struct {
+	handler NetworkHandler
+
+	lock     sync.Mutex
+	networks map[string]*network
+}
+
+type network struct {
+	config NetworkConfig
+
+	lock       sync.Mutex
+	client     *Client
+	state      NetworkState
+	since      time.Time
+	lastError  error
+	reconnects int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewManager(handler NetworkHandler) *Manager {
+	return &Manager{
+		handler:  handler,
+		networks: map[string]*network{},
+	}
+}
+
+func (m *Manager) Add(config NetworkConfig) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.networks[config.Name]; ok {
+		return ErrorNetworkExists
+	}
+
+	if config.ReconnectMin <= 0 {
+		config.ReconnectMin = DefaultReconnectMin
+	}
+
+	if config.ReconnectMax < config.ReconnectMin {
+		config.ReconnectMax = DefaultReconnectMax
+		if config.ReconnectMax < config.ReconnectMin {
+			config.ReconnectMax = config.ReconnectMin
+		}
+	}
+
+	m.networks[config.Name] = &network{config: config, since: time.Now()}
+
+	return nil
+}
+
+func (m *Manager) Remove(name string) error {
+	if err := m.Stop(name); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	delete(m.networks, name)
+	m.lock.Unlock()
+
+	return nil
+}
+
+func (m *Manager) Networks() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ret := make([]string, 0, len(m.networks))
+	for name := range m.networks {
+		ret = append(ret, name)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+func (m *Manager) network(name string) (*network, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	n, ok := m.networks[name]
+	if !ok {
+		return nil, ErrorNoSuchNetwork
+	}
+
+	return n, nil
+}
+
+func (m *Manager) Start(ctx context.Context, name string) error {
+	n, err := m.network(name)
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for n.done != nil {
+		if n.ctx.Err() == nil {
+			return nil
+		}
+
+		done := n.done
+		n.lock.Unlock()
+		<-done
+		n.lock.Lock()
+	}
+
+	n.ctx, n.cancel = context.WithCancel(ctx)
+	n.done = make(chan struct{})
+
+	go m.run(n.ctx, n, n.done)
+
+	return nil
+}
+
+func (m *Manager) StartAll(ctx context.Context) {
+	for _, name := range m.Networks() {
+		m.Start(ctx, name)
+	}
+}
+
+func (m *Manager) Stop(name string) error {
+	n, err := m.network(name)
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	cancel, done := n.cancel, n.done
+	n.lock.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	<-done
+
+	return nil
+}
+
+func (m *Manager) StopAll() {
+	var wg sync.WaitGroup
+
+	for _, name := range m.Networks() {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			m.Stop(name)
+		}(name)
+	}
+
+	wg.Wait()
+}
+
+func (m *Manager) Client(name string) *Client {
+	n, err := m.network(name)
+	if err != nil {
+		return nil
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.client
+}
+
+func (m *Manager) Send(name string, msg *Message) error {
+	n, err := m.network(name)
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	c := n.client
+	n.lock.Unlock()
+
+	if c == nil {
+		return ErrorNotConnected
+	}
+
+	return c.Send(msg)
+}
+
+func (m *Manager) SendText(name string, target string, text string) error {
+	msg, err := Privmsg(target, text)
+	if err != nil {
+		return err
+	}
+
+	return m.Send(name, msg)
+}
+
+func (m *Manager) Health(name string) (NetworkHealth, error) {
+	n, err := m.network(name)
+	if err != nil {
+		return NetworkHealth{}, err
+	}
+
+	return n.health(), nil
+}
+
+func (m *Manager) HealthAll() []NetworkHealth {
+	names := m.Networks()
+	ret := make([]NetworkHealth, 0, len(names))
+
+	for _, name := range names {
+		if h, err := m.Health(name); err == nil {
+			ret = append(ret, h)
+		}
+	}
+
+	return ret
+}
+
+func (n *network) health() NetworkHealth {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	h := NetworkHealth{
+		Network:    n.config.Name,
+		State:      n.state,
+		Since:      n.since,
+		LastError:  n.lastError,
+		Reconnects: n.reconnects,
+	}
+
+	if n.client != nil {
+		h.Lag = n.client.Lag()
+	}
+
+	return h
+}
+
+func (n *network) setState(state NetworkState, c *Client, err error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if state != n.state {
+		n.state = state
+		n.since = time.Now()
+	}
+
+	n.client = c
+
+	if err != nil {
+		n.lastError = err
+	}
+}
+
+func (m *Manager) run(ctx context.Context, n *network, done chan struct{}) {
+	defer func() {
+		n.setState(NetworkStopped, nil, nil)
+
+		n.lock.Lock()
+		n.ctx, n.cancel, n.done = nil, nil, nil
+		close(done)
+		n.lock.Unlock()
+	}()
+
+	backoff := n.config.ReconnectMin
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			n.setState(NetworkBackoff, nil, nil)
+
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+
+			n.lock.Lock()
+			n.reconnects++
+			n.lock.Unlock()
+
+			if n.config.Client.Metrics != nil {
+				n.config.Client.Metrics.Reconnect()
+			}
+
+			backoff *= 2
+			if backoff > n.config.ReconnectMax {
+				backoff = n.config.ReconnectMax
+			}
+		}
+
+		n.setState(NetworkConnecting, nil, nil)
+
+		c, err := m.connect(ctx, n)
+		if err != nil {
+			n.setState(NetworkBackoff, nil, err)
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		n.setState(NetworkConnected, c, nil)
+
+		err = c.Run(ctx)
+		if c.Registered() {
+			backoff = n.config.ReconnectMin
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		n.setState(NetworkBackoff, nil, err)
+	}
+}
+
+func (m *Manager) connect(ctx context.Context, n *network) (*Client, error) {
+	config := n.config.Client
+	next := config.Handler
+
+	config.Handler = HandlerFunc(func(c *Client, msg *Message) {
+		if msg.Command == RPL_WELCOME {
+			n.setState(NetworkRegistered, c, nil)
+		}
+
+		if next != nil {
+			next.Handle(c, msg)
+		}
+
+		if m.handler != nil {
+			m.handler.HandleNetwork(n.config.Name, c, msg)
+		}
+	})
+
+	if n.config.Connect != nil {
+		t, err := n.config.Connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewTransportClient(t, config), nil
+	}
+
+	return Dial(ctx, n.config.Addr, config)
+}
@@ -0,0 +1,230 @@
+package tightbeam_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+type reconnectCounter struct {
+	tightbeam.NopMetrics
+
+	lock  sync.Mutex
+	count int
+}
+
+func (r *reconnectCounter) Reconnect() {
+	r.lock.Lock()
+	r.count++
+	r.lock.Unlock()
+}
+
+func (r *reconnectCounter) Count() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.count
+}
+
+func TestManager(t *testing.T) {
+	servers := make(chan *tightbeamtest.Server, 4)
+	received := make(chan string, 4)
+
+	mgr := tightbeam.NewManager(tightbeam.NetworkHandlerFunc(func(network string, c *tightbeam.Client, m *tightbeam.Message) {
+		if m.Command == "PRIVMSG" {
+			received <- network + ":" + m.Trailing()
+		}
+	}))
+
+	metrics := &reconnectCounter{}
+
+	err := mgr.Add(tightbeam.NetworkConfig{
+		Name: "libera",
+		Connect: func(ctx context.Context) (tightbeam.Transport, error) {
+			srv := tightbeamtest.NewServer(t)
+			servers <- srv
+			return tightbeam.NewStreamTransport(srv.Conn()), nil
+		},
+		Client:       tightbeam.ClientConfig{Nick: "bot", Metrics: metrics},
+		ReconnectMin: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mgr.Add(tightbeam.NetworkConfig{Name: "libera"}); err != tightbeam.ErrorNetworkExists {
+		t.Fatalf("duplicate Add: got %v", err)
+	}
+
+	if err := mgr.SendText("libera", "#chan", "hi"); err != tightbeam.ErrorNotConnected {
+		t.Fatalf("SendText before start: got %v", err)
+	}
+
+	if err := mgr.SendText("oftc", "#chan", "hi"); err != tightbeam.ErrorNoSuchNetwork {
+		t.Fatalf("SendText to unknown network: got %v", err)
+	}
+
+	mgr.StartAll(context.Background())
+	defer mgr.StopAll()
+
+	srv := <-servers
+	srv.Register("bot")
+	srv.Send(":alice!a@host PRIVMSG bot :hello")
+
+	if got := <-received; got != "libera:hello" {
+		t.Fatalf("handler got %q", got)
+	}
+
+	if h, _ := mgr.Health("libera"); h.State != tightbeam.NetworkRegistered {
+		t.Fatalf("state = %v, want registered", h.State)
+	}
+
+	go mgr.SendText("libera", "#chan", "hi there")
+	srv.Expect("PRIVMSG #chan :hi there")
+
+	srv.Close()
+
+	srv = <-servers
+	srv.Register("bot")
+
+	h, _ := mgr.Health("libera")
+	if h.Reconnects != 1 || h.LastError == nil {
+		t.Fatalf("health after reconnect = %+v", h)
+	}
+
+	if metrics.Count() != 1 {
+		t.Fatalf("Metrics.Reconnect called %d times, want 1", metrics.Count())
+	}
+
+	mgr.Stop("libera")
+
+	if h, _ := mgr.Health("libera"); h.State != tightbeam.NetworkStopped {
+		t.Fatalf("state after Stop = %v", h.State)
+	}
+}
+
+func TestManagerNetworks(t *testing.T) {
+	type event struct {
+		network string
+		client  *tightbeam.Client
+		text    string
+	}
+
+	received := make(chan event, 4)
+	mgr := tightbeam.NewManager(tightbeam.NetworkHandlerFunc(func(network string, c *tightbeam.Client, m *tightbeam.Message) {
+		if m.Command == "PRIVMSG" {
+			received <- event{network, c, m.Trailing()}
+		}
+	}))
+
+	servers := map[string]chan *tightbeamtest.Server{}
+	for _, name := range []string{"oftc", "libera"} {
+		ch := make(chan *tightbeamtest.Server, 1)
+		servers[name] = ch
+
+		err := mgr.Add(tightbeam.NetworkConfig{
+			Name: name,
+			Connect: func(ctx context.Context) (tightbeam.Transport, error) {
+				srv := tightbeamtest.NewServer(t)
+				ch <- srv
+				return tightbeam.NewStreamTransport(srv.Conn()), nil
+			},
+			Client: tightbeam.ClientConfig{Nick: "bot"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if names := mgr.Networks(); len(names) != 2 || names[0] != "libera" || names[1] != "oftc" {
+		t.Fatalf("Networks() = %q", names)
+	}
+
+	mgr.StartAll(context.Background())
+	defer mgr.StopAll()
+
+	libera, oftc := <-servers["libera"], <-servers["oftc"]
+	libera.Register("bot")
+	oftc.Register("bot")
+
+	oftc.Send(":alice!a@host PRIVMSG #chan :from oftc")
+	if ev := <-received; ev.network != "oftc" || ev.text != "from oftc" || ev.client != mgr.Client("oftc") {
+		t.Fatalf("handler got %q from %q", ev.text, ev.network)
+	}
+
+	libera.Send(":bob!b@host PRIVMSG #chan :from libera")
+	if ev := <-received; ev.network != "libera" || ev.text != "from libera" || ev.client != mgr.Client("libera") {
+		t.Fatalf("handler got %q from %q", ev.text, ev.network)
+	}
+
+	if mgr.Client("libera") == mgr.Client("oftc") {
+		t.Fatal("Client() returned the same client for both networks")
+	}
+
+	go mgr.SendText("oftc", "#chan", "to oftc")
+	oftc.Expect("PRIVMSG #chan :to oftc")
+
+	go mgr.Send("libera", tightbeam.MustParseMessage("PRIVMSG bob :to libera"))
+	libera.Expect("PRIVMSG bob :to libera")
+
+	oftc.Send("PING :sync")
+	oftc.Expect("PONG sync")
+
+	health := mgr.HealthAll()
+	if len(health) != 2 || health[0].Network != "libera" || health[1].Network != "oftc" {
+		t.Fatalf("HealthAll() = %+v", health)
+	}
+
+	if err := mgr.Remove("oftc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mgr.SendText("oftc", "#chan", "gone"); err != tightbeam.ErrorNoSuchNetwork {
+		t.Fatalf("SendText to a removed network: got %v", err)
+	}
+
+	if h, _ := mgr.Health("libera"); h.State != tightbeam.NetworkRegistered {
+		t.Fatalf("libera state after removing oftc = %v", h.State)
+	}
+}
+
+func TestManagerRestart(t *testing.T) {
+	servers := make(chan *tightbeamtest.Server, 4)
+	mgr := tightbeam.NewManager(nil)
+
+	mgr.Add(tightbeam.NetworkConfig{
+		Name: "libera",
+		Connect: func(ctx context.Context) (tightbeam.Transport, error) {
+			srv := tightbeamtest.NewServer(t)
+			servers <- srv
+			return tightbeam.NewStreamTransport(srv.Conn()), nil
+		},
+		Client: tightbeam.ClientConfig{Nick: "bot"},
+	})
+	defer mgr.StopAll()
+
+	for n := 0; n < 3; n++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		if err := mgr.Start(ctx, "libera"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := mgr.Start(ctx, "libera"); err != nil {
+			t.Fatal(err)
+		}
+
+		srv := <-servers
+		srv.Register("bot")
+		cancel()
+	}
+
+	select {
+	case <-servers:
+		t.Fatal("a second Start() while running connected again")
+	default:
+	}
+}
@@ -0,0 +1,268 @@
+package tightbeam
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+const binaryMessageVersion = 1
+
+var ErrorBadBinaryMessage = errors.New("irc: Malformed binary message")
+
+func (t Tags) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *Tags) UnmarshalText(data []byte) error {
+	if len(data) == 0 {
+		*t = Tags{}
+		return nil
+	}
+
+	*t = ParseTags(string(data))
+	return nil
+}
+
+func (t Tags) MarshalJSON() ([]byte, error) {
+	ret := make(map[string]string, len(t))
+
+	for k, v := range t {
+		ret[k] = string(v)
+	}
+
+	return json.Marshal(ret)
+}
+
+func (t *Tags) UnmarshalJSON(data []byte) error {
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*t = Tags{}
+	for k, v := range raw {
+		(*t)[k] = TagVal(v)
+	}
+
+	return nil
+}
+
+type prefixJSON struct {
+	Name string `json:"name"`
+	User string `json:"user,omitempty"`
+	Host string `json:"host,omitempty"`
+}
+
+func (p Prefix) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Prefix) UnmarshalText(data []byte) error {
+	*p = *ParsePrefix(string(data))
+	return nil
+}
+
+func (p Prefix) MarshalJSON() ([]byte, error) {
+	return json.Marshal(prefixJSON{Name: p.Name, User: p.User, Host: p.Host})
+}
+
+func (p *Prefix) UnmarshalJSON(data []byte) error {
+	var raw prefixJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*p = Prefix{Name: raw.Name, User: raw.User, Host: raw.Host}
+	return nil
+}
+
+type messageJSON struct {
+	Tags    Tags     `json:"tags,omitempty"`
+	Prefix  *Prefix  `json:"prefix,omitempty"`
+	Command string   `json:"command"`
+	Params  []string `json:"params"`
+}
+
+func (m Message) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Message) UnmarshalText(data []byte) error {
+	parsed, err := ParseMessage(string(data))
+	if err != nil {
+		return err
+	}
+
+	*m = *parsed
+	return nil
+}
+
+// MarshalJSON encodes the message as an object of this form:
+//
+//	{
+//	  "tags":    {"time": "2024-01-01T00:00:00.000Z", "+draft/reply": "abc"},
+//	  "prefix":  {"name": "nick", "user": "user", "host": "host"},
+//	  "command": "PRIVMSG",
+//	  "params":  ["#channel", "hello world"]
+//	}
+//
+// Tag values are unescaped strings and valueless tags map to "". The tags
+// and prefix members are omitted when empty, as are prefix user and host.
+// Params is always present and the trailing parameter is not marked.
+func (m Message) MarshalJSON() ([]byte, error) {
+	raw := messageJSON{
+		Tags:    m.Tags,
+		Command: m.Command,
+		Params:  m.Params,
+	}
+
+	if m.Prefix != nil && m.Prefix.Name != "" {
+		raw.Prefix = m.Prefix
+	}
+
+	if raw.Params == nil {
+		raw.Params = []string{}
+	}
+
+	return json.Marshal(raw)
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var raw messageJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if raw.Command == "" {
+		return ErrorNoCommand
+	}
+
+	*m = Message{
+		Tags:    raw.Tags,
+		Prefix:  raw.Prefix,
+		Command: raw.Command,
+		Params:  raw.Params,
+	}
+
+	if m.Tags == nil {
+		m.Tags = Tags{}
+	}
+
+	if m.Prefix == nil {
+		m.Prefix = &Prefix{}
+	}
+
+	if len(m.Params) == 0 {
+		m.Params = nil
+	}
+
+	return nil
+}
+
+// MarshalBinary encodes the message as a version byte (currently 1) followed
+// by the tag count and each tag key and value in sorted key order, the
+// prefix name, user and host, the command, and the param count and each
+// param. Counts are uvarints and every string is a uvarint byte length
+// followed by the raw bytes, so any message round-trips exactly.
+func (m Message) MarshalBinary() ([]byte, error) {
+	buf := []byte{binaryMessageVersion}
+
+	keys := m.Tags.Keys()
+	buf = binary.AppendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendBinaryString(buf, k)
+		buf = appendBinaryString(buf, string(m.Tags[k]))
+	}
+
+	prefix := m.Prefix
+	if prefix == nil {
+		prefix = &Prefix{}
+	}
+
+	buf = appendBinaryString(buf, prefix.Name)
+	buf = appendBinaryString(buf, prefix.User)
+	buf = appendBinaryString(buf, prefix.Host)
+	buf = appendBinaryString(buf, m.Command)
+
+	buf = binary.AppendUvarint(buf, uint64(len(m.Params)))
+	for _, param := range m.Params {
+		buf = appendBinaryString(buf, param)
+	}
+
+	return buf, nil
+}
+
+func (m *Message) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != binaryMessageVersion {
+		return ErrorBadBinaryMessage
+	}
+
+	r := &binaryReader{data: data[1:]}
+
+	ret := Message{Tags: Tags{}, Prefix: &Prefix{}}
+
+	count := r.uvarint()
+	for n := uint64(0); n < count && r.err == nil; n++ {
+		k := r.string()
+		ret.Tags[k] = TagVal(r.string())
+	}
+
+	ret.Prefix.Name = r.string()
+	ret.Prefix.User = r.string()
+	ret.Prefix.Host = r.string()
+	ret.Command = r.string()
+
+	count = r.uvarint()
+	for n := uint64(0); n < count && r.err == nil; n++ {
+		ret.Params = append(ret.Params, r.string())
+	}
+
+	if r.err != nil || len(r.data) != 0 || ret.Command == "" {
+		return ErrorBadBinaryMessage
+	}
+
+	*m = ret
+	return nil
+}
+
+func appendBinaryString(buf []byte, v string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(v)))
+	return append(buf, v...)
+}
+
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = ErrorBadBinaryMessage
+		return 0
+	}
+
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) string() string {
+	l := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+
+	if l > uint64(len(r.data)) {
+		r.err = ErrorBadBinaryMessage
+		return ""
+	}
+
+	v := string(r.data[:l])
+	r.data = r.data[l:]
+	return v
+}
@@ -0,0 +1,99 @@
+package tightbeam_test
+
+import (
+	"encoding/json"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+var marshalSeeds = []string{
+	"PING",
+	"PING :",
+	":irc.example.com 001 nick :Welcome",
+	"@time=2024-01-01T00:00:00.000Z;+draft/reply=abc :nick!user@host PRIVMSG #chan :hello world",
+	"@a;b=\\s\\:\\\\ :nick CMD a b c",
+	":nick!user@host PRIVMSG #chan :caf\xe9",
+}
+
+func FuzzMarshalBinary(f *testing.F) {
+	for _, seed := range marshalSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		m, err := tightbeam.ParseMessage(line)
+		if err != nil {
+			return
+		}
+
+		data, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got tightbeam.Message
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+
+		if !got.Equal(m) {
+			t.Fatalf("%q: round trip gave %q", line, got.String())
+		}
+
+		for n := range data {
+			var short tightbeam.Message
+			if short.UnmarshalBinary(data[:n]) == nil {
+				t.Fatalf("%q: truncated to %d bytes decoded without error", line, n)
+			}
+		}
+	})
+}
+
+func FuzzMarshalJSON(f *testing.F) {
+	for _, seed := range marshalSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		m, err := tightbeam.ParseMessage(line)
+		if err != nil || !validUTF8(m) {
+			return
+		}
+
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got tightbeam.Message
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+
+		if !got.Equal(m) {
+			t.Fatalf("%q: round trip through %s gave %q", line, data, got.String())
+		}
+	})
+}
+
+func validUTF8(m *tightbeam.Message) bool {
+	strs := append([]string{m.Command}, m.Params...)
+
+	for k, v := range m.Tags {
+		strs = append(strs, k, string(v))
+	}
+
+	if m.Prefix != nil {
+		strs = append(strs, m.Prefix.Name, m.Prefix.User, m.Prefix.Host)
+	}
+
+	for _, v := range strs {
+		if !utf8.ValidString(v) {
+			return false
+		}
+	}
+
+	return true
+}
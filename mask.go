@@ -0,0 +1,242 @@
+package tightbeam
+
+import (
+	"net"
+	s "strings"
+)
+
+type Mask struct {
+	Name string
+	User string
+	Host string
+
+	ExtBan string
+}
+
+func ParseMask(v string) *Mask {
+	m := &Mask{}
+
+	switch {
+	case IsExtBan(v):
+		return &Mask{ExtBan: v}
+	case s.ContainsAny(v, "!@"):
+		p := ParsePrefix(v)
+		m.Name, m.User, m.Host = p.Name, p.User, p.Host
+
+		if !s.Contains(v, "!") && s.Contains(v, "@") {
+			m.Name, m.User = "", m.Name
+		}
+	case s.ContainsAny(v, ".:"):
+		m.Host = v
+	default:
+		m.Name = v
+	}
+
+	return m.Normalize()
+}
+
+func IsExtBan(v string) bool {
+	if len(v) < 2 || (v[0] != '$' && v[0] != '~') {
+		return false
+	}
+
+	name, _, _ := s.Cut(v[1:], ":")
+	name = s.TrimPrefix(name, "~")
+	if name == "" {
+		return false
+	}
+
+	for n := 0; n < len(name); n++ {
+		if c := name[n]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *Mask) Normalize() *Mask {
+	if m.ExtBan != "" {
+		return &Mask{ExtBan: m.ExtBan}
+	}
+
+	return &Mask{
+		Name: normalizeMaskPart(m.Name),
+		User: normalizeMaskPart(m.User),
+		Host: normalizeMaskPart(m.Host),
+	}
+}
+
+func normalizeMaskPart(v string) string {
+	for s.Contains(v, "**") {
+		v = s.ReplaceAll(v, "**", "*")
+	}
+
+	if v == "" {
+		return "*"
+	}
+
+	return v
+}
+
+func (m *Mask) String() string {
+	if m.ExtBan != "" {
+		return m.ExtBan
+	}
+
+	return m.Name + "!" + m.User + "@" + m.Host
+}
+
+func (m *Mask) Match(p *Prefix) bool {
+	return m.MatchCase(p, CaseMappingRFC1459)
+}
+
+func (m *Mask) MatchCase(p *Prefix, casemap CaseMapping) bool {
+	if p == nil || m.ExtBan != "" {
+		return false
+	}
+
+	return MatchWildcard(m.Name, p.Name, casemap) &&
+		MatchWildcard(m.User, p.User, casemap) &&
+		MatchWildcard(m.Host, p.Host, casemap)
+}
+
+func MatchWildcard(pattern, v string, casemap CaseMapping) bool {
+	return MatchWildcardExact(casemap.Fold(pattern), casemap.Fold(v))
+}
+
+func MatchWildcardExact(pattern, v string) bool {
+	px, vx := 0, 0
+	starPx, starVx := -1, -1
+
+	for vx < len(v) {
+		switch {
+		case px < len(pattern) && pattern[px] == '*':
+			starPx, starVx = px, vx
+			px++
+		case px < len(pattern) && (pattern[px] == '?' || pattern[px] == v[vx]):
+			px++
+			vx++
+		case starPx >= 0:
+			starVx++
+			px, vx = starPx+1, starVx
+		default:
+			return false
+		}
+	}
+
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+
+	return px == len(pattern)
+}
+
+type BanStyle int
+
+const (
+	BanHost BanStyle = iota
+	BanUserHost
+	BanNick
+	BanDomain
+	BanFull
+)
+
+func BanMask(p *Prefix, style BanStyle) *Mask {
+	m := &Mask{Name: "*", User: "*", Host: "*"}
+
+	user := p.User
+	if s.HasPrefix(user, "~") {
+		user = "*" + user[1:]
+	}
+
+	switch style {
+	case BanHost:
+		m.Host = p.Host
+	case BanUserHost:
+		m.User, m.Host = user, p.Host
+	case BanNick:
+		m.Name = p.Name
+	case BanDomain:
+		m.Host = domainMask(p.Host)
+	case BanFull:
+		m.Name, m.User, m.Host = p.Name, user, p.Host
+	}
+
+	return m.Normalize()
+}
+
+func domainMask(host string) string {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() != nil {
+			return host[:s.LastIndexByte(host, '.')+1] + "*"
+		}
+
+		return host[:s.LastIndexByte(host, ':')+1] + "*"
+	}
+
+	if s.Count(host, ".") < 2 {
+		return host
+	}
+
+	return "*" + host[s.IndexByte(host, '.'):]
+}
+
+func (i ISupport) ExtBan() (prefix string, types string, ok bool) {
+	v, ok := i.Get("EXTBAN")
+	if !ok {
+		return "", "", false
+	}
+
+	parts := s.SplitN(v, ",", 2)
+	if len(parts) < 2 {
+		return "", parts[0], true
+	}
+
+	return parts[0], parts[1], true
+}
+
+func ExtBanMask(prefix string, typ byte, value string) string {
+	ret := prefix + string(typ)
+	if value != "" {
+		ret += ":" + value
+	}
+
+	return ret
+}
+
+func AccountBan(isupport ISupport, account string) (string, bool) {
+	prefix, types, ok := isupport.ExtBan()
+	if !ok {
+		return "", false
+	}
+
+	for _, typ := range []byte{'a', 'R'} {
+		if s.IndexByte(types, typ) >= 0 {
+			return ExtBanMask(prefix, typ, account), true
+		}
+	}
+
+	return "", false
+}
+
+func ValidHostname(host string) bool {
+	if len(host) == 0 || len(host) > 255 || !s.Contains(host, ".") {
+		return false
+	}
+
+	for _, label := range s.Split(host, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for n := 0; n < len(label); n++ {
+			c := label[n]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,63 @@
+package tightbeam_test
+
+import (
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+func TestMatchWildcard(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		v       string
+		casemap tightbeam.CaseMapping
+		match   bool
+		exact   bool
+	}{
+		{"*", "", tightbeam.CaseMappingASCII, true, true},
+		{"?", "", tightbeam.CaseMappingASCII, false, false},
+		{"a*b?c*", "aXXbYcZZ", tightbeam.CaseMappingASCII, true, true},
+		{"a*b", "ac", tightbeam.CaseMappingASCII, false, false},
+		{"*.EXAMPLE.com", "host.example.com", tightbeam.CaseMappingASCII, true, false},
+		{"nick{x}", "NICK[X]", tightbeam.CaseMappingRFC1459, true, false},
+		{"nick{x}", "NICK[X]", tightbeam.CaseMappingASCII, false, false},
+		{"nick^", "NICK~", tightbeam.CaseMappingStrictRFC1459, false, false},
+		{"nick^", "NICK~", tightbeam.CaseMappingRFC1459, true, false},
+		{"Exact", "Exact", tightbeam.CaseMappingASCII, true, true},
+	} {
+		if match := tightbeam.MatchWildcard(tt.pattern, tt.v, tt.casemap); match != tt.match {
+			t.Errorf("MatchWildcard(%q, %q, %s) = %v, want %v", tt.pattern, tt.v, tt.casemap, match, tt.match)
+		}
+
+		if exact := tightbeam.MatchWildcardExact(tt.pattern, tt.v); exact != tt.exact {
+			t.Errorf("MatchWildcardExact(%q, %q) = %v, want %v", tt.pattern, tt.v, exact, tt.exact)
+		}
+	}
+}
+
+func TestParseMask(t *testing.T) {
+	for _, tt := range []struct {
+		v    string
+		want string
+	}{
+		{"nick", "nick!*@*"},
+		{"user@host", "*!user@host"},
+		{"*.example.com", "*!*@*.example.com"},
+		{"a!b", "a!b@*"},
+		{"a!**@", "a!*@*"},
+		{"$a:acct", "$a:acct"},
+	} {
+		if got := tightbeam.ParseMask(tt.v).String(); got != tt.want {
+			t.Errorf("ParseMask(%q) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+
+	p := tightbeam.ParsePrefix("Nick[x]!~user@host.example.com")
+	if !tightbeam.ParseMask("nick{X}").Match(p) || tightbeam.ParseMask("nick{X}").MatchCase(p, tightbeam.CaseMappingASCII) {
+		t.Error("Match() does not use the casemapping")
+	}
+
+	if tightbeam.ParseMask("$a:acct").Match(p) {
+		t.Error("extban matched a prefix")
+	}
+}
@@ -0,0 +1,140 @@
+package tightbeam
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+)
+
+var ErrorExpvarInUse = errors.New("irc: Expvar name is already published as a different type")
+
+var expvarLock sync.Mutex
+
+type Metrics interface {
+	MessageIn(command string, bytes int)
+	MessageOut(command string, bytes int)
+	ParseError()
+	SendQueue(depth int)
+	Lag(d time.Duration)
+	Reconnect()
+	HandlerLatency(command string, d time.Duration)
+}
+
+type NopMetrics struct{}
+
+func (NopMetrics) MessageIn(string, int)                {}
+func (NopMetrics) MessageOut(string, int)               {}
+func (NopMetrics) ParseError()                          {}
+func (NopMetrics) SendQueue(int)                        {}
+func (NopMetrics) Lag(time.Duration)                    {}
+func (NopMetrics) Reconnect()                           {}
+func (NopMetrics) HandlerLatency(string, time.Duration) {}
+
+type ExpvarMetrics struct {
+	vars *expvar.Map
+
+	messagesIn  *expvar.Map
+	messagesOut *expvar.Map
+	bytesIn     *expvar.Int
+	bytesOut    *expvar.Int
+	parseErrors *expvar.Int
+	sendQueue   *expvar.Int
+	lag         *expvar.Float
+	reconnects  *expvar.Int
+	handlerNs   *expvar.Map
+	handlerRuns *expvar.Map
+}
+
+func NewExpvarMetrics(name string) (*ExpvarMetrics, error) {
+	expvarLock.Lock()
+	defer expvarLock.Unlock()
+
+	var vars *expvar.Map
+
+	switch v := expvar.Get(name).(type) {
+	case nil:
+		vars = expvar.NewMap(name)
+	case *expvar.Map:
+		vars = v
+	default:
+		return nil, ErrorExpvarInUse
+	}
+
+	m := &ExpvarMetrics{vars: vars}
+
+	m.messagesIn = expvarMap(vars, "messages_in")
+	m.messagesOut = expvarMap(vars, "messages_out")
+	m.bytesIn = expvarInt(vars, "bytes_in")
+	m.bytesOut = expvarInt(vars, "bytes_out")
+	m.parseErrors = expvarInt(vars, "parse_errors")
+	m.sendQueue = expvarInt(vars, "send_queue")
+	m.reconnects = expvarInt(vars, "reconnects")
+	m.handlerNs = expvarMap(vars, "handler_ns")
+	m.handlerRuns = expvarMap(vars, "handler_calls")
+
+	m.lag, _ = vars.Get("lag_seconds").(*expvar.Float)
+	if m.lag == nil {
+		m.lag = new(expvar.Float)
+		vars.Set("lag_seconds", m.lag)
+	}
+
+	return m, nil
+}
+
+func (m *ExpvarMetrics) Vars() *expvar.Map {
+	return m.vars
+}
+
+func (m *ExpvarMetrics) MessageIn(command string, bytes int) {
+	m.messagesIn.Add(command, 1)
+	m.bytesIn.Add(int64(bytes))
+}
+
+func (m *ExpvarMetrics) MessageOut(command string, bytes int) {
+	m.messagesOut.Add(command, 1)
+	m.bytesOut.Add(int64(bytes))
+}
+
+func (m *ExpvarMetrics) ParseError() {
+	m.parseErrors.Add(1)
+}
+
+func (m *ExpvarMetrics) SendQueue(depth int) {
+	m.sendQueue.Set(int64(depth))
+}
+
+func (m *ExpvarMetrics) Lag(d time.Duration) {
+	m.lag.Set(d.Seconds())
+}
+
+func (m *ExpvarMetrics) Reconnect() {
+	m.reconnects.Add(1)
+}
+
+func (m *ExpvarMetrics) HandlerLatency(command string, d time.Duration) {
+	m.handlerNs.Add(command, int64(d))
+	m.handlerRuns.Add(command, 1)
+}
+
+func expvarMap(parent *expvar.Map, key string) *expvar.Map {
+	if v, ok := parent.Get(key).(*expvar.Map); ok {
+		return v
+	}
+
+	v := new(expvar.Map).Init()
+	parent.Set(key, v)
+
+	return v
+}
+
+func expvarInt(parent *expvar.Map, key string) *expvar.Int {
+	if v, ok := parent.Get(key).(*expvar.Int); ok {
+		return v
+	}
+
+	v := new(expvar.Int)
+	parent.Set(key, v)
+
+	return v
+}
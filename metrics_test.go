@@ -0,0 +1,210 @@
+package tightbeam_test
+
+import (
+	"context"
+	"expvar"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	name := "tightbeam_test_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	m, err := tightbeam.NewExpvarMetrics(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.MessageIn("PRIVMSG", 20)
+	m.MessageIn("PRIVMSG", 30)
+	m.MessageIn("PING", 8)
+	m.MessageOut("PONG", 8)
+	m.ParseError()
+	m.SendQueue(3)
+	m.SendQueue(1)
+	m.Lag(1500 * time.Millisecond)
+	m.Reconnect()
+	m.Reconnect()
+	m.HandlerLatency("PRIVMSG", 2*time.Millisecond)
+	m.HandlerLatency("PRIVMSG", 3*time.Millisecond)
+
+	for _, tt := range []struct {
+		path []string
+		want string
+	}{
+		{[]string{"messages_in", "PRIVMSG"}, "2"},
+		{[]string{"messages_in", "PING"}, "1"},
+		{[]string{"messages_out", "PONG"}, "1"},
+		{[]string{"bytes_in"}, "58"},
+		{[]string{"bytes_out"}, "8"},
+		{[]string{"parse_errors"}, "1"},
+		{[]string{"send_queue"}, "1"},
+		{[]string{"lag_seconds"}, "1.5"},
+		{[]string{"reconnects"}, "2"},
+		{[]string{"handler_ns", "PRIVMSG"}, "5000000"},
+		{[]string{"handler_calls", "PRIVMSG"}, "2"},
+	} {
+		if got := expvarValue(m.Vars(), tt.path...); got != tt.want {
+			t.Errorf("%v = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+
+	again, err := tightbeam.NewExpvarMetrics(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again.Reconnect()
+	if got := expvarValue(m.Vars(), "reconnects"); got != "3" || again.Vars() != m.Vars() {
+		t.Fatalf("reused metrics do not share counters: reconnects = %s", got)
+	}
+
+	expvar.NewInt(name + "_int")
+	if _, err := tightbeam.NewExpvarMetrics(name + "_int"); err != tightbeam.ErrorExpvarInUse {
+		t.Fatalf("NewExpvarMetrics() error = %v, want ErrorExpvarInUse", err)
+	}
+}
+
+func expvarValue(m *expvar.Map, path ...string) string {
+	var v expvar.Var = m
+
+	for _, key := range path {
+		parent, ok := v.(*expvar.Map)
+		if !ok {
+			return ""
+		}
+
+		if v = parent.Get(key); v == nil {
+			return ""
+		}
+	}
+
+	return v.String()
+}
+
+type countingMetrics struct {
+	lock     sync.Mutex
+	in       map[string]int
+	out      map[string]int
+	bytesIn  int
+	parse    int
+	handled  map[string]int
+	lag      time.Duration
+	maxQueue int
+}
+
+func (m *countingMetrics) MessageIn(command string, bytes int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.in[command]++
+	m.bytesIn += bytes
+}
+
+func (m *countingMetrics) MessageOut(command string, bytes int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.out[command]++
+}
+
+func (m *countingMetrics) ParseError() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.parse++
+}
+
+func (m *countingMetrics) SendQueue(depth int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if depth > m.maxQueue {
+		m.maxQueue = depth
+	}
+}
+
+func (m *countingMetrics) Lag(d time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.lag = d
+}
+
+func (m *countingMetrics) Reconnect() {}
+
+func (m *countingMetrics) HandlerLatency(command string, d time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.handled[command]++
+}
+
+func TestClientMetrics(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	m := &countingMetrics{in: map[string]int{}, out: map[string]int{}, handled: map[string]int{}}
+	c := tightbeam.NewClient(a, tightbeam.ClientConfig{
+		Nick:         "me",
+		PingInterval: 10 * time.Millisecond,
+		Metrics:      m,
+		Handler:      tightbeam.HandlerFunc(func(*tightbeam.Client, *tightbeam.Message) {}),
+	})
+
+	done := make(chan struct{})
+	go func() {
+		c.Run(context.Background())
+		close(done)
+	}()
+
+	r, w := tightbeam.NewReader(b), tightbeam.NewWriter(b)
+	r.ReadLine()
+	r.ReadLine()
+	w.WriteLine(":srv 001 me :Welcome")
+	w.WriteLine("@@@")
+	w.WriteLine(":alice!a@h PRIVMSG me :hi")
+
+	ping, err := r.ReadMessage()
+	if err != nil || ping.Command != "PING" {
+		t.Fatalf("read %v, %v, want a lag PING", ping, err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	w.WriteLine(":srv PONG srv :" + ping.Trailing())
+	w.WriteLine("PING :sync")
+	for {
+		if reply, _ := r.ReadMessage(); reply == nil || reply.Command == "PONG" {
+			break
+		}
+	}
+
+	c.Close()
+	<-done
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.in["001"] != 1 || m.in["PRIVMSG"] != 1 || m.in["PONG"] != 1 || m.bytesIn == 0 {
+		t.Errorf("MessageIn counts = %v, %d bytes", m.in, m.bytesIn)
+	}
+
+	if m.out["NICK"] != 1 || m.out["USER"] != 1 || m.out["PING"] == 0 || m.out["PONG"] != 1 {
+		t.Errorf("MessageOut counts = %v", m.out)
+	}
+
+	if m.parse != 1 {
+		t.Errorf("ParseError count = %d, want 1", m.parse)
+	}
+
+	if m.handled["PRIVMSG"] != 1 {
+		t.Errorf("HandlerLatency counts = %v", m.handled)
+	}
+
+	if m.lag < 5*time.Millisecond || m.lag != c.Lag() {
+		t.Errorf("Lag = %v, client Lag() = %v", m.lag, c.Lag())
+	}
+
+	if m.maxQueue == 0 {
+		t.Error("SendQueue never reported a depth")
+	}
+}
@@ -0,0 +1,311 @@
+package tightbeam
+
+import (
+	"strconv"
+	s "strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+const (
+	CapMultiline = "draft/multiline"
+
+	TagBatch           = "batch"
+	TagMultilineConcat = "draft/multiline-concat"
+
+	DefaultMultilineLineBytes = 400
+)
+
+var batchRef uint64
+
+func NewBatchRef() string {
+	return strconv.FormatUint(atomic.AddUint64(&batchRef, 1), 36)
+}
+
+func ParseCapValue(v string) map[string]string {
+	ret := map[string]string{}
+
+	for _, item := range s.Split(v, ",") {
+		if item == "" {
+			continue
+		}
+
+		parts := s.SplitN(item, "=", 2)
+		if len(parts) < 2 {
+			ret[parts[0]] = ""
+			continue
+		}
+
+		ret[parts[0]] = parts[1]
+	}
+
+	return ret
+}
+
+type MultilineLimits struct {
+	MaxBytes int
+	MaxLines int
+}
+
+func ParseMultilineLimits(capValue string) MultilineLimits {
+	ret := MultilineLimits{}
+	vals := ParseCapValue(capValue)
+
+	ret.MaxBytes, _ = strconv.Atoi(vals["max-bytes"])
+	ret.MaxLines, _ = strconv.Atoi(vals["max-lines"])
+
+	return ret
+}
+
+type Multiline struct {
+	Enabled      bool
+	Limits       MultilineLimits
+	MaxLineBytes int
+}
+
+func (ml *Multiline) HandleCap(m *Message) {
+	if m.Command != "CAP" || len(m.Params) < 3 {
+		return
+	}
+
+	subcommand := s.ToUpper(m.Params[1])
+
+	for _, item := range s.Fields(m.Trailing()) {
+		name, value, _ := s.Cut(item, "=")
+
+		switch subcommand {
+		case "LS", "NEW":
+			if name == CapMultiline {
+				ml.Limits = ParseMultilineLimits(value)
+			}
+		case "ACK":
+			if s.TrimPrefix(name, "-") == CapMultiline {
+				ml.Enabled = name[0] != '-'
+			}
+		case "DEL":
+			if name == CapMultiline {
+				ml.Enabled = false
+			}
+		}
+	}
+}
+
+type multilineChunk struct {
+	text   string
+	concat bool
+}
+
+func (ml *Multiline) Split(command, target, text string) []*Message {
+	lineBytes := ml.MaxLineBytes
+	if lineBytes <= 0 {
+		lineBytes = DefaultMultilineLineBytes
+	}
+
+	if ml.Enabled && ml.Limits.MaxBytes > 0 && ml.Limits.MaxBytes < lineBytes {
+		lineBytes = ml.Limits.MaxBytes
+	}
+
+	var chunks []multilineChunk
+
+	for _, line := range s.Split(s.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		for n, part := range splitLine(line, lineBytes) {
+			chunks = append(chunks, multilineChunk{text: part, concat: n > 0})
+		}
+	}
+
+	if !ml.Enabled {
+		var ret []*Message
+
+		for _, c := range chunks {
+			if c.text == "" {
+				continue
+			}
+
+			ret = append(ret, &Message{
+				Command: command,
+				Params:  []string{target, c.text},
+			})
+		}
+
+		return ret
+	}
+
+	var ret []*Message
+	var batch []multilineChunk
+	size := 0
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ret = append(ret, ml.batch(command, target, batch)...)
+		batch, size = nil, 0
+	}
+
+	for _, c := range chunks {
+		add := len(c.text)
+		if len(batch) > 0 && !c.concat {
+			add++
+		}
+
+		if len(batch) > 0 &&
+			((ml.Limits.MaxBytes > 0 && size+add > ml.Limits.MaxBytes) ||
+				(ml.Limits.MaxLines > 0 && len(batch)+1 > ml.Limits.MaxLines)) {
+			flush()
+			add = len(c.text)
+		}
+
+		if len(batch) == 0 {
+			c.concat = false
+		}
+
+		batch = append(batch, c)
+		size += add
+	}
+
+	flush()
+
+	return ret
+}
+
+func (ml *Multiline) batch(command, target string, chunks []multilineChunk) []*Message {
+	for len(chunks) > 0 && chunks[len(chunks)-1].text == "" {
+		chunks = chunks[:len(chunks)-1]
+	}
+
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	if len(chunks) == 1 {
+		return []*Message{{
+			Command: command,
+			Params:  []string{target, chunks[0].text},
+		}}
+	}
+
+	ref := NewBatchRef()
+
+	ret := []*Message{{
+		Command: "BATCH",
+		Params:  []string{"+" + ref, CapMultiline, target},
+	}}
+
+	for _, c := range chunks {
+		m := &Message{
+			Tags:    Tags{TagBatch: TagVal(ref)},
+			Command: command,
+			Params:  []string{target, c.text},
+		}
+
+		if c.concat {
+			m.Tags[TagMultilineConcat] = ""
+		}
+
+		ret = append(ret, m)
+	}
+
+	return append(ret, &Message{
+		Command: "BATCH",
+		Params:  []string{"-" + ref},
+	})
+}
+
+func splitLine(line string, max int) []string {
+	var ret []string
+
+	for len(line) > max {
+		cut := max
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		if cut == 0 {
+			cut = max
+		}
+
+		if sp := s.LastIndexByte(line[:cut], ' '); sp > 0 {
+			cut = sp + 1
+		}
+
+		ret = append(ret, line[:cut])
+		line = line[cut:]
+	}
+
+	return append(ret, line)
+}
+
+type multilineBatch struct {
+	start *Message
+	lines []*Message
+}
+
+type MultilineAssembler struct {
+	batches map[string]*multilineBatch
+}
+
+func (a *MultilineAssembler) Handle(m *Message) (*Message, bool) {
+	if m.Command == "BATCH" && len(m.Params) > 0 && len(m.Params[0]) > 1 {
+		ref := m.Params[0][1:]
+
+		switch m.Params[0][0] {
+		case '+':
+			if len(m.Params) < 3 || m.Params[1] != CapMultiline {
+				return m, true
+			}
+
+			if a.batches == nil {
+				a.batches = map[string]*multilineBatch{}
+			}
+
+			a.batches[ref] = &multilineBatch{start: m}
+
+			return nil, false
+		case '-':
+			b, ok := a.batches[ref]
+			if !ok {
+				return m, true
+			}
+
+			delete(a.batches, ref)
+
+			ret := b.assemble()
+			return ret, ret != nil
+		}
+	}
+
+	if ref, ok := m.GetTag(TagBatch); ok {
+		if b, ok := a.batches[ref]; ok {
+			b.lines = append(b.lines, m)
+			return nil, false
+		}
+	}
+
+	return m, true
+}
+
+func (b *multilineBatch) assemble() *Message {
+	if len(b.lines) == 0 {
+		return nil
+	}
+
+	buf := &s.Builder{}
+
+	for n, line := range b.lines {
+		if _, concat := line.GetTag(TagMultilineConcat); n > 0 && !concat {
+			buf.WriteByte('\n')
+		}
+
+		buf.WriteString(line.Trailing())
+	}
+
+	ret := &Message{
+		Tags:    b.start.Tags.Copy(),
+		Prefix:  b.start.Prefix.Copy(),
+		Command: b.lines[0].Command,
+		Params:  []string{b.start.Params[2], buf.String()},
+	}
+
+	return ret
+}
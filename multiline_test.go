@@ -0,0 +1,206 @@
+package tightbeam_test
+
+import (
+	"context"
+	s "strings"
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+func splitLines(ml *tightbeam.Multiline, text string) []string {
+	var ret []string
+
+	for _, m := range ml.Split("PRIVMSG", "#c", text) {
+		if m.Command == "BATCH" {
+			if s.HasPrefix(m.Params[0], "+") {
+				ret = append(ret, "BATCH + "+s.Join(m.Params[1:], " "))
+			} else {
+				ret = append(ret, "BATCH -")
+			}
+			continue
+		}
+
+		line := m.Params[1]
+		if _, ok := m.GetTag(tightbeam.TagMultilineConcat); ok {
+			line = "concat " + line
+		}
+		if _, ok := m.GetTag(tightbeam.TagBatch); ok {
+			line = "batch " + line
+		}
+
+		ret = append(ret, line)
+	}
+
+	return ret
+}
+
+func TestMultilineSplit(t *testing.T) {
+	long := s.Repeat("word ", 100)
+
+	for _, tt := range []struct {
+		name string
+		ml   tightbeam.Multiline
+		text string
+		want []string
+	}{
+		{"disabled", tightbeam.Multiline{}, "a\r\nb\n\nc", []string{"a", "b", "c"}},
+		{"disabled long", tightbeam.Multiline{MaxLineBytes: 200}, long, []string{long[:200], long[200:400], long[400:]}},
+		{"single line", tightbeam.Multiline{Enabled: true}, "hello", []string{"hello"}},
+		{"batch", tightbeam.Multiline{Enabled: true}, "a\nb\n\nc\n", []string{"BATCH + draft/multiline #c", "batch a", "batch b", "batch ", "batch c", "BATCH -"}},
+		{"concat", tightbeam.Multiline{Enabled: true, MaxLineBytes: 10}, "aaaa bbbb cccc", []string{"BATCH + draft/multiline #c", "batch aaaa bbbb ", "batch concat cccc", "BATCH -"}},
+		{"max lines", tightbeam.Multiline{Enabled: true, Limits: tightbeam.MultilineLimits{MaxLines: 2}}, "a\nb\nc", []string{"BATCH + draft/multiline #c", "batch a", "batch b", "BATCH -", "c"}},
+		{"max bytes", tightbeam.Multiline{Enabled: true, Limits: tightbeam.MultilineLimits{MaxBytes: 5}}, "ab\ncd\nef", []string{"BATCH + draft/multiline #c", "batch ab", "batch cd", "BATCH -", "ef"}},
+		{"max bytes below line bytes", tightbeam.Multiline{Enabled: true, Limits: tightbeam.MultilineLimits{MaxBytes: 5}}, "abcdefghijkl", []string{"abcde", "fghij", "kl"}},
+	} {
+		got := splitLines(&tt.ml, tt.text)
+
+		if s.Join(got, "|") != s.Join(tt.want, "|") {
+			t.Errorf("%s: Split(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMultilineSplitMaxBytes(t *testing.T) {
+	ml := &tightbeam.Multiline{Enabled: true, Limits: tightbeam.MultilineLimits{MaxBytes: 50, MaxLines: 10}}
+
+	for _, m := range ml.Split("PRIVMSG", "#c", s.Repeat("x", 120)+"\n"+s.Repeat("y ", 70)) {
+		if m.Command != "BATCH" && len(m.Params[1]) > 50 {
+			t.Fatalf("line of %d bytes exceeds max-bytes 50: %q", len(m.Params[1]), m.Params[1])
+		}
+	}
+}
+
+func TestMultilineRoundTrip(t *testing.T) {
+	ml := &tightbeam.Multiline{Enabled: true, MaxLineBytes: 16}
+	text := "first line\nsecond line that is long enough to wrap\n\nlast"
+
+	a := &tightbeam.MultilineAssembler{}
+	prefix := tightbeam.ParsePrefix("alice!a@host")
+
+	var got *tightbeam.Message
+	for _, m := range ml.Split("PRIVMSG", "#c", text) {
+		m.Prefix = prefix
+
+		if ret, ok := a.Handle(tightbeam.MustParseMessage(m.String())); ok {
+			if got != nil {
+				t.Fatalf("assembler returned a second message %q", ret)
+			}
+			got = ret
+		}
+	}
+
+	if got == nil || got.Command != "PRIVMSG" || got.Params[0] != "#c" || got.Trailing() != text || got.Prefix.Name != "alice" {
+		t.Fatalf("assembled %v", got)
+	}
+}
+
+func TestMultilineAssembler(t *testing.T) {
+	a := &tightbeam.MultilineAssembler{}
+
+	for _, tt := range []struct {
+		line string
+		want string
+	}{
+		{"PRIVMSG #c :plain", "PRIVMSG #c plain"},
+		{"BATCH +x chathistory #c", "BATCH +x chathistory #c"},
+		{"@batch=x PRIVMSG #c :other batch", "@batch=x PRIVMSG #c :other batch"},
+		{"BATCH -x", "BATCH -x"},
+		{"@time=1 :n!u@h BATCH +m draft/multiline #c", ""},
+		{"@batch=m :n!u@h PRIVMSG #c :one", ""},
+		{"@batch=m;draft/multiline-concat :n!u@h PRIVMSG #c :two", ""},
+		{"@batch=m :n!u@h PRIVMSG #c :three", ""},
+		{"BATCH -m", "@time=1 :n!u@h PRIVMSG #c :onetwo\nthree"},
+		{"BATCH +e draft/multiline #c", ""},
+		{"BATCH -e", ""},
+	} {
+		m, ok := a.Handle(tightbeam.MustParseMessage(tt.line))
+
+		got := ""
+		if ok {
+			got = m.String()
+		}
+
+		if got != tt.want && !(ok && m.Equal(tightbeam.MustParseMessage(tt.want))) {
+			t.Errorf("Handle(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestMultilineHandleCap(t *testing.T) {
+	ml := &tightbeam.Multiline{}
+
+	for _, tt := range []struct {
+		line    string
+		enabled bool
+		limits  tightbeam.MultilineLimits
+	}{
+		{"CAP * LS :batch draft/multiline=max-bytes=4096,max-lines=24", false, tightbeam.MultilineLimits{MaxBytes: 4096, MaxLines: 24}},
+		{"CAP * ACK :batch draft/multiline", true, tightbeam.MultilineLimits{MaxBytes: 4096, MaxLines: 24}},
+		{"CAP * NEW :draft/multiline=max-bytes=1024", true, tightbeam.MultilineLimits{MaxBytes: 1024}},
+		{"CAP * ACK :-draft/multiline", false, tightbeam.MultilineLimits{MaxBytes: 1024}},
+		{"CAP * ACK draft/multiline", true, tightbeam.MultilineLimits{MaxBytes: 1024}},
+		{"CAP * DEL :draft/multiline", false, tightbeam.MultilineLimits{MaxBytes: 1024}},
+		{"PRIVMSG * ACK draft/multiline", false, tightbeam.MultilineLimits{MaxBytes: 1024}},
+	} {
+		ml.HandleCap(tightbeam.MustParseMessage(tt.line))
+
+		if ml.Enabled != tt.enabled || ml.Limits != tt.limits {
+			t.Errorf("after %q: Enabled %v, Limits %+v, want %v, %+v", tt.line, ml.Enabled, ml.Limits, tt.enabled, tt.limits)
+		}
+	}
+}
+
+func TestClientMultiline(t *testing.T) {
+	srv := tightbeamtest.NewServer(t)
+	received := make(chan *tightbeam.Message, 1)
+
+	c := tightbeam.NewClient(srv.Conn(), tightbeam.ClientConfig{
+		Nick:      "bot",
+		Multiline: true,
+		Handler: tightbeam.HandlerFunc(func(c *tightbeam.Client, m *tightbeam.Message) {
+			if m.Command == "PRIVMSG" {
+				received <- m
+			}
+		}),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	srv.Expect("CAP LS 302")
+	srv.Expect("NICK bot")
+	srv.Expect("USER * * * *")
+	srv.Send(":srv CAP * LS * :multi-prefix batch")
+	srv.Send(":srv CAP * LS :draft/multiline=max-bytes=4096,max-lines=24")
+	srv.Expect("CAP REQ :batch draft/multiline")
+	srv.Send(":srv CAP * ACK :batch draft/multiline")
+	srv.Expect("CAP END")
+	srv.Numeric(tightbeam.RPL_WELCOME, "bot", "Welcome")
+
+	go c.SendMultiline("PRIVMSG", "#c", "one\ntwo")
+	start := srv.Expect("BATCH * draft/multiline #c")
+	ref := start.Params[0][1:]
+	srv.Expect("@batch=" + ref + " PRIVMSG #c one")
+	srv.Expect("@batch=" + ref + " PRIVMSG #c two")
+	srv.Expect("BATCH -" + ref)
+
+	srv.Send(":alice!a@h BATCH +r draft/multiline #c")
+	srv.Send("@batch=r :alice!a@h PRIVMSG #c :hello")
+	srv.Send("@batch=r :alice!a@h PRIVMSG #c :world")
+	srv.Send(":alice!a@h BATCH -r")
+
+	if m := <-received; m.Trailing() != "hello\nworld" {
+		t.Fatalf("handler got %q", m.Trailing())
+	}
+
+	srv.Send(":srv CAP bot DEL :draft/multiline")
+	srv.Send("PING :sync")
+	srv.Expect("PONG sync")
+
+	go c.SendMultiline("PRIVMSG", "#c", "one\ntwo")
+	srv.Expect("PRIVMSG #c one")
+	srv.Expect("PRIVMSG #c two")
+}
@@ -0,0 +1,77 @@
+package tightbeam
+
+const (
+	RPL_WELCOME  = "001"
+	RPL_YOURHOST = "002"
+	RPL_CREATED  = "003"
+	RPL_MYINFO   = "004"
+	RPL_ISUPPORT = "005"
+
+	RPL_UMODEIS = "221"
+
+	RPL_AWAY          = "301"
+	RPL_ISON          = "303"
+	RPL_WHOISREGNICK  = "307"
+	RPL_WHOISUSER     = "311"
+	RPL_WHOISSERVER   = "312"
+	RPL_WHOISOPERATOR = "313"
+	RPL_ENDOFWHO      = "315"
+	RPL_WHOISIDLE     = "317"
+	RPL_ENDOFWHOIS    = "318"
+	RPL_WHOISCHANNELS = "319"
+	RPL_CHANNELMODEIS = "324"
+	RPL_CREATIONTIME  = "329"
+	RPL_WHOISACCOUNT  = "330"
+	RPL_NOTOPIC       = "331"
+	RPL_TOPIC         = "332"
+	RPL_TOPICWHOTIME  = "333"
+	RPL_WHOISACTUALLY = "338"
+	RPL_WHOREPLY      = "352"
+	RPL_NAMREPLY      = "353"
+	RPL_WHOSPCRPL     = "354"
+	RPL_ENDOFNAMES    = "366"
+	RPL_BANLIST       = "367"
+	RPL_ENDOFBANLIST  = "368"
+	RPL_MOTD          = "372"
+	RPL_MOTDSTART     = "375"
+	RPL_ENDOFMOTD     = "376"
+
+	ERR_NOSUCHNICK        = "401"
+	ERR_NOSUCHSERVER      = "402"
+	ERR_NOSUCHCHANNEL     = "403"
+	ERR_CANNOTSENDTOCHAN  = "404"
+	ERR_NORECIPIENT       = "411"
+	ERR_NOTEXTTOSEND      = "412"
+	ERR_UNKNOWNCOMMAND    = "421"
+	ERR_NOMOTD            = "422"
+	ERR_NONICKNAMEGIVEN   = "431"
+	ERR_ERRONEUSNICKNAME  = "432"
+	ERR_NICKNAMEINUSE     = "433"
+	ERR_USERNOTINCHANNEL  = "441"
+	ERR_NOTONCHANNEL      = "442"
+	ERR_NOTREGISTERED     = "451"
+	ERR_NEEDMOREPARAMS    = "461"
+	ERR_ALREADYREGISTERED = "462"
+	ERR_PASSWDMISMATCH    = "464"
+	ERR_CHANNELISFULL     = "471"
+	ERR_UNKNOWNMODE       = "472"
+	ERR_BANNEDFROMCHAN    = "474"
+	ERR_BADCHANNELKEY     = "475"
+	ERR_BADCHANMASK       = "476"
+	ERR_CHANOPRIVSNEEDED  = "482"
+	ERR_UMODEUNKNOWNFLAG  = "501"
+	ERR_USERSDONTMATCH    = "502"
+
+	RPL_LOGON       = "600"
+	RPL_LOGOFF      = "601"
+	RPL_WATCHOFF    = "602"
+	RPL_NOWON       = "604"
+	RPL_NOWOFF      = "605"
+	RPL_WHOISSECURE = "671"
+
+	RPL_MONONLINE    = "730"
+	RPL_MONOFFLINE   = "731"
+	RPL_MONLIST      = "732"
+	RPL_ENDOFMONLIST = "733"
+	ERR_MONLISTFULL  = "734"
+)
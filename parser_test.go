@@ -0,0 +1,207 @@
+package tightbeam_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	s "strings"
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+type parserAtoms struct {
+	Tags   map[string]string `json:"tags"`
+	Source *string           `json:"source"`
+	Verb   string            `json:"verb"`
+	Params []string          `json:"params"`
+}
+
+func (a parserAtoms) message() *tightbeam.Message {
+	m := &tightbeam.Message{Command: a.Verb, Params: a.Params}
+
+	if a.Source != nil {
+		m.Prefix = tightbeam.ParsePrefix(*a.Source)
+	}
+
+	if a.Tags != nil {
+		m.Tags = tightbeam.Tags{}
+		for k, v := range a.Tags {
+			m.Tags[k] = tightbeam.TagVal(v)
+		}
+	}
+
+	return m
+}
+
+func loadParserTests(t *testing.T, name string, v any) {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "parser-tests", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
+
+func TestParserMsgSplit(t *testing.T) {
+	var data struct {
+		Tests []struct {
+			Input string      `json:"input"`
+			Atoms parserAtoms `json:"atoms"`
+		} `json:"tests"`
+	}
+	loadParserTests(t, "msg-split", &data)
+
+	for _, tt := range data.Tests {
+		m, err := tightbeam.ParseMessage(tt.Input)
+		if err != nil {
+			t.Errorf("ParseMessage(%q) = %v", tt.Input, err)
+			continue
+		}
+
+		if !s.EqualFold(m.Command, tt.Atoms.Verb) {
+			t.Errorf("ParseMessage(%q) verb = %q, want %q", tt.Input, m.Command, tt.Atoms.Verb)
+		}
+
+		if want := tt.Atoms.message().Prefix; !m.Prefix.Equal(want) {
+			t.Errorf("ParseMessage(%q) source = %+v, want %+v", tt.Input, m.Prefix, want)
+		}
+
+		if len(m.Params) != len(tt.Atoms.Params) || (len(m.Params) > 0 && !reflect.DeepEqual(m.Params, tt.Atoms.Params)) {
+			t.Errorf("ParseMessage(%q) params = %q, want %q", tt.Input, m.Params, tt.Atoms.Params)
+		}
+
+		if want := tt.Atoms.message().Tags; !m.Tags.Equal(want) {
+			t.Errorf("ParseMessage(%q) tags = %q, want %q", tt.Input, m.Tags, want)
+		}
+	}
+}
+
+func TestParserMsgJoin(t *testing.T) {
+	var data struct {
+		Tests []struct {
+			Desc    string      `json:"desc"`
+			Atoms   parserAtoms `json:"atoms"`
+			Matches []string    `json:"matches"`
+		} `json:"tests"`
+	}
+	loadParserTests(t, "msg-join", &data)
+
+	for _, tt := range data.Tests {
+		line := tt.Atoms.message().String()
+
+		found := false
+		for _, match := range tt.Matches {
+			found = found || line == match
+		}
+
+		if !found {
+			t.Errorf("%s: String() = %q, want one of %q", tt.Desc, line, tt.Matches)
+		}
+	}
+}
+
+func TestParserUserhostSplit(t *testing.T) {
+	var data struct {
+		Tests []struct {
+			Source string `json:"source"`
+			Atoms  struct {
+				Nick string `json:"nick"`
+				User string `json:"user"`
+				Host string `json:"host"`
+			} `json:"atoms"`
+		} `json:"tests"`
+	}
+	loadParserTests(t, "userhost-split", &data)
+
+	for _, tt := range data.Tests {
+		p := tightbeam.ParsePrefix(tt.Source)
+		if want := (tightbeam.Prefix{Name: tt.Atoms.Nick, User: tt.Atoms.User, Host: tt.Atoms.Host}); *p != want {
+			t.Errorf("ParsePrefix(%q) = %+v, want %+v", tt.Source, *p, want)
+		}
+
+		if p.String() != tt.Source {
+			t.Errorf("ParsePrefix(%q).String() = %q", tt.Source, p.String())
+		}
+	}
+}
+
+func TestParserTagVal(t *testing.T) {
+	for _, tt := range []struct {
+		raw, value string
+	}{
+		{"", ""},
+		{"value", "value"},
+		{"a\\sb", "a b"},
+		{"\\:", ";"},
+		{"\\\\", "\\"},
+		{"\\r\\n", "\r\n"},
+		{"\\\\\\s\\:\\r\\n", "\\ ;\r\n"},
+	} {
+		if v := tightbeam.ParseTagVal(tt.raw); string(v) != tt.value {
+			t.Errorf("ParseTagVal(%q) = %q, want %q", tt.raw, v, tt.value)
+		}
+
+		if raw := tightbeam.TagVal(tt.value).Encode(); raw != tt.raw {
+			t.Errorf("TagVal(%q).Encode() = %q, want %q", tt.value, raw, tt.raw)
+		}
+	}
+
+	for _, tt := range []struct {
+		raw, value string
+	}{
+		{"value\\1", "value1"},
+		{"value1\\", "value1"},
+		{"value\\\\ntest", "value\\ntest"},
+	} {
+		if v := tightbeam.ParseTagVal(tt.raw); string(v) != tt.value {
+			t.Errorf("ParseTagVal(%q) = %q, want %q", tt.raw, v, tt.value)
+		}
+	}
+}
+
+func TestParserMaskMatch(t *testing.T) {
+	var data struct {
+		Tests []struct {
+			Mask    string   `json:"mask"`
+			Matches []string `json:"matches"`
+			Fails   []string `json:"fails"`
+		} `json:"tests"`
+	}
+	loadParserTests(t, "mask-match", &data)
+
+	for _, tt := range data.Tests {
+		for _, v := range tt.Matches {
+			if !tightbeam.MatchWildcard(tt.Mask, v, tightbeam.CaseMappingRFC1459) {
+				t.Errorf("MatchWildcard(%q, %q) = false, want true", tt.Mask, v)
+			}
+		}
+
+		for _, v := range tt.Fails {
+			if tightbeam.MatchWildcard(tt.Mask, v, tightbeam.CaseMappingRFC1459) {
+				t.Errorf("MatchWildcard(%q, %q) = true, want false", tt.Mask, v)
+			}
+		}
+	}
+}
+
+func TestParserValidateHostname(t *testing.T) {
+	var data struct {
+		Tests []struct {
+			Host  string `json:"host"`
+			Valid bool   `json:"valid"`
+		} `json:"tests"`
+	}
+	loadParserTests(t, "validate-hostname", &data)
+
+	for _, tt := range data.Tests {
+		if valid := tightbeam.ValidHostname(tt.Host); valid != tt.Valid {
+			t.Errorf("ValidHostname(%q) = %v, want %v", tt.Host, valid, tt.Valid)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package tightbeam
+
+import (
+	s "strings"
+	"sync"
+)
+
+const presenceLineBytes = 400
+
+type PresenceMode int
+
+const (
+	PresenceISON PresenceMode = iota
+	PresenceMonitor
+	PresenceWatch
+)
+
+type PresenceEvent struct {
+	Nick   string
+	Online bool
+	Prefix *Prefix
+}
+
+type presenceTarget struct {
+	nick      string
+	known     bool
+	online    bool
+	monitored bool
+}
+
+type Presence struct {
+	Send     func(*Message) error
+	OnChange func(PresenceEvent)
+
+	lock       sync.Mutex
+	isupport   ISupport
+	mode       PresenceMode
+	registered bool
+	targets    map[string]*presenceTarget
+	isonQueue  [][]string
+}
+
+func NewPresence(send func(*Message) error, onChange func(PresenceEvent)) *Presence {
+	return &Presence{
+		Send:     send,
+		OnChange: onChange,
+		isupport: ISupport{},
+		targets:  map[string]*presenceTarget{},
+	}
+}
+
+func (p *Presence) Mode() PresenceMode {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.mode
+}
+
+func (p *Presence) Online(nick string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	t, ok := p.targets[p.fold(nick)]
+	return ok && t.online
+}
+
+func (p *Presence) Add(nicks ...string) error {
+	p.lock.Lock()
+
+	var added []string
+	for _, nick := range nicks {
+		key := p.fold(nick)
+		if _, ok := p.targets[key]; ok {
+			continue
+		}
+
+		p.targets[key] = &presenceTarget{nick: nick}
+		added = append(added, nick)
+	}
+
+	var msgs []*Message
+	if p.registered {
+		msgs = p.addMessages(added)
+	}
+
+	p.lock.Unlock()
+
+	return p.send(msgs)
+}
+
+func (p *Presence) Remove(nicks ...string) error {
+	p.lock.Lock()
+
+	var removed []string
+	for _, nick := range nicks {
+		key := p.fold(nick)
+		t, ok := p.targets[key]
+		if !ok {
+			continue
+		}
+
+		delete(p.targets, key)
+		if t.monitored {
+			removed = append(removed, t.nick)
+		}
+	}
+
+	var msgs []*Message
+	if p.registered && len(removed) > 0 {
+		switch p.mode {
+		case PresenceMonitor:
+			msgs = presenceLines("MONITOR", "-", ",", removed)
+		case PresenceWatch:
+			msgs = presenceLines("WATCH", "-", " ", removed)
+		}
+
+		var waiting []string
+		for _, t := range p.targets {
+			if !t.monitored {
+				waiting = append(waiting, t.nick)
+			}
+		}
+
+		msgs = append(msgs, p.addMessages(waiting)...)
+	}
+
+	p.lock.Unlock()
+
+	return p.send(msgs)
+}
+
+func (p *Presence) Poll() error {
+	p.lock.Lock()
+
+	var nicks []string
+	if p.registered {
+		for _, t := range p.targets {
+			if !t.monitored {
+				nicks = append(nicks, t.nick)
+			}
+		}
+	}
+
+	msgs := presenceLines("ISON", "", " ", nicks)
+	for _, m := range msgs {
+		p.isonQueue = append(p.isonQueue, s.Fields(m.Trailing()))
+	}
+
+	p.lock.Unlock()
+
+	return p.send(msgs)
+}
+
+func (p *Presence) Handle(m *Message) {
+	p.lock.Lock()
+
+	var events []PresenceEvent
+	var msgs []*Message
+
+	switch m.Command {
+	case RPL_WELCOME:
+		p.isupport = ISupport{}
+		p.registered = false
+		p.isonQueue = nil
+		for _, t := range p.targets {
+			t.monitored = false
+		}
+	case RPL_ISUPPORT:
+		p.isupport.Update(m)
+	case RPL_ENDOFMOTD, ERR_NOMOTD:
+		if p.registered {
+			break
+		}
+
+		p.registered = true
+		p.rekey()
+
+		switch {
+		case p.isupport.Has("MONITOR"):
+			p.mode = PresenceMonitor
+		case p.isupport.Has("WATCH"):
+			p.mode = PresenceWatch
+		default:
+			p.mode = PresenceISON
+		}
+
+		var nicks []string
+		for _, t := range p.targets {
+			nicks = append(nicks, t.nick)
+		}
+
+		msgs = p.addMessages(nicks)
+	case RPL_MONONLINE:
+		for _, target := range s.Split(m.Trailing(), ",") {
+			if target == "" {
+				continue
+			}
+
+			prefix := ParsePrefix(target)
+			events = p.update(events, prefix.Name, true, prefix)
+		}
+	case RPL_MONOFFLINE:
+		for _, nick := range s.Split(m.Trailing(), ",") {
+			if nick != "" {
+				events = p.update(events, nick, false, nil)
+			}
+		}
+	case ERR_MONLISTFULL:
+		if len(m.Params) > 2 {
+			for _, nick := range s.Split(m.Params[2], ",") {
+				if t, ok := p.targets[p.fold(nick)]; ok {
+					t.monitored = false
+				}
+			}
+		}
+	case RPL_LOGON, RPL_NOWON:
+		if len(m.Params) > 3 {
+			prefix := &Prefix{Name: m.Params[1], User: m.Params[2], Host: m.Params[3]}
+			events = p.update(events, prefix.Name, true, prefix)
+		}
+	case RPL_LOGOFF, RPL_NOWOFF:
+		if len(m.Params) > 1 {
+			events = p.update(events, m.Params[1], false, nil)
+		}
+	case RPL_ISON:
+		if len(p.isonQueue) == 0 {
+			break
+		}
+
+		queried := p.isonQueue[0]
+		p.isonQueue = p.isonQueue[1:]
+
+		online := map[string]bool{}
+		for _, nick := range s.Fields(m.Trailing()) {
+			online[p.fold(nick)] = true
+		}
+
+		for _, nick := range queried {
+			events = p.update(events, nick, online[p.fold(nick)], nil)
+		}
+	}
+
+	p.lock.Unlock()
+
+	p.send(msgs)
+
+	if p.OnChange != nil {
+		for _, e := range events {
+			p.OnChange(e)
+		}
+	}
+}
+
+func (p *Presence) update(events []PresenceEvent, nick string, online bool, prefix *Prefix) []PresenceEvent {
+	t, ok := p.targets[p.fold(nick)]
+	if !ok || (t.known && t.online == online) {
+		return events
+	}
+
+	t.known, t.online = true, online
+
+	return append(events, PresenceEvent{Nick: t.nick, Online: online, Prefix: prefix})
+}
+
+func (p *Presence) addMessages(nicks []string) []*Message {
+	if len(nicks) == 0 || p.mode == PresenceISON {
+		return nil
+	}
+
+	limit := 0
+	switch p.mode {
+	case PresenceMonitor:
+		limit = p.isupport.Int("MONITOR", 0)
+	case PresenceWatch:
+		limit = p.isupport.Int("WATCH", 0)
+	}
+
+	monitored := 0
+	for _, t := range p.targets {
+		if t.monitored {
+			monitored++
+		}
+	}
+
+	var add []string
+	for _, nick := range nicks {
+		if limit > 0 && monitored >= limit {
+			break
+		}
+
+		p.targets[p.fold(nick)].monitored = true
+		add = append(add, nick)
+		monitored++
+	}
+
+	if p.mode == PresenceMonitor {
+		return presenceLines("MONITOR", "+", ",", add)
+	}
+
+	return presenceLines("WATCH", "+", " ", add)
+}
+
+func (p *Presence) rekey() {
+	targets := map[string]*presenceTarget{}
+
+	for _, t := range p.targets {
+		targets[p.fold(t.nick)] = t
+	}
+
+	p.targets = targets
+}
+
+func (p *Presence) fold(nick string) string {
+	return p.isupport.CaseMapping().Fold(nick)
+}
+
+func (p *Presence) send(msgs []*Message) error {
+	for _, m := range msgs {
+		if err := p.Send(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func presenceLines(command, itemPrefix, sep string, nicks []string) []*Message {
+	var ret []*Message
+
+	buf := &s.Builder{}
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+
+		m := &Message{Command: command}
+
+		switch command {
+		case "MONITOR":
+			m.Params = []string{itemPrefix, buf.String()}
+		case "WATCH":
+			m.Params = s.Fields(buf.String())
+		default:
+			m.Params = []string{buf.String()}
+		}
+
+		ret = append(ret, m)
+		buf.Reset()
+	}
+
+	lead := ""
+	if command == "WATCH" {
+		lead = itemPrefix
+	}
+
+	for _, nick := range nicks {
+		if buf.Len() > 0 && buf.Len()+len(sep)+len(lead)+len(nick) > presenceLineBytes {
+			flush()
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteString(sep)
+		}
+
+		buf.WriteString(lead)
+		buf.WriteString(nick)
+	}
+
+	flush()
+
+	return ret
+}
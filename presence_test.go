@@ -0,0 +1,221 @@
+package tightbeam_test
+
+import (
+	"fmt"
+	"sort"
+	s "strings"
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+type presenceRecorder struct {
+	sent   []*tightbeam.Message
+	events []string
+}
+
+func newPresenceRecorder() (*presenceRecorder, *tightbeam.Presence) {
+	r := &presenceRecorder{}
+
+	p := tightbeam.NewPresence(func(m *tightbeam.Message) error {
+		r.sent = append(r.sent, m)
+		return nil
+	}, func(e tightbeam.PresenceEvent) {
+		v := e.Nick + " offline"
+		if e.Online {
+			v = e.Nick + " online"
+		}
+		if e.Prefix != nil && e.Prefix.Host != "" {
+			v += " " + e.Prefix.String()
+		}
+		r.events = append(r.events, v)
+	})
+
+	return r, p
+}
+
+func (r *presenceRecorder) takeSent() []string {
+	var ret []string
+
+	for _, m := range r.sent {
+		params := append([]string(nil), m.Params...)
+		if len(params) > 0 {
+			items := s.FieldsFunc(params[len(params)-1], func(c rune) bool { return c == ',' || c == ' ' })
+			sort.Strings(items)
+			params[len(params)-1] = s.Join(items, ",")
+		}
+		if m.Command == "WATCH" {
+			sort.Strings(params)
+		}
+
+		ret = append(ret, m.Command+" "+s.Join(params, " "))
+	}
+
+	r.sent = nil
+
+	return ret
+}
+
+func (r *presenceRecorder) takeEvents() []string {
+	ret := r.events
+	sort.Strings(ret)
+	r.events = nil
+
+	return ret
+}
+
+func handleLines(p *tightbeam.Presence, lines ...string) {
+	for _, line := range lines {
+		p.Handle(tightbeam.MustParseMessage(line))
+	}
+}
+
+func checkStrings(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+
+	if s.Join(got, "|") != s.Join(want, "|") {
+		t.Fatalf("%s = %q, want %q", what, got, want)
+	}
+}
+
+func TestPresenceMonitor(t *testing.T) {
+	r, p := newPresenceRecorder()
+
+	p.Add("alice", "Bob")
+	checkStrings(t, "sent before registration", r.takeSent())
+
+	handleLines(p,
+		":srv 001 me :Welcome",
+		":srv 005 me MONITOR=100 CASEMAPPING=rfc1459 :are supported",
+		":srv 376 me :End of MOTD",
+	)
+
+	if p.Mode() != tightbeam.PresenceMonitor {
+		t.Fatalf("Mode() = %v, want PresenceMonitor", p.Mode())
+	}
+	checkStrings(t, "sent after registration", r.takeSent(), "MONITOR + Bob,alice")
+
+	handleLines(p, ":srv 730 me :alice!a@host,bob")
+	checkStrings(t, "events", r.takeEvents(), "Bob online", "alice online alice!a@host")
+
+	if !p.Online("ALICE") || !p.Online("bob") {
+		t.Fatal("Online() after RPL_MONONLINE")
+	}
+
+	handleLines(p, ":srv 730 me :alice!a@host", ":srv 731 me :bob")
+	checkStrings(t, "events", r.takeEvents(), "Bob offline")
+
+	p.Remove("Alice", "carol")
+	checkStrings(t, "sent after Remove", r.takeSent(), "MONITOR - alice")
+
+	p.Add("bob", "dave[")
+	checkStrings(t, "sent after Add", r.takeSent(), "MONITOR + dave[")
+
+	handleLines(p, ":srv 730 me :DAVE{")
+	checkStrings(t, "events", r.takeEvents(), "dave[ online")
+}
+
+func TestPresenceMonitorLimit(t *testing.T) {
+	r, p := newPresenceRecorder()
+	p.Add("a")
+
+	handleLines(p, ":srv 005 me MONITOR=2 :are supported", ":srv 422 me :No MOTD")
+	checkStrings(t, "sent", r.takeSent(), "MONITOR + a")
+
+	p.Add("b", "c")
+	if sent := r.takeSent(); len(sent) != 1 || len(s.Split(sent[0], ",")) != 1 {
+		t.Fatalf("sent %q past the MONITOR limit", sent)
+	}
+
+	handleLines(p, ":srv 734 me 2 a :Monitor list is full")
+	p.Remove("b", "c")
+	checkStrings(t, "sent after Remove", r.takeSent()[1:], "MONITOR + a")
+}
+
+func TestPresenceWatch(t *testing.T) {
+	r, p := newPresenceRecorder()
+	p.Add("alice", "bob")
+
+	handleLines(p, ":srv 005 me WATCH=128 :are supported", ":srv 376 me :End of MOTD")
+
+	if p.Mode() != tightbeam.PresenceWatch {
+		t.Fatalf("Mode() = %v, want PresenceWatch", p.Mode())
+	}
+	checkStrings(t, "sent", r.takeSent(), "WATCH +alice +bob")
+
+	handleLines(p,
+		":srv 604 me alice a host 0 :is online",
+		":srv 605 me bob * * 0 :is offline",
+		":srv 601 me alice a host 0 :logged offline",
+		":srv 600 me bob b host 0 :logged online",
+	)
+	checkStrings(t, "events", r.takeEvents(), "alice offline", "alice online alice!a@host", "bob offline", "bob online bob!b@host")
+
+	p.Remove("alice")
+	checkStrings(t, "sent after Remove", r.takeSent(), "WATCH -alice")
+}
+
+func TestPresenceISON(t *testing.T) {
+	r, p := newPresenceRecorder()
+	p.Add("alice", "bob")
+
+	if err := p.Poll(); err != nil || len(r.takeSent()) != 0 {
+		t.Fatal("Poll() before registration sent ISON")
+	}
+
+	handleLines(p, ":srv 376 me :End of MOTD")
+
+	if p.Mode() != tightbeam.PresenceISON {
+		t.Fatalf("Mode() = %v, want PresenceISON", p.Mode())
+	}
+	checkStrings(t, "sent on registration", r.takeSent())
+
+	p.Poll()
+	checkStrings(t, "sent by Poll", r.takeSent(), "ISON alice,bob")
+
+	handleLines(p, ":srv 303 me :ALICE")
+	checkStrings(t, "events", r.takeEvents(), "alice online", "bob offline")
+
+	p.Poll()
+	r.takeSent()
+	handleLines(p, ":srv 303 me :alice", ":srv 303 me :bob")
+	checkStrings(t, "events", r.takeEvents())
+}
+
+func TestPresenceReregister(t *testing.T) {
+	r, p := newPresenceRecorder()
+	p.Add("alice")
+
+	handleLines(p, ":srv 005 me MONITOR=10 :are supported", ":srv 376 me :End of MOTD")
+	checkStrings(t, "sent", r.takeSent(), "MONITOR + alice")
+
+	handleLines(p, ":srv 376 me :End of MOTD")
+	checkStrings(t, "sent on a second MOTD", r.takeSent())
+
+	handleLines(p, ":srv 001 me :Welcome", ":srv 005 me WATCH=10 :are supported", ":srv 376 me :End of MOTD")
+	checkStrings(t, "sent after reconnect", r.takeSent(), "WATCH +alice")
+}
+
+func TestPresenceLineLength(t *testing.T) {
+	r, p := newPresenceRecorder()
+
+	var nicks []string
+	for n := 0; n < 100; n++ {
+		nicks = append(nicks, fmt.Sprintf("nickname%02d", n))
+	}
+	p.Add(nicks...)
+
+	handleLines(p, ":srv 005 me MONITOR :are supported", ":srv 376 me :End of MOTD")
+
+	total := 0
+	for _, m := range r.sent {
+		if len(m.Trailing()) > 400 {
+			t.Fatalf("MONITOR line of %d bytes", len(m.Trailing()))
+		}
+		total += len(s.Split(m.Trailing(), ","))
+	}
+
+	if len(r.sent) < 2 || total != len(nicks) {
+		t.Fatalf("sent %d lines with %d nicks", len(r.sent), total)
+	}
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+type HTTPConnect struct {
+	Addr     string
+	Username string
+	Password string
+	Forward  tightbeam.Dialer
+
+	TLS       bool
+	TLSConfig *tls.Config
+}
+
+func (d *HTTPConnect) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := tightbeam.DialConn(ctx, d.Addr, d.Forward, d.tlsConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	stop := watchContext(ctx, conn)
+	ret, err := d.connect(conn, addr)
+	if err = stop(err); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (d *HTTPConnect) tlsConfig() *tls.Config {
+	if !d.TLS {
+		return nil
+	}
+
+	if d.TLSConfig == nil {
+		return &tls.Config{}
+	}
+
+	return d.TLSConfig
+}
+
+func (d *HTTPConnect) connect(conn net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+
+	if d.Username != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(d.Username + ":" + d.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.New("proxy: CONNECT failed: " + resp.Status)
+	}
+
+	if r.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	s "strings"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+var ErrorUnsupportedScheme = errors.New("proxy: Unsupported proxy scheme")
+
+func FromURL(u *url.URL, forward tightbeam.Dialer) (tightbeam.Dialer, error) {
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+
+	user, pass := "", ""
+	if u.User != nil {
+		user = u.User.Username()
+		pass, _ = u.User.Password()
+	}
+
+	switch s.ToLower(u.Scheme) {
+	case "socks5", "socks5h":
+		return &SOCKS5{
+			Addr:      hostPort(u, "1080"),
+			Username:  user,
+			Password:  pass,
+			Forward:   forward,
+			RemoteDNS: s.ToLower(u.Scheme) == "socks5h",
+		}, nil
+	case "http":
+		return &HTTPConnect{
+			Addr:     hostPort(u, "80"),
+			Username: user,
+			Password: pass,
+			Forward:  forward,
+		}, nil
+	case "https":
+		return &HTTPConnect{
+			Addr:     hostPort(u, "443"),
+			Username: user,
+			Password: pass,
+			Forward:  forward,
+			TLS:      true,
+		}, nil
+	}
+
+	return nil, ErrorUnsupportedScheme
+}
+
+func FromString(rawurl string, forward tightbeam.Dialer) (tightbeam.Dialer, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromURL(u, forward)
+}
+
+type envDialer struct {
+	forward tightbeam.Dialer
+}
+
+func FromEnvironment(forward tightbeam.Dialer) tightbeam.Dialer {
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+
+	return &envDialer{forward: forward}
+}
+
+func (d *envDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	if noProxy(host) {
+		return d.forward.DialContext(ctx, network, addr)
+	}
+
+	for _, name := range []string{"ALL_PROXY", "HTTPS_PROXY", "HTTP_PROXY"} {
+		v := getenv(name)
+		if v == "" {
+			continue
+		}
+
+		if !s.Contains(v, "://") {
+			v = "http://" + v
+		}
+
+		dialer, err := FromString(v, d.forward)
+		if err != nil {
+			return nil, err
+		}
+
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	return d.forward.DialContext(ctx, network, addr)
+}
+
+func noProxy(host string) bool {
+	host = s.ToLower(host)
+
+	for _, entry := range s.Split(getenv("NO_PROXY"), ",") {
+		entry = s.ToLower(s.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		if entry == "*" {
+			return true
+		}
+
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entry = s.TrimPrefix(entry, "*")
+		if host == s.TrimPrefix(entry, ".") || (s.HasPrefix(entry, ".") && s.HasSuffix(host, entry)) ||
+			s.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getenv(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+
+	return os.Getenv(s.ToLower(name))
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+func watchContext(ctx context.Context, conn net.Conn) func(err error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	return func(err error) error {
+		close(stop)
+		<-done
+
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		conn.SetDeadline(time.Time{})
+		return err
+	}
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
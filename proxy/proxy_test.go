@@ -0,0 +1,371 @@
+package proxy_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	s "strings"
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/proxy"
+)
+
+type socksServer struct {
+	method   byte
+	authOK   bool
+	reply    byte
+	version  byte
+	username string
+	password string
+	target   string
+}
+
+func (srv *socksServer) serve(t *testing.T) (string, chan *socksServer) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	seen := make(chan *socksServer, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		got := &socksServer{}
+		defer func() { seen <- got }()
+
+		buf := make([]byte, 256)
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return
+		}
+		io.ReadFull(conn, buf[:buf[1]])
+
+		version := srv.version
+		if version == 0 {
+			version = 5
+		}
+		conn.Write([]byte{version, srv.method})
+
+		if srv.method == 0x02 {
+			io.ReadFull(conn, buf[:2])
+			user := make([]byte, buf[1])
+			io.ReadFull(conn, user)
+			io.ReadFull(conn, buf[:1])
+			pass := make([]byte, buf[0])
+			io.ReadFull(conn, pass)
+			got.username, got.password = string(user), string(pass)
+
+			if !srv.authOK {
+				conn.Write([]byte{0x01, 0x01})
+				return
+			}
+			conn.Write([]byte{0x01, 0x00})
+		} else if srv.method != 0x00 {
+			return
+		}
+
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return
+		}
+
+		switch buf[3] {
+		case 0x01:
+			io.ReadFull(conn, buf[:4])
+			got.target = net.IP(append([]byte(nil), buf[:4]...)).String()
+		case 0x04:
+			io.ReadFull(conn, buf[:16])
+			got.target = net.IP(append([]byte(nil), buf[:16]...)).String()
+		case 0x03:
+			io.ReadFull(conn, buf[:1])
+			host := make([]byte, buf[0])
+			io.ReadFull(conn, host)
+			got.target = string(host)
+		}
+
+		io.ReadFull(conn, buf[:2])
+		got.target += ":" + strconv.Itoa(int(buf[0])<<8|int(buf[1]))
+
+		conn.Write([]byte{0x05, srv.reply, 0x00, 0x03, 4, 'h', 'o', 's', 't', 0x1a, 0x0b})
+		if srv.reply != 0x00 {
+			return
+		}
+
+		conn.Write([]byte("hello\r\n"))
+	}()
+
+	return l.Addr().String(), seen
+}
+
+func readGreeting(t *testing.T, conn net.Conn) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "hello\r\n" {
+		t.Fatalf("read %q, %v through the proxy", line, err)
+	}
+}
+
+func TestSOCKS5(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		srv    socksServer
+		dialer proxy.SOCKS5
+		addr   string
+		target string
+		err    string
+	}{
+		{"remote dns", socksServer{}, proxy.SOCKS5{RemoteDNS: true}, "irc.example:6667", "irc.example:6667", ""},
+		{"local dns", socksServer{}, proxy.SOCKS5{}, "localhost:6697", "127.0.0.1:6697", ""},
+		{"ipv4", socksServer{}, proxy.SOCKS5{RemoteDNS: true}, "192.0.2.1:6667", "192.0.2.1:6667", ""},
+		{"ipv6", socksServer{}, proxy.SOCKS5{}, "[2001:db8::1]:6667", "2001:db8::1:6667", ""},
+		{"auth", socksServer{method: 0x02, authOK: true}, proxy.SOCKS5{Username: "user", Password: "pass", RemoteDNS: true}, "irc.example:6667", "irc.example:6667", ""},
+		{"auth rejected", socksServer{method: 0x02}, proxy.SOCKS5{Username: "user", Password: "wrong", RemoteDNS: true}, "irc.example:6667", "", proxy.ErrorSOCKSAuth.Error()},
+		{"auth required", socksServer{method: 0x02}, proxy.SOCKS5{RemoteDNS: true}, "irc.example:6667", "", proxy.ErrorSOCKSAuth.Error()},
+		{"no acceptable method", socksServer{method: 0xff}, proxy.SOCKS5{RemoteDNS: true}, "irc.example:6667", "", proxy.ErrorSOCKSAuth.Error()},
+		{"bad version", socksServer{version: 4}, proxy.SOCKS5{RemoteDNS: true}, "irc.example:6667", "", proxy.ErrorSOCKSProtocol.Error()},
+		{"refused", socksServer{reply: 5}, proxy.SOCKS5{RemoteDNS: true}, "irc.example:6667", "irc.example:6667", "proxy: SOCKS5 connection refused"},
+		{"unknown reply", socksServer{reply: 42}, proxy.SOCKS5{RemoteDNS: true}, "irc.example:6667", "irc.example:6667", proxy.ErrorSOCKSProtocol.Error()},
+		{"bad port", socksServer{}, proxy.SOCKS5{RemoteDNS: true}, "irc.example:0", "", "proxy: Bad port 0"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, seen := tt.srv.serve(t)
+
+			d := tt.dialer
+			d.Addr = addr
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			conn, err := d.DialContext(ctx, "tcp", tt.addr)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("DialContext() error = %v, want %s", err, tt.err)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+				readGreeting(t, conn)
+			}
+
+			got := <-seen
+			if got.target != tt.target {
+				t.Errorf("proxy saw target %q, want %q", got.target, tt.target)
+			}
+
+			if d.Username != "" && (got.username != d.Username || got.password != d.Password) {
+				t.Errorf("proxy saw credentials %q:%q", got.username, got.password)
+			}
+		})
+	}
+}
+
+func TestHTTPConnect(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status string
+		user   string
+		auth   string
+		err    string
+	}{
+		{"ok", "200 Connection established", "", "", ""},
+		{"auth", "200 OK", "user", "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass")), ""},
+		{"rejected", "407 Proxy Authentication Required", "", "", "proxy: CONNECT failed: 407 Proxy Authentication Required"},
+		{"forbidden", "403 Forbidden", "user", "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass")), "proxy: CONNECT failed: 403 Forbidden"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			seen := make(chan *http.Request, 1)
+			go func() {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				seen <- req
+				if err != nil {
+					return
+				}
+
+				io.WriteString(conn, "HTTP/1.1 "+tt.status+"\r\nContent-Length: 0\r\n\r\nhello\r\n")
+				io.Copy(io.Discard, conn)
+			}()
+
+			d := &proxy.HTTPConnect{Addr: l.Addr().String(), Username: tt.user}
+			if tt.user != "" {
+				d.Password = "pass"
+			}
+
+			conn, err := d.DialContext(context.Background(), "tcp", "irc.example:6697")
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("DialContext() error = %v, want %s", err, tt.err)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				readGreeting(t, conn)
+				conn.Close()
+			}
+
+			req := <-seen
+			if req == nil || req.Method != http.MethodConnect || req.Host != "irc.example:6697" || req.RequestURI != "irc.example:6697" {
+				t.Fatalf("proxy saw request %+v", req)
+			}
+
+			if got := req.Header.Get("Proxy-Authorization"); got != tt.auth {
+				t.Errorf("Proxy-Authorization = %q, want %q", got, tt.auth)
+			}
+		})
+	}
+}
+
+func TestHandshakeCancel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	for _, d := range []tightbeam.Dialer{
+		&proxy.SOCKS5{Addr: l.Addr().String(), RemoteDNS: true},
+		&proxy.HTTPConnect{Addr: l.Addr().String()},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		start := time.Now()
+		if _, err := d.DialContext(ctx, "tcp", "irc.example:6667"); err != context.Canceled {
+			t.Errorf("%T: DialContext() error = %v, want context.Canceled", d, err)
+		}
+
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%T: DialContext() took %v after cancel", d, elapsed)
+		}
+	}
+}
+
+type recordingDialer struct {
+	addrs []string
+}
+
+func (d *recordingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.addrs = append(d.addrs, addr)
+	return nil, errors.New("recorded")
+}
+
+func TestFromURL(t *testing.T) {
+	forward := &recordingDialer{}
+
+	for _, tt := range []struct {
+		url  string
+		want tightbeam.Dialer
+		err  error
+	}{
+		{"socks5://proxy.example", &proxy.SOCKS5{Addr: "proxy.example:1080", Forward: forward}, nil},
+		{"SOCKS5H://u:p@proxy.example:9050", &proxy.SOCKS5{Addr: "proxy.example:9050", Username: "u", Password: "p", Forward: forward, RemoteDNS: true}, nil},
+		{"http://u@proxy.example", &proxy.HTTPConnect{Addr: "proxy.example:80", Username: "u", Forward: forward}, nil},
+		{"https://[2001:db8::1]", &proxy.HTTPConnect{Addr: "[2001:db8::1]:443", Forward: forward, TLS: true}, nil},
+		{"ftp://proxy.example", nil, proxy.ErrorUnsupportedScheme},
+	} {
+		d, err := proxy.FromString(tt.url, forward)
+		if err != tt.err {
+			t.Errorf("FromString(%q) error = %v, want %v", tt.url, err, tt.err)
+			continue
+		}
+
+		switch want := tt.want.(type) {
+		case *proxy.SOCKS5:
+			if got, ok := d.(*proxy.SOCKS5); !ok || *got != *want {
+				t.Errorf("FromString(%q) = %+v, want %+v", tt.url, d, want)
+			}
+		case *proxy.HTTPConnect:
+			if got, ok := d.(*proxy.HTTPConnect); !ok || *got != *want {
+				t.Errorf("FromString(%q) = %+v, want %+v", tt.url, d, want)
+			}
+		}
+	}
+
+	if _, err := proxy.FromString("socks5://[::1", nil); err == nil {
+		t.Error("FromString() accepted a malformed URL")
+	}
+}
+
+func TestFromEnvironment(t *testing.T) {
+	for _, name := range []string{"ALL_PROXY", "HTTPS_PROXY", "HTTP_PROXY", "NO_PROXY"} {
+		t.Setenv(name, "")
+		t.Setenv(s.ToLower(name), "")
+	}
+
+	for _, tt := range []struct {
+		env  map[string]string
+		addr string
+		want string
+	}{
+		{nil, "irc.example:6667", "irc.example:6667"},
+		{map[string]string{"ALL_PROXY": "socks5h://proxy.example"}, "irc.example:6667", "proxy.example:1080"},
+		{map[string]string{"https_proxy": "proxy.example:3128"}, "irc.example:6667", "proxy.example:3128"},
+		{map[string]string{"HTTP_PROXY": "http://proxy.example", "ALL_PROXY": "socks5h://socks.example"}, "irc.example:6667", "socks.example:1080"},
+		{map[string]string{"HTTP_PROXY": "http://proxy.example", "NO_PROXY": "*"}, "irc.example:6667", "irc.example:6667"},
+		{map[string]string{"HTTP_PROXY": "http://proxy.example", "NO_PROXY": "other.example, .example"}, "irc.example:6667", "irc.example:6667"},
+		{map[string]string{"HTTP_PROXY": "http://proxy.example", "NO_PROXY": "example"}, "irc.example:6667", "irc.example:6667"},
+		{map[string]string{"HTTP_PROXY": "http://proxy.example", "NO_PROXY": "*.example"}, "irc.example:6667", "irc.example:6667"},
+		{map[string]string{"HTTP_PROXY": "http://proxy.example", "NO_PROXY": "irc.example:7000"}, "irc.example:6667", "irc.example:6667"},
+		{map[string]string{"HTTP_PROXY": "http://proxy.example", "no_proxy": "notirc.example"}, "irc.example:6667", "proxy.example:80"},
+		{map[string]string{"HTTP_PROXY": "http://proxy.example", "NO_PROXY": "192.0.2.0/24"}, "192.0.2.7:6667", "192.0.2.7:6667"},
+		{map[string]string{"HTTP_PROXY": "http://proxy.example", "NO_PROXY": "192.0.2.0/24"}, "198.51.100.7:6667", "proxy.example:80"},
+	} {
+		for k, v := range tt.env {
+			t.Setenv(k, v)
+		}
+
+		forward := &recordingDialer{}
+		proxy.FromEnvironment(forward).DialContext(context.Background(), "tcp", tt.addr)
+
+		if len(forward.addrs) != 1 || forward.addrs[0] != tt.want {
+			t.Errorf("env %v: dialed %q, want %q", tt.env, forward.addrs, tt.want)
+		}
+
+		for k := range tt.env {
+			t.Setenv(k, "")
+		}
+	}
+
+	t.Setenv("ALL_PROXY", "ftp://proxy.example")
+	if _, err := proxy.FromEnvironment(nil).DialContext(context.Background(), "tcp", "irc.example:6667"); err != proxy.ErrorUnsupportedScheme {
+		t.Fatalf("DialContext() error = %v, want ErrorUnsupportedScheme", err)
+	}
+}
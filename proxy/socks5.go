@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+var (
+	ErrorSOCKSAuth = errors.New("proxy: SOCKS5 authentication failed")
+
+	ErrorSOCKSProtocol = errors.New("proxy: SOCKS5 protocol error")
+)
+
+var socksReplies = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+type SOCKS5 struct {
+	Addr     string
+	Username string
+	Password string
+	Forward  tightbeam.Dialer
+
+	RemoteDNS bool
+}
+
+func (d *SOCKS5) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	forward := d.Forward
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+
+	addr, err := d.resolve(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := forward.DialContext(ctx, "tcp", d.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	stop := watchContext(ctx, conn)
+	if err := stop(d.handshake(conn, addr)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (d *SOCKS5) resolve(ctx context.Context, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || d.RemoteDNS || net.ParseIP(host) != nil {
+		return addr, nil
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return "", err
+	}
+
+	ip := ips[0]
+	for _, v := range ips {
+		if v.To4() != nil {
+			ip = v
+			break
+		}
+	}
+
+	return net.JoinHostPort(ip.String(), port), nil
+}
+
+func (d *SOCKS5) handshake(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 0xffff {
+		return errors.New("proxy: Bad port " + portStr)
+	}
+
+	methods := []byte{0x00}
+	if d.Username != "" {
+		methods = []byte{0x00, 0x02}
+	}
+
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return err
+	}
+
+	if resp[0] != 0x05 {
+		return ErrorSOCKSProtocol
+	}
+
+	switch resp[1] {
+	case 0x00:
+	case 0x02:
+		if d.Username == "" || len(d.Username) > 255 || len(d.Password) > 255 {
+			return ErrorSOCKSAuth
+		}
+
+		req := []byte{0x01, byte(len(d.Username))}
+		req = append(req, d.Username...)
+		req = append(req, byte(len(d.Password)))
+		req = append(req, d.Password...)
+
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(conn, resp[:]); err != nil {
+			return err
+		}
+
+		if resp[1] != 0x00 {
+			return ErrorSOCKSAuth
+		}
+	default:
+		return ErrorSOCKSAuth
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, 0x01)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, 0x04)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return errors.New("proxy: Host name too long")
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return err
+	}
+
+	if head[0] != 0x05 {
+		return ErrorSOCKSProtocol
+	}
+
+	if head[1] != 0x00 {
+		if msg, ok := socksReplies[head[1]]; ok {
+			return errors.New("proxy: SOCKS5 " + msg)
+		}
+		return ErrorSOCKSProtocol
+	}
+
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = 4
+	case 0x04:
+		skip = 16
+	case 0x03:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		skip = int(l[0])
+	default:
+		return ErrorSOCKSProtocol
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
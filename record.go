@@ -0,0 +1,280 @@
+package tightbeam
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	s "strings"
+	"sync"
+	"time"
+)
+
+var ErrorBadRecordEntry = errors.New("irc: Malformed recording entry")
+
+type Direction byte
+
+const (
+	Inbound  Direction = '<'
+	Outbound Direction = '>'
+)
+
+type RecordEntry struct {
+	Time      time.Time
+	Direction Direction
+	Line      string
+}
+
+func ParseRecordEntry(line string) (RecordEntry, error) {
+	parts := s.SplitN(line, " ", 3)
+	if len(parts) < 2 || len(parts[1]) != 1 {
+		return RecordEntry{}, ErrorBadRecordEntry
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return RecordEntry{}, ErrorBadRecordEntry
+	}
+
+	dir := Direction(parts[1][0])
+	if dir != Inbound && dir != Outbound {
+		return RecordEntry{}, ErrorBadRecordEntry
+	}
+
+	e := RecordEntry{Time: t, Direction: dir}
+	if len(parts) == 3 {
+		e.Line = parts[2]
+	}
+
+	return e, nil
+}
+
+func (e RecordEntry) String() string {
+	return e.Time.UTC().Format(time.RFC3339Nano) + " " + string(e.Direction) + " " + e.Line
+}
+
+func ReadRecording(r io.Reader) ([]RecordEntry, error) {
+	var ret []RecordEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+
+		e, err := ParseRecordEntry(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, e)
+	}
+
+	return ret, scanner.Err()
+}
+
+type Recorder struct {
+	t Transport
+
+	lock   sync.Mutex
+	w      io.Writer
+	now    func() time.Time
+	closed bool
+}
+
+func NewRecorder(t Transport, w io.Writer) *Recorder {
+	return &Recorder{
+		t:   t,
+		w:   w,
+		now: time.Now,
+	}
+}
+
+func (r *Recorder) ReadLine() (string, error) {
+	line, err := r.t.ReadLine()
+	if err == nil {
+		r.record(Inbound, line)
+	}
+
+	return line, err
+}
+
+func (r *Recorder) WriteLine(line string) error {
+	r.record(Outbound, line)
+
+	return r.t.WriteLine(line)
+}
+
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	r.closed = true
+	r.lock.Unlock()
+
+	return r.t.Close()
+}
+
+func (r *Recorder) record(dir Direction, line string) {
+	line = s.TrimRight(line, "\r\n")
+	if line == "" {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	e := RecordEntry{Time: r.now(), Direction: dir, Line: line}
+	io.WriteString(r.w, e.String()+"\n")
+}
+
+type Replay struct {
+	entries []RecordEntry
+	speed   float64
+
+	lock     sync.Mutex
+	pos      int
+	outbound int
+	last     time.Time
+
+	sentLock sync.Mutex
+	sent     []string
+	wrote    chan struct{}
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func NewReplay(r io.Reader, speed float64) (*Replay, error) {
+	entries, err := ReadRecording(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReplayEntries(entries, speed), nil
+}
+
+func NewReplayEntries(entries []RecordEntry, speed float64) *Replay {
+	return &Replay{
+		entries: entries,
+		speed:   speed,
+		wrote:   make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (r *Replay) ReadLine() (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for r.pos < len(r.entries) {
+		e := r.entries[r.pos]
+		r.pos++
+
+		if e.Direction == Outbound {
+			r.outbound++
+			r.advance(e.Time)
+			continue
+		}
+
+		if err := r.waitSent(r.outbound); err != nil {
+			return "", err
+		}
+
+		if !r.last.IsZero() && r.speed > 0 {
+			if err := r.sleep(time.Duration(float64(e.Time.Sub(r.last)) / r.speed)); err != nil {
+				return "", err
+			}
+		}
+
+		r.advance(e.Time)
+
+		select {
+		case <-r.done:
+			return "", io.ErrClosedPipe
+		default:
+		}
+
+		return e.Line, nil
+	}
+
+	return "", io.EOF
+}
+
+func (r *Replay) advance(t time.Time) {
+	if t.After(r.last) {
+		r.last = t
+	}
+}
+
+func (r *Replay) waitSent(n int) error {
+	for {
+		r.sentLock.Lock()
+		sent, wrote := len(r.sent), r.wrote
+		r.sentLock.Unlock()
+
+		if sent >= n {
+			return nil
+		}
+
+		select {
+		case <-wrote:
+		case <-r.done:
+			return io.ErrClosedPipe
+		}
+	}
+}
+
+func (r *Replay) sleep(delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.done:
+		return io.ErrClosedPipe
+	}
+}
+
+func (r *Replay) WriteLine(line string) error {
+	select {
+	case <-r.done:
+		return io.ErrClosedPipe
+	default:
+	}
+
+	if line = s.TrimRight(line, "\r\n"); line == "" {
+		return nil
+	}
+
+	r.sentLock.Lock()
+	r.sent = append(r.sent, line)
+	close(r.wrote)
+	r.wrote = make(chan struct{})
+	r.sentLock.Unlock()
+
+	return nil
+}
+
+func (r *Replay) Sent() []string {
+	r.sentLock.Lock()
+	defer r.sentLock.Unlock()
+
+	return append([]string(nil), r.sent...)
+}
+
+func (r *Replay) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package tightbeam_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	s "strings"
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+func TestParseRecordEntry(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+
+	for _, tt := range []struct {
+		line string
+		want tightbeam.RecordEntry
+		err  error
+	}{
+		{"2024-01-02T03:04:05.0000006Z < :srv 001 me :hi there", tightbeam.RecordEntry{ts, tightbeam.Inbound, ":srv 001 me :hi there"}, nil},
+		{"2024-01-02T03:04:05.0000006Z > NICK me", tightbeam.RecordEntry{ts, tightbeam.Outbound, "NICK me"}, nil},
+		{"2024-01-02T03:04:05.0000006Z >", tightbeam.RecordEntry{ts, tightbeam.Outbound, ""}, nil},
+		{"2024-01-02T03:04:05.0000006Z = NICK me", tightbeam.RecordEntry{}, tightbeam.ErrorBadRecordEntry},
+		{"2024-01-02T03:04:05.0000006Z << NICK me", tightbeam.RecordEntry{}, tightbeam.ErrorBadRecordEntry},
+		{"yesterday > NICK me", tightbeam.RecordEntry{}, tightbeam.ErrorBadRecordEntry},
+		{"NICK", tightbeam.RecordEntry{}, tightbeam.ErrorBadRecordEntry},
+	} {
+		e, err := tightbeam.ParseRecordEntry(tt.line)
+		if err != tt.err || !e.Time.Equal(tt.want.Time) || e.Direction != tt.want.Direction || e.Line != tt.want.Line {
+			t.Errorf("ParseRecordEntry(%q) = %+v, %v, want %+v, %v", tt.line, e, err, tt.want, tt.err)
+			continue
+		}
+
+		if err == nil && e.Line != "" && e.String() != tt.line {
+			t.Errorf("ParseRecordEntry(%q).String() = %q", tt.line, e.String())
+		}
+	}
+
+	entries, err := tightbeam.ReadRecording(s.NewReader("2024-01-02T03:04:05Z > NICK me\n\n2024-01-02T03:04:06Z < PING x\n"))
+	if err != nil || len(entries) != 2 || entries[1].Line != "PING x" {
+		t.Fatalf("ReadRecording() = %+v, %v", entries, err)
+	}
+
+	if _, err := tightbeam.ReadRecording(s.NewReader("2024-01-02T03:04:05Z > NICK me\nbroken\n")); err != tightbeam.ErrorBadRecordEntry {
+		t.Fatalf("ReadRecording() error = %v, want ErrorBadRecordEntry", err)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	buf := &bytes.Buffer{}
+	rec := tightbeam.NewRecorder(tightbeam.NewStreamTransport(a), buf)
+	c := tightbeam.NewTransportClient(rec, tightbeam.ClientConfig{Nick: "me"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+
+	r, w := tightbeam.NewReader(b), tightbeam.NewWriter(b)
+	r.ReadLine()
+	r.ReadLine()
+	w.WriteLine(":srv 001 me :Welcome")
+	w.WriteLine("PING :abc")
+
+	if line, _ := r.ReadLine(); line != "PONG abc" {
+		t.Fatalf("read %q, want PONG abc", line)
+	}
+
+	c.Close()
+	<-done
+
+	entries, err := tightbeam.ReadRecording(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range entries {
+		if e.Time.IsZero() {
+			t.Errorf("entry %q has no time", e.Line)
+		}
+		got = append(got, string(e.Direction)+" "+e.Line)
+	}
+
+	want := []string{"> NICK me", "> USER me 0 * me", "< :srv 001 me :Welcome", "< PING :abc", "> PONG abc"}
+	if len(got) < len(want) || s.Join(got[:len(want)], "|") != s.Join(want, "|") {
+		t.Fatalf("recorded %q, want %q", got, want)
+	}
+}
+
+func TestReplayOrder(t *testing.T) {
+	ts := time.Now()
+	rp := tightbeam.NewReplayEntries([]tightbeam.RecordEntry{
+		{ts, tightbeam.Inbound, "NOTICE * :hello"},
+		{ts, tightbeam.Outbound, "NICK me"},
+		{ts, tightbeam.Outbound, "USER me 0 * me"},
+		{ts, tightbeam.Inbound, ":srv 001 me :Welcome"},
+	}, 0)
+
+	if line, err := rp.ReadLine(); line != "NOTICE * :hello" || err != nil {
+		t.Fatalf("ReadLine() = %q, %v", line, err)
+	}
+
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := rp.ReadLine()
+		lines <- line
+	}()
+
+	rp.WriteLine("NICK me")
+
+	select {
+	case line := <-lines:
+		t.Fatalf("ReadLine() returned %q before USER was sent", line)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	rp.WriteLine("USER me 0 * me")
+
+	if line := <-lines; line != ":srv 001 me :Welcome" {
+		t.Fatalf("ReadLine() = %q", line)
+	}
+
+	if _, err := rp.ReadLine(); err != io.EOF {
+		t.Fatalf("ReadLine() at end = %v, want io.EOF", err)
+	}
+
+	if sent := rp.Sent(); s.Join(sent, "|") != "NICK me|USER me 0 * me" {
+		t.Fatalf("Sent() = %q", sent)
+	}
+}
+
+func TestReplayTiming(t *testing.T) {
+	ts := time.Now()
+	entries := []tightbeam.RecordEntry{
+		{ts, tightbeam.Inbound, "PING a"},
+		{ts.Add(time.Second), tightbeam.Inbound, "PING b"},
+	}
+
+	rp := tightbeam.NewReplayEntries(entries, 20)
+	start := time.Now()
+	rp.ReadLine()
+	rp.ReadLine()
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("replay at 20x took %v", elapsed)
+	}
+
+	rp = tightbeam.NewReplayEntries(entries, 0.001)
+	rp.ReadLine()
+
+	lines := make(chan error, 1)
+	go func() {
+		_, err := rp.ReadLine()
+		lines <- err
+	}()
+
+	rp.Close()
+
+	if err := <-lines; err != io.ErrClosedPipe {
+		t.Fatalf("ReadLine() after Close = %v, want io.ErrClosedPipe", err)
+	}
+
+	if err := rp.WriteLine("PONG a"); err != io.ErrClosedPipe {
+		t.Fatalf("WriteLine() after Close = %v, want io.ErrClosedPipe", err)
+	}
+}
+
+func TestReplayClient(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rp, err := tightbeam.NewReplay(s.NewReader(`2024-01-02T03:04:05Z > NICK me
+2024-01-02T03:04:05Z > USER me 0 * me
+2024-01-02T03:04:06Z < :srv 001 me :Welcome
+2024-01-02T03:04:06Z < PING :abc
+2024-01-02T03:04:06Z > PONG abc
+2024-01-02T03:04:07Z < :srv NICK :other
+`), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := tightbeam.NewTransportClient(tightbeam.NewRecorder(rp, buf), tightbeam.ClientConfig{Nick: "me"})
+	if err := c.Run(context.Background()); err != io.EOF {
+		t.Fatalf("Run() = %v, want io.EOF", err)
+	}
+
+	if sent := rp.Sent(); s.Join(sent, "|") != "NICK me|USER me 0 * me|PONG abc" {
+		t.Fatalf("Sent() = %q", sent)
+	}
+
+	if entries, _ := tightbeam.ReadRecording(buf); len(entries) != 6 || entries[4].Line != "PONG abc" || entries[5].Direction != tightbeam.Inbound {
+		t.Fatalf("recorded %+v", entries)
+	}
+}
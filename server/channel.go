@@ -0,0 +1,128 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+type Channel struct {
+	Name    string
+	Created time.Time
+
+	Topic      string
+	TopicSetBy string
+	TopicSetAt time.Time
+
+	Modes map[byte]bool
+	Key   string
+	Limit int
+	Bans  []string
+
+	Members map[string]string
+}
+
+func NewChannel(name string) *Channel {
+	return &Channel{
+		Name:    name,
+		Created: time.Now(),
+		Modes:   map[byte]bool{'n': true, 't': true},
+		Members: map[string]string{},
+	}
+}
+
+func (c *Channel) ModeString() (string, []string) {
+	var modes []byte
+	for m, on := range c.Modes {
+		if on {
+			modes = append(modes, m)
+		}
+	}
+
+	sort.Slice(modes, func(i, j int) bool { return modes[i] < modes[j] })
+
+	var params []string
+	if c.Key != "" {
+		modes = append(modes, 'k')
+		params = append(params, c.Key)
+	}
+
+	if c.Limit > 0 {
+		modes = append(modes, 'l')
+		params = append(params, itoa(c.Limit))
+	}
+
+	return "+" + string(modes), params
+}
+
+func (c *Channel) Banned(p *tightbeam.Prefix, casemap tightbeam.CaseMapping) bool {
+	for _, ban := range c.Bans {
+		if tightbeam.ParseMask(ban).MatchCase(p, casemap) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Channel) banIndex(mask string, casemap tightbeam.CaseMapping) int {
+	for n, ban := range c.Bans {
+		if casemap.Equal(ban, mask) {
+			return n
+		}
+	}
+
+	return -1
+}
+
+type ChannelStore interface {
+	Get(name string) (*Channel, bool)
+	Put(name string, c *Channel)
+	Delete(name string)
+	List() []*Channel
+}
+
+type MemoryStore struct {
+	lock     sync.Mutex
+	channels map[string]*Channel
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{channels: map[string]*Channel{}}
+}
+
+func (m *MemoryStore) Get(name string) (*Channel, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, ok := m.channels[name]
+	return c, ok
+}
+
+func (m *MemoryStore) Put(name string, c *Channel) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.channels[name] = c
+}
+
+func (m *MemoryStore) Delete(name string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.channels, name)
+}
+
+func (m *MemoryStore) List() []*Channel {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ret := make([]*Channel, 0, len(m.channels))
+	for _, c := range m.channels {
+		ret = append(ret, c)
+	}
+
+	return ret
+}
@@ -0,0 +1,864 @@
+package server
+
+import (
+	"strconv"
+	s "strings"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+const (
+	maxNickLen    = 30
+	maxChannelLen = 50
+	maxTopicLen   = 390
+
+	closeTimeout = 5 * time.Second
+)
+
+type commandFunc func(srv *Server, c *conn, m *tightbeam.Message)
+
+type command struct {
+	fn         commandFunc
+	minParams  int
+	registered bool
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"CAP":     {fn: (*Server).handleCap, minParams: 1},
+		"PASS":    {fn: (*Server).handlePass, minParams: 1},
+		"NICK":    {fn: (*Server).handleNick},
+		"USER":    {fn: (*Server).handleUser, minParams: 4},
+		"PING":    {fn: (*Server).handlePing, minParams: 1},
+		"PONG":    {fn: func(*Server, *conn, *tightbeam.Message) {}},
+		"QUIT":    {fn: (*Server).handleQuit},
+		"JOIN":    {fn: (*Server).handleJoin, minParams: 1, registered: true},
+		"PART":    {fn: (*Server).handlePart, minParams: 1, registered: true},
+		"PRIVMSG": {fn: (*Server).handleMessage, registered: true},
+		"NOTICE":  {fn: (*Server).handleMessage, registered: true},
+		"TOPIC":   {fn: (*Server).handleTopic, minParams: 1, registered: true},
+		"MODE":    {fn: (*Server).handleMode, minParams: 1, registered: true},
+		"KICK":    {fn: (*Server).handleKick, minParams: 2, registered: true},
+		"WHO":     {fn: (*Server).handleWho, registered: true},
+		"NAMES":   {fn: (*Server).handleNames, registered: true},
+	}
+}
+
+func (srv *Server) dispatch(c *conn, m *tightbeam.Message) {
+	cmd, ok := commands[m.Command]
+	if !ok {
+		if c.registered {
+			c.numeric(tightbeam.ERR_UNKNOWNCOMMAND, m.Command, "Unknown command")
+		}
+		return
+	}
+
+	if cmd.registered && !c.registered {
+		c.numeric(tightbeam.ERR_NOTREGISTERED, "You have not registered")
+		return
+	}
+
+	if len(m.Params) < cmd.minParams {
+		c.numeric(tightbeam.ERR_NEEDMOREPARAMS, m.Command, "Not enough parameters")
+		return
+	}
+
+	cmd.fn(srv, c, m)
+}
+
+func (srv *Server) handleCap(c *conn, m *tightbeam.Message) {
+	switch s.ToUpper(m.Params[0]) {
+	case "LS", "LIST":
+		if !c.registered {
+			c.capNegotiating = true
+		}
+		c.send(&tightbeam.Message{Prefix: srv.prefix(), Command: "CAP", Params: []string{c.target(), s.ToUpper(m.Params[0]), ""}})
+	case "REQ":
+		if !c.registered {
+			c.capNegotiating = true
+		}
+		c.send(&tightbeam.Message{Prefix: srv.prefix(), Command: "CAP", Params: []string{c.target(), "NAK", m.Trailing()}})
+	case "END":
+		c.capNegotiating = false
+		srv.tryRegister(c)
+	}
+}
+
+func (srv *Server) handlePass(c *conn, m *tightbeam.Message) {
+	if c.registered {
+		c.numeric(tightbeam.ERR_ALREADYREGISTERED, "You may not reregister")
+		return
+	}
+
+	c.pass = m.Params[0]
+}
+
+func (srv *Server) handleNick(c *conn, m *tightbeam.Message) {
+	if len(m.Params) < 1 || m.Params[0] == "" {
+		c.numeric(tightbeam.ERR_NONICKNAMEGIVEN, "No nickname given")
+		return
+	}
+
+	nick := m.Params[0]
+	if !validNick(nick) {
+		c.numeric(tightbeam.ERR_ERRONEUSNICKNAME, nick, "Erroneous nickname")
+		return
+	}
+
+	if srv.config.Sessions != nil {
+		if !c.registered {
+			c.nick = nick
+			srv.tryRegister(c)
+		}
+		return
+	}
+
+	key := srv.fold(nick)
+	if other, ok := srv.clients[key]; ok && other != c {
+		c.numeric(tightbeam.ERR_NICKNAMEINUSE, nick, "Nickname is already in use")
+		return
+	}
+
+	if !c.registered {
+		if c.nick != "" {
+			delete(srv.clients, srv.fold(c.nick))
+		}
+
+		c.nick = nick
+		srv.clients[key] = c
+		srv.tryRegister(c)
+		return
+	}
+
+	if nick == c.nick {
+		return
+	}
+
+	oldKey := srv.fold(c.nick)
+	msg := &tightbeam.Message{Prefix: c.prefix(), Command: "NICK", Params: []string{nick}}
+	srv.sendCommon(c, msg, true)
+
+	delete(srv.clients, oldKey)
+	srv.clients[key] = c
+	c.nick = nick
+
+	for name := range c.channels {
+		ch, ok := srv.config.Channels.Get(name)
+		if !ok {
+			continue
+		}
+
+		modes := ch.Members[oldKey]
+		delete(ch.Members, oldKey)
+		ch.Members[key] = modes
+		srv.config.Channels.Put(name, ch)
+	}
+}
+
+func (srv *Server) handleUser(c *conn, m *tightbeam.Message) {
+	if c.registered {
+		c.numeric(tightbeam.ERR_ALREADYREGISTERED, "You may not reregister")
+		return
+	}
+
+	c.user = m.Params[0]
+	c.realname = m.Params[3]
+
+	if srv.config.Sessions == nil && len(c.user) > 10 {
+		c.user = c.user[:10]
+	}
+
+	srv.tryRegister(c)
+}
+
+func (srv *Server) tryRegister(c *conn) {
+	if c.registered || c.capNegotiating || c.nick == "" || c.user == "" {
+		return
+	}
+
+	if srv.config.Auth != nil {
+		account, err := srv.config.Auth.Authenticate(c.nick, c.user, c.pass)
+		if err != nil {
+			c.numeric(tightbeam.ERR_PASSWDMISMATCH, "Password incorrect")
+			c.close("Bad password")
+			return
+		}
+		c.account = account
+	}
+
+	c.registered = true
+	c.pass = ""
+
+	if srv.config.Sessions != nil {
+		c.session = &Session{c: c}
+		return
+	}
+
+	c.numeric(tightbeam.RPL_WELCOME, "Welcome to the "+srv.config.Network+" Network, "+c.prefix().String())
+	c.numeric(tightbeam.RPL_YOURHOST, "Your host is "+srv.config.Name+", running version tightbeam")
+	c.numeric(tightbeam.RPL_CREATED, "This server was created "+srv.created.Format(time.RFC1123))
+	c.numeric(tightbeam.RPL_MYINFO, srv.config.Name, "tightbeam", "o", "bklmnostv")
+
+	tokens := srv.ISupport()
+	for len(tokens) > 0 {
+		n := len(tokens)
+		if n > 12 {
+			n = 12
+		}
+
+		c.numeric(tightbeam.RPL_ISUPPORT, append(tokens[:n:n], "are supported by this server")...)
+		tokens = tokens[n:]
+	}
+
+	srv.sendMOTD(c)
+}
+
+func (srv *Server) sendMOTD(c *conn) {
+	if len(srv.config.MOTD) == 0 {
+		c.numeric(tightbeam.ERR_NOMOTD, "MOTD File is missing")
+		return
+	}
+
+	c.numeric(tightbeam.RPL_MOTDSTART, "- "+srv.config.Name+" Message of the day - ")
+	for _, line := range srv.config.MOTD {
+		c.numeric(tightbeam.RPL_MOTD, "- "+line)
+	}
+	c.numeric(tightbeam.RPL_ENDOFMOTD, "End of /MOTD command.")
+}
+
+func (srv *Server) handlePing(c *conn, m *tightbeam.Message) {
+	c.send(&tightbeam.Message{Prefix: srv.prefix(), Command: "PONG", Params: []string{srv.config.Name, m.Params[0]}})
+}
+
+func (srv *Server) handleQuit(c *conn, m *tightbeam.Message) {
+	reason := "Client Quit"
+	if len(m.Params) > 0 {
+		reason = "Quit: " + m.Params[0]
+	}
+
+	c.close(reason)
+}
+
+func (srv *Server) quit(c *conn, reason string) {
+	if c.registered {
+		srv.sendCommon(c, &tightbeam.Message{Prefix: c.prefix(), Command: "QUIT", Params: []string{reason}}, false)
+
+		for name := range c.channels {
+			srv.removeMember(c, name)
+		}
+	}
+
+	if c.nick != "" && srv.clients[srv.fold(c.nick)] == c {
+		delete(srv.clients, srv.fold(c.nick))
+	}
+}
+
+func (srv *Server) handleJoin(c *conn, m *tightbeam.Message) {
+	if m.Params[0] == "0" {
+		for name := range c.channels {
+			if ch, ok := srv.config.Channels.Get(name); ok {
+				srv.part(c, ch, name, "")
+			}
+		}
+		return
+	}
+
+	var keys []string
+	if len(m.Params) > 1 {
+		keys = s.Split(m.Params[1], ",")
+	}
+
+	for n, name := range s.Split(m.Params[0], ",") {
+		key := ""
+		if n < len(keys) {
+			key = keys[n]
+		}
+
+		srv.join(c, name, key)
+	}
+}
+
+func (srv *Server) join(c *conn, name, key string) {
+	if !validChannel(name) {
+		c.numeric(tightbeam.ERR_BADCHANMASK, name, "Bad Channel Mask")
+		return
+	}
+
+	folded := srv.fold(name)
+	if c.channels[folded] {
+		return
+	}
+
+	ch, ok := srv.config.Channels.Get(folded)
+	if !ok {
+		ch = NewChannel(name)
+	} else {
+		if ch.Key != "" && ch.Key != key {
+			c.numeric(tightbeam.ERR_BADCHANNELKEY, ch.Name, "Cannot join channel (+k)")
+			return
+		}
+
+		if ch.Limit > 0 && len(ch.Members) >= ch.Limit {
+			c.numeric(tightbeam.ERR_CHANNELISFULL, ch.Name, "Cannot join channel (+l)")
+			return
+		}
+
+		if ch.Banned(c.prefix(), srv.casemap) {
+			c.numeric(tightbeam.ERR_BANNEDFROMCHAN, ch.Name, "Cannot join channel (+b)")
+			return
+		}
+	}
+
+	modes := ""
+	if len(ch.Members) == 0 {
+		modes = "o"
+	}
+
+	ch.Members[srv.fold(c.nick)] = modes
+	c.channels[folded] = true
+	srv.config.Channels.Put(folded, ch)
+
+	srv.sendChannel(ch, &tightbeam.Message{Prefix: c.prefix(), Command: "JOIN", Params: []string{ch.Name}}, nil)
+
+	if ch.Topic != "" {
+		srv.sendTopic(c, ch)
+	}
+
+	srv.sendNames(c, ch)
+}
+
+func (srv *Server) handlePart(c *conn, m *tightbeam.Message) {
+	reason := ""
+	if len(m.Params) > 1 {
+		reason = m.Params[1]
+	}
+
+	for _, name := range s.Split(m.Params[0], ",") {
+		folded := srv.fold(name)
+
+		ch, ok := srv.config.Channels.Get(folded)
+		if !ok {
+			c.numeric(tightbeam.ERR_NOSUCHCHANNEL, name, "No such channel")
+			continue
+		}
+
+		if !c.channels[folded] {
+			c.numeric(tightbeam.ERR_NOTONCHANNEL, ch.Name, "You're not on that channel")
+			continue
+		}
+
+		srv.part(c, ch, folded, reason)
+	}
+}
+
+func (srv *Server) part(c *conn, ch *Channel, folded, reason string) {
+	params := []string{ch.Name}
+	if reason != "" {
+		params = append(params, reason)
+	}
+
+	srv.sendChannel(ch, &tightbeam.Message{Prefix: c.prefix(), Command: "PART", Params: params}, nil)
+	srv.removeMember(c, folded)
+}
+
+func (srv *Server) removeMember(c *conn, folded string) {
+	delete(c.channels, folded)
+
+	ch, ok := srv.config.Channels.Get(folded)
+	if !ok {
+		return
+	}
+
+	delete(ch.Members, srv.fold(c.nick))
+
+	if len(ch.Members) == 0 {
+		srv.config.Channels.Delete(folded)
+		return
+	}
+
+	srv.config.Channels.Put(folded, ch)
+}
+
+func (srv *Server) handleMessage(c *conn, m *tightbeam.Message) {
+	notice := m.Command == "NOTICE"
+
+	if len(m.Params) < 1 || m.Params[0] == "" {
+		if !notice {
+			c.numeric(tightbeam.ERR_NORECIPIENT, "No recipient given ("+m.Command+")")
+		}
+		return
+	}
+
+	if len(m.Params) < 2 || m.Params[1] == "" {
+		if !notice {
+			c.numeric(tightbeam.ERR_NOTEXTTOSEND, "No text to send")
+		}
+		return
+	}
+
+	for _, target := range s.Split(m.Params[0], ",") {
+		out := &tightbeam.Message{Prefix: c.prefix(), Command: m.Command, Params: []string{target, m.Params[1]}}
+
+		if s.HasPrefix(target, "#") {
+			folded := srv.fold(target)
+
+			ch, ok := srv.config.Channels.Get(folded)
+			if !ok {
+				if !notice {
+					c.numeric(tightbeam.ERR_NOSUCHCHANNEL, target, "No such channel")
+				}
+				continue
+			}
+
+			modes, member := ch.Members[srv.fold(c.nick)]
+			if (ch.Modes['n'] && !member) || (ch.Modes['m'] && modes == "") || (modes == "" && ch.Banned(c.prefix(), srv.casemap)) {
+				if !notice {
+					c.numeric(tightbeam.ERR_CANNOTSENDTOCHAN, ch.Name, "Cannot send to channel")
+				}
+				continue
+			}
+
+			srv.sendChannel(ch, out, c)
+			continue
+		}
+
+		other, ok := srv.clients[srv.fold(target)]
+		if !ok || !other.registered {
+			if !notice {
+				c.numeric(tightbeam.ERR_NOSUCHNICK, target, "No such nick/channel")
+			}
+			continue
+		}
+
+		other.send(out)
+	}
+}
+
+func (srv *Server) handleTopic(c *conn, m *tightbeam.Message) {
+	folded := srv.fold(m.Params[0])
+
+	ch, ok := srv.config.Channels.Get(folded)
+	if !ok {
+		c.numeric(tightbeam.ERR_NOSUCHCHANNEL, m.Params[0], "No such channel")
+		return
+	}
+
+	if len(m.Params) < 2 {
+		if ch.Topic == "" {
+			c.numeric(tightbeam.RPL_NOTOPIC, ch.Name, "No topic is set")
+			return
+		}
+
+		srv.sendTopic(c, ch)
+		return
+	}
+
+	modes, member := ch.Members[srv.fold(c.nick)]
+	if !member {
+		c.numeric(tightbeam.ERR_NOTONCHANNEL, ch.Name, "You're not on that channel")
+		return
+	}
+
+	if ch.Modes['t'] && !s.Contains(modes, "o") {
+		c.numeric(tightbeam.ERR_CHANOPRIVSNEEDED, ch.Name, "You're not channel operator")
+		return
+	}
+
+	topic := m.Params[1]
+	if len(topic) > maxTopicLen {
+		topic = topic[:maxTopicLen]
+	}
+
+	ch.Topic = topic
+	ch.TopicSetBy = c.prefix().String()
+	ch.TopicSetAt = time.Now()
+	srv.config.Channels.Put(folded, ch)
+
+	srv.sendChannel(ch, &tightbeam.Message{Prefix: c.prefix(), Command: "TOPIC", Params: []string{ch.Name, topic}}, nil)
+}
+
+func (srv *Server) sendTopic(c *conn, ch *Channel) {
+	c.numeric(tightbeam.RPL_TOPIC, ch.Name, ch.Topic)
+	c.numeric(tightbeam.RPL_TOPICWHOTIME, ch.Name, ch.TopicSetBy, strconv.FormatInt(ch.TopicSetAt.Unix(), 10))
+}
+
+func (srv *Server) handleMode(c *conn, m *tightbeam.Message) {
+	target := m.Params[0]
+
+	if !s.HasPrefix(target, "#") {
+		if srv.fold(target) != srv.fold(c.nick) {
+			if _, ok := srv.clients[srv.fold(target)]; !ok {
+				c.numeric(tightbeam.ERR_NOSUCHNICK, target, "No such nick/channel")
+				return
+			}
+
+			c.numeric(tightbeam.ERR_USERSDONTMATCH, "Cant change mode for other users")
+			return
+		}
+
+		if len(m.Params) > 1 {
+			c.numeric(tightbeam.ERR_UMODEUNKNOWNFLAG, "Unknown MODE flag")
+			return
+		}
+
+		c.numeric(tightbeam.RPL_UMODEIS, "+")
+		return
+	}
+
+	folded := srv.fold(target)
+
+	ch, ok := srv.config.Channels.Get(folded)
+	if !ok {
+		c.numeric(tightbeam.ERR_NOSUCHCHANNEL, target, "No such channel")
+		return
+	}
+
+	if len(m.Params) < 2 {
+		modes, params := ch.ModeString()
+		c.numeric(tightbeam.RPL_CHANNELMODEIS, append([]string{ch.Name, modes}, params...)...)
+		c.numeric(tightbeam.RPL_CREATIONTIME, ch.Name, strconv.FormatInt(ch.Created.Unix(), 10))
+		return
+	}
+
+	if (m.Params[1] == "b" || m.Params[1] == "+b") && len(m.Params) < 3 {
+		for _, ban := range ch.Bans {
+			c.numeric(tightbeam.RPL_BANLIST, ch.Name, ban)
+		}
+		c.numeric(tightbeam.RPL_ENDOFBANLIST, ch.Name, "End of channel ban list")
+		return
+	}
+
+	if !s.Contains(ch.Members[srv.fold(c.nick)], "o") {
+		c.numeric(tightbeam.ERR_CHANOPRIVSNEEDED, ch.Name, "You're not channel operator")
+		return
+	}
+
+	args := m.Params[2:]
+	adding := true
+
+	applied := &s.Builder{}
+	var appliedArgs []string
+	lastSign := byte(0)
+
+	record := func(mode byte, arg string) {
+		sign := byte('-')
+		if adding {
+			sign = '+'
+		}
+
+		if sign != lastSign {
+			applied.WriteByte(sign)
+			lastSign = sign
+		}
+
+		applied.WriteByte(mode)
+		if arg != "" {
+			appliedArgs = append(appliedArgs, arg)
+		}
+	}
+
+	nextArg := func() (string, bool) {
+		if len(args) == 0 {
+			return "", false
+		}
+
+		arg := args[0]
+		args = args[1:]
+
+		return arg, true
+	}
+
+	for n := 0; n < len(m.Params[1]); n++ {
+		mode := m.Params[1][n]
+
+		switch mode {
+		case '+':
+			adding = true
+		case '-':
+			adding = false
+		case 'm', 'n', 's', 't':
+			if ch.Modes[mode] != adding {
+				ch.Modes[mode] = adding
+				record(mode, "")
+			}
+		case 'k':
+			if !adding {
+				nextArg()
+				if ch.Key != "" {
+					ch.Key = ""
+					record(mode, "*")
+				}
+				continue
+			}
+
+			key, ok := nextArg()
+			if !ok || key == "" || s.ContainsAny(key, " ,") {
+				continue
+			}
+
+			ch.Key = key
+			record(mode, key)
+		case 'l':
+			if !adding {
+				if ch.Limit > 0 {
+					ch.Limit = 0
+					record(mode, "")
+				}
+				continue
+			}
+
+			arg, ok := nextArg()
+			limit, err := strconv.Atoi(arg)
+			if !ok || err != nil || limit <= 0 {
+				continue
+			}
+
+			ch.Limit = limit
+			record(mode, arg)
+		case 'b':
+			arg, ok := nextArg()
+			if !ok || arg == "" {
+				continue
+			}
+
+			mask := tightbeam.ParseMask(arg).String()
+			n := ch.banIndex(mask, srv.casemap)
+			if (n >= 0) == adding {
+				continue
+			}
+
+			if adding {
+				ch.Bans = append(ch.Bans, mask)
+			} else {
+				ch.Bans = append(ch.Bans[:n:n], ch.Bans[n+1:]...)
+			}
+			record(mode, mask)
+		case 'o', 'v':
+			nick, ok := nextArg()
+			if !ok {
+				continue
+			}
+
+			key := srv.fold(nick)
+			modes, member := ch.Members[key]
+			other := srv.clients[key]
+			if !member || other == nil {
+				c.numeric(tightbeam.ERR_USERNOTINCHANNEL, nick, ch.Name, "They aren't on that channel")
+				continue
+			}
+
+			has := s.IndexByte(modes, mode) >= 0
+			if has == adding {
+				continue
+			}
+
+			if adding {
+				modes = memberModes(modes + string(mode))
+			} else {
+				modes = s.ReplaceAll(modes, string(mode), "")
+			}
+
+			ch.Members[key] = modes
+			record(mode, other.nick)
+		default:
+			c.numeric(tightbeam.ERR_UNKNOWNMODE, string(mode), "is unknown mode char to me")
+		}
+	}
+
+	if applied.Len() == 0 {
+		return
+	}
+
+	srv.config.Channels.Put(folded, ch)
+	srv.sendChannel(ch, &tightbeam.Message{
+		Prefix:  c.prefix(),
+		Command: "MODE",
+		Params:  append([]string{ch.Name, applied.String()}, appliedArgs...),
+	}, nil)
+}
+
+func (srv *Server) handleKick(c *conn, m *tightbeam.Message) {
+	folded := srv.fold(m.Params[0])
+
+	ch, ok := srv.config.Channels.Get(folded)
+	if !ok {
+		c.numeric(tightbeam.ERR_NOSUCHCHANNEL, m.Params[0], "No such channel")
+		return
+	}
+
+	modes, member := ch.Members[srv.fold(c.nick)]
+	if !member {
+		c.numeric(tightbeam.ERR_NOTONCHANNEL, ch.Name, "You're not on that channel")
+		return
+	}
+
+	if !s.Contains(modes, "o") {
+		c.numeric(tightbeam.ERR_CHANOPRIVSNEEDED, ch.Name, "You're not channel operator")
+		return
+	}
+
+	reason := c.nick
+	if len(m.Params) > 2 {
+		reason = m.Params[2]
+	}
+
+	for _, nick := range s.Split(m.Params[1], ",") {
+		key := srv.fold(nick)
+		victim := srv.clients[key]
+		if _, ok := ch.Members[key]; !ok || victim == nil {
+			c.numeric(tightbeam.ERR_USERNOTINCHANNEL, nick, ch.Name, "They aren't on that channel")
+			continue
+		}
+
+		srv.sendChannel(ch, &tightbeam.Message{Prefix: c.prefix(), Command: "KICK", Params: []string{ch.Name, victim.nick, reason}}, nil)
+		srv.removeMember(victim, folded)
+
+		if ch, ok = srv.config.Channels.Get(folded); !ok {
+			return
+		}
+	}
+}
+
+func (srv *Server) handleWho(c *conn, m *tightbeam.Message) {
+	mask := "*"
+	if len(m.Params) > 0 {
+		mask = m.Params[0]
+	}
+
+	if s.HasPrefix(mask, "#") {
+		if ch, ok := srv.config.Channels.Get(srv.fold(mask)); ok {
+			for key, modes := range ch.Members {
+				if other := srv.clients[key]; other != nil {
+					srv.sendWho(c, ch.Name, other, modes)
+				}
+			}
+		}
+	} else if other, ok := srv.clients[srv.fold(mask)]; ok && other.registered {
+		srv.sendWho(c, "*", other, "")
+	}
+
+	c.numeric(tightbeam.RPL_ENDOFWHO, mask, "End of WHO list")
+}
+
+func (srv *Server) sendWho(c *conn, channel string, other *conn, modes string) {
+	flags := "H" + memberPrefix(modes)
+	c.numeric(tightbeam.RPL_WHOREPLY, channel, other.user, other.host, srv.config.Name, other.nick, flags, "0 "+other.realname)
+}
+
+func (srv *Server) handleNames(c *conn, m *tightbeam.Message) {
+	if len(m.Params) < 1 {
+		c.numeric(tightbeam.RPL_ENDOFNAMES, "*", "End of /NAMES list.")
+		return
+	}
+
+	for _, name := range s.Split(m.Params[0], ",") {
+		ch, ok := srv.config.Channels.Get(srv.fold(name))
+		if !ok || (ch.Modes['s'] && !c.channels[srv.fold(name)]) {
+			c.numeric(tightbeam.RPL_ENDOFNAMES, name, "End of /NAMES list.")
+			continue
+		}
+
+		srv.sendNames(c, ch)
+	}
+}
+
+func (srv *Server) sendNames(c *conn, ch *Channel) {
+	symbol := "="
+	if ch.Modes['s'] {
+		symbol = "@"
+	}
+
+	buf := &s.Builder{}
+	flush := func() {
+		if buf.Len() > 0 {
+			c.numeric(tightbeam.RPL_NAMREPLY, symbol, ch.Name, buf.String())
+			buf.Reset()
+		}
+	}
+
+	for key, modes := range ch.Members {
+		other := srv.clients[key]
+		if other == nil {
+			continue
+		}
+
+		name := memberPrefix(modes) + other.nick
+
+		if buf.Len()+len(name)+1 > 400 {
+			flush()
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(name)
+	}
+
+	flush()
+
+	c.numeric(tightbeam.RPL_ENDOFNAMES, ch.Name, "End of /NAMES list.")
+}
+
+func (srv *Server) sendChannel(ch *Channel, m *tightbeam.Message, except *conn) {
+	for key := range ch.Members {
+		if other := srv.clients[key]; other != nil && other != except {
+			other.send(m)
+		}
+	}
+}
+
+func (srv *Server) sendCommon(c *conn, m *tightbeam.Message, self bool) {
+	seen := map[*conn]bool{c: true}
+
+	if self {
+		c.send(m)
+	}
+
+	for name := range c.channels {
+		ch, ok := srv.config.Channels.Get(name)
+		if !ok {
+			continue
+		}
+
+		for key := range ch.Members {
+			if other := srv.clients[key]; other != nil && !seen[other] {
+				seen[other] = true
+				other.send(m)
+			}
+		}
+	}
+}
+
+func (c *conn) target() string {
+	if c.nick == "" {
+		return "*"
+	}
+
+	return c.nick
+}
+
+func memberModes(modes string) string {
+	ret := ""
+	for _, m := range "ov" {
+		if s.ContainsRune(modes, m) {
+			ret += string(m)
+		}
+	}
+
+	return ret
+}
+
+func memberPrefix(modes string) string {
+	ret := ""
+	if s.Contains(modes, "o") {
+		ret += "@"
+	}
+	if s.Contains(modes, "v") {
+		ret += "+"
+	}
+
+	return ret
+}
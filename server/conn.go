@@ -0,0 +1,181 @@
+package server
+
+import (
+	s "strings"
+	"sync"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+type conn struct {
+	srv       *Server
+	transport tightbeam.Transport
+
+	nick     string
+	user     string
+	realname string
+	host     string
+	pass     string
+	account  string
+
+	capNegotiating bool
+	registered     bool
+	channels       map[string]bool
+	session        *Session
+
+	out       chan *tightbeam.Message
+	closeOnce sync.Once
+	closed    chan struct{}
+	quitMsg   string
+}
+
+func newConn(srv *Server, t tightbeam.Transport) *conn {
+	return &conn{
+		srv:       srv,
+		transport: t,
+		host:      remoteHost(t),
+		channels:  map[string]bool{},
+		out:       make(chan *tightbeam.Message, srv.config.SendQueue),
+		closed:    make(chan struct{}),
+	}
+}
+
+func (c *conn) prefix() *tightbeam.Prefix {
+	return &tightbeam.Prefix{Name: c.nick, User: c.user, Host: c.host}
+}
+
+func (c *conn) serve() {
+	go c.writeLoop()
+
+	for {
+		line, err := c.transport.ReadLine()
+		if err != nil {
+			break
+		}
+
+		m, err := tightbeam.ParseMessage(line)
+		if err != nil {
+			continue
+		}
+
+		c.srv.lock.Lock()
+		session := c.session
+		if session == nil || sessionLocal(m) {
+			c.srv.dispatch(c, m)
+		}
+		attached := session == nil && c.session != nil
+		c.srv.lock.Unlock()
+
+		switch {
+		case attached:
+			c.srv.config.Sessions.Attach(c.session)
+		case session != nil && !sessionLocal(m):
+			c.srv.config.Sessions.Handle(session, m)
+		}
+	}
+
+	if c.session != nil {
+		c.srv.config.Sessions.Detach(c.session)
+	}
+
+	c.srv.lock.Lock()
+	reason := c.quitMsg
+	if reason == "" {
+		reason = "Connection closed"
+	}
+	c.srv.quit(c, reason)
+	delete(c.srv.conns, c)
+	c.srv.lock.Unlock()
+
+	c.close("")
+}
+
+func (c *conn) writeLoop() {
+	defer c.transport.Close()
+
+	for {
+		select {
+		case m := <-c.out:
+			if err := c.transport.WriteLine(m.String()); err != nil {
+				c.close("")
+				return
+			}
+		case <-c.closed:
+			for {
+				select {
+				case m := <-c.out:
+					if c.transport.WriteLine(m.String()) != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *conn) send(m *tightbeam.Message) {
+	select {
+	case <-c.closed:
+		return
+	default:
+	}
+
+	select {
+	case c.out <- m:
+	default:
+		c.quitMsg = "SendQ exceeded"
+		c.close("")
+	}
+}
+
+func (c *conn) numeric(command string, params ...string) {
+	c.send(&tightbeam.Message{
+		Prefix:  c.srv.prefix(),
+		Command: command,
+		Params:  append([]string{c.target()}, params...),
+	})
+}
+
+func (c *conn) close(reason string) {
+	c.closeOnce.Do(func() {
+		if reason != "" {
+			c.quitMsg = reason
+			select {
+			case c.out <- &tightbeam.Message{Command: "ERROR", Params: []string{"Closing Link: " + reason}}:
+			default:
+			}
+		}
+
+		close(c.closed)
+
+		time.AfterFunc(closeTimeout, func() {
+			c.transport.Close()
+		})
+	})
+}
+
+func validNick(nick string) bool {
+	if nick == "" || len(nick) > maxNickLen {
+		return false
+	}
+
+	for n, r := range nick {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case s.ContainsRune("[]\\`_^{|}", r):
+		case n > 0 && (r >= '0' && r <= '9' || r == '-'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func validChannel(name string) bool {
+	return len(name) > 1 && len(name) <= maxChannelLen && name[0] == '#' &&
+		!s.ContainsAny(name, " ,\x07")
+}
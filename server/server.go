@@ -0,0 +1,190 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	s "strings"
+	"sync"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+var ErrorServerClosed = errors.New("irc: Server closed")
+
+type Authenticator interface {
+	Authenticate(nick, user, pass string) (account string, err error)
+}
+
+type AuthenticatorFunc func(nick, user, pass string) (string, error)
+
+func (f AuthenticatorFunc) Authenticate(nick, user, pass string) (string, error) {
+	return f(nick, user, pass)
+}
+
+type Config struct {
+	Name    string
+	Network string
+	MOTD    []string
+
+	Auth     Authenticator
+	Channels ChannelStore
+	Sessions SessionHandler
+
+	SendQueue int
+}
+
+type Server struct {
+	config  Config
+	created time.Time
+	casemap tightbeam.CaseMapping
+
+	lock      sync.Mutex
+	clients   map[string]*conn
+	conns     map[*conn]struct{}
+	listeners map[net.Listener]struct{}
+	closed    bool
+}
+
+func New(config Config) *Server {
+	if config.Name == "" {
+		config.Name = "tightbeam.local"
+	}
+
+	if config.Network == "" {
+		config.Network = "tightbeam"
+	}
+
+	if config.Channels == nil {
+		config.Channels = NewMemoryStore()
+	}
+
+	if config.SendQueue <= 0 {
+		config.SendQueue = 512
+	}
+
+	return &Server{
+		config:    config,
+		created:   time.Now(),
+		casemap:   tightbeam.CaseMappingASCII,
+		clients:   map[string]*conn{},
+		conns:     map[*conn]struct{}{},
+		listeners: map[net.Listener]struct{}{},
+	}
+}
+
+func (srv *Server) ISupport() []string {
+	return []string{
+		"CASEMAPPING=" + string(srv.casemap),
+		"CHANLIMIT=#:",
+		"CHANMODES=b,k,l,mnst",
+		"CHANNELLEN=" + itoa(maxChannelLen),
+		"CHANTYPES=#",
+		"MODES=4",
+		"NETWORK=" + srv.config.Network,
+		"NICKLEN=" + itoa(maxNickLen),
+		"PREFIX=(ov)@+",
+		"TARGMAX=PRIVMSG:4,NOTICE:4,JOIN:,PART:,KICK:1,NAMES:1,WHO:1",
+		"TOPICLEN=" + itoa(maxTopicLen),
+	}
+}
+
+func (srv *Server) Serve(l net.Listener) error {
+	srv.lock.Lock()
+	if srv.closed {
+		srv.lock.Unlock()
+		return ErrorServerClosed
+	}
+	srv.listeners[l] = struct{}{}
+	srv.lock.Unlock()
+
+	defer func() {
+		srv.lock.Lock()
+		delete(srv.listeners, l)
+		srv.lock.Unlock()
+	}()
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			srv.lock.Lock()
+			closed := srv.closed
+			srv.lock.Unlock()
+
+			if closed {
+				return ErrorServerClosed
+			}
+			return err
+		}
+
+		go srv.ServeConn(nc)
+	}
+}
+
+func (srv *Server) ServeConn(rwc io.ReadWriteCloser) {
+	srv.ServeTransport(tightbeam.NewStreamTransport(rwc))
+}
+
+func (srv *Server) ServeTransport(t tightbeam.Transport) {
+	c := newConn(srv, t)
+
+	srv.lock.Lock()
+	if srv.closed {
+		srv.lock.Unlock()
+		t.Close()
+		return
+	}
+	srv.conns[c] = struct{}{}
+	srv.lock.Unlock()
+
+	c.serve()
+}
+
+func (srv *Server) Close() error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	srv.closed = true
+
+	for l := range srv.listeners {
+		l.Close()
+	}
+
+	for c := range srv.conns {
+		c.close("Server shutting down")
+	}
+
+	return nil
+}
+
+func (srv *Server) fold(name string) string {
+	return srv.casemap.Fold(name)
+}
+
+func (srv *Server) prefix() *tightbeam.Prefix {
+	return &tightbeam.Prefix{Name: srv.config.Name}
+}
+
+func remoteHost(t tightbeam.Transport) string {
+	ra, ok := t.(interface{ RemoteAddr() net.Addr })
+	if !ok || ra.RemoteAddr() == nil {
+		return "localhost"
+	}
+
+	host, _, err := net.SplitHostPort(ra.RemoteAddr().String())
+	if err != nil || host == "" {
+		return "localhost"
+	}
+
+	if s.HasPrefix(host, ":") {
+		host = "0" + host
+	}
+
+	return host
+}
+
+func itoa(n int) string {
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,617 @@
+package server_test
+
+import (
+	"net"
+	"sort"
+	s "strings"
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/server"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+type client struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *tightbeam.Reader
+	writer *tightbeam.Writer
+}
+
+func dial(t *testing.T, srv *server.Server) *client {
+	t.Helper()
+
+	c, conn := net.Pipe()
+	go srv.ServeConn(conn)
+	t.Cleanup(func() { c.Close() })
+
+	return &client{t: t, conn: c, reader: tightbeam.NewReader(c), writer: tightbeam.NewWriter(c)}
+}
+
+func register(t *testing.T, srv *server.Server, nick string) *client {
+	t.Helper()
+
+	c := dial(t, srv)
+	c.send("NICK " + nick)
+	c.send("USER " + nick + " 0 * :Real " + nick)
+
+	for {
+		if m := c.next(); m.Command == tightbeam.RPL_ENDOFMOTD || m.Command == tightbeam.ERR_NOMOTD {
+			return c
+		}
+	}
+}
+
+func (c *client) send(line string) {
+	c.t.Helper()
+
+	c.conn.SetWriteDeadline(time.Now().Add(tightbeamtest.DefaultTimeout))
+	if err := c.writer.WriteLine(line); err != nil {
+		c.t.Fatalf("writing %q: %v", line, err)
+	}
+}
+
+func (c *client) next() *tightbeam.Message {
+	c.t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(tightbeamtest.DefaultTimeout))
+
+	m, err := c.reader.ReadMessage()
+	if err != nil {
+		c.t.Fatalf("reading: %v", err)
+	}
+
+	return m
+}
+
+func (c *client) expect(pattern string) *tightbeam.Message {
+	c.t.Helper()
+
+	m := c.next()
+
+	if diff := tightbeamtest.MustPattern(pattern).Diff(m); diff != "" {
+		c.t.Fatalf("unexpected message\n  want: %s\n   got: %s\n%s", pattern, m, diff)
+	}
+
+	return m
+}
+
+func (c *client) expectNames(channel string, names ...string) {
+	c.t.Helper()
+
+	var got []string
+	for {
+		m := c.next()
+		if m.Command == tightbeam.RPL_ENDOFNAMES {
+			break
+		}
+
+		if m.Command != tightbeam.RPL_NAMREPLY || len(m.Params) < 4 || m.Params[2] != channel {
+			c.t.Fatalf("unexpected message %q while reading NAMES for %s", m, channel)
+		}
+
+		got = append(got, s.Fields(m.Params[3])...)
+	}
+
+	sort.Strings(got)
+	sort.Strings(names)
+
+	if s.Join(got, " ") != s.Join(names, " ") {
+		c.t.Fatalf("NAMES %s = %q, want %q", channel, got, names)
+	}
+}
+
+func (c *client) sync() {
+	c.t.Helper()
+
+	c.send("PING sync")
+	c.expect(":tightbeam.local PONG tightbeam.local sync")
+}
+
+func TestRegistration(t *testing.T) {
+	srv := server.New(server.Config{MOTD: []string{"hello"}})
+	defer srv.Close()
+
+	alice := dial(t, srv)
+	alice.send("JOIN #chan")
+	alice.expect(":tightbeam.local 451 * *")
+	alice.send("NICK 1bad")
+	alice.expect(":tightbeam.local 432 * 1bad *")
+	alice.send("NICK")
+	alice.expect(":tightbeam.local 431 * *")
+	alice.send("USER")
+	alice.expect(":tightbeam.local 461 * USER *")
+	alice.send("NICK alice")
+	alice.send("USER alice 0 * :Alice Liddell")
+	alice.expect(":tightbeam.local 001 alice :Welcome to the tightbeam Network, alice!alice@localhost")
+	alice.expect(":tightbeam.local 002 alice *")
+	alice.expect(":tightbeam.local 003 alice *")
+	alice.expect(":tightbeam.local 004 alice tightbeam.local tightbeam o bklmnostv")
+	isupport := alice.expect(":tightbeam.local 005 alice **")
+	alice.expect(":tightbeam.local 375 alice *")
+	alice.expect(":tightbeam.local 372 alice :- hello")
+	alice.expect(":tightbeam.local 376 alice *")
+
+	if !s.Contains(isupport.String(), "CASEMAPPING=ascii") || !s.Contains(isupport.String(), "PREFIX=(ov)@+") {
+		t.Fatalf("ISUPPORT = %q", isupport)
+	}
+
+	alice.send("USER alice 0 * :Again")
+	alice.expect(":tightbeam.local 462 alice *")
+	alice.send("PASS late")
+	alice.expect(":tightbeam.local 462 alice *")
+	alice.send("FROB")
+	alice.expect(":tightbeam.local 421 alice FROB *")
+	alice.send("MODE alice")
+	alice.expect(":tightbeam.local 221 alice +")
+
+	other := dial(t, srv)
+	other.send("NICK ALICE")
+	other.expect(":tightbeam.local 433 * ALICE *")
+	other.send("NICK bob")
+	other.send("USER averyveryverylongusername 0 * :Bob")
+	other.expect(":tightbeam.local 001 bob :Welcome to the tightbeam Network, bob!averyveryv@localhost")
+	for other.next().Command != tightbeam.RPL_ENDOFMOTD {
+	}
+
+	alice.send("MODE bob")
+	alice.expect(":tightbeam.local 502 alice *")
+	alice.send("MODE nobody")
+	alice.expect(":tightbeam.local 401 alice nobody *")
+
+	alice.send("NICK Alice2")
+	alice.expect(":alice!alice@localhost NICK Alice2")
+	alice.send("PRIVMSG BOB :hi")
+	other.expect(":Alice2!alice@localhost PRIVMSG BOB hi")
+	other.send("PRIVMSG alice :hi")
+	other.expect(":tightbeam.local 401 bob alice *")
+	other.send("NOTICE alice :hi")
+	other.send("PRIVMSG alice2")
+	other.expect(":tightbeam.local 412 bob *")
+
+	alice.send("QUIT :bye")
+	alice.expect("ERROR :Closing Link: Quit: bye")
+}
+
+func TestRegistrationCapAndAuth(t *testing.T) {
+	srv := server.New(server.Config{
+		Auth: server.AuthenticatorFunc(func(nick, user, pass string) (string, error) {
+			if pass != "secret" {
+				return "", server.ErrorServerClosed
+			}
+			return user, nil
+		}),
+	})
+	defer srv.Close()
+
+	c := dial(t, srv)
+	c.send("CAP LS 302")
+	c.expect(":tightbeam.local CAP * LS :")
+	c.send("PASS secret")
+	c.send("NICK carol")
+	c.send("USER carol 0 * :Carol")
+	c.send("CAP REQ :multi-prefix")
+	c.expect(":tightbeam.local CAP carol NAK multi-prefix")
+	c.send("CAP END")
+	c.expect(":tightbeam.local 001 carol **")
+
+	bad := dial(t, srv)
+	bad.send("PASS wrong")
+	bad.send("NICK dave")
+	bad.send("USER dave 0 * :Dave")
+	bad.expect(":tightbeam.local 464 dave *")
+	bad.expect("ERROR :Closing Link: Bad password")
+}
+
+type sessions struct {
+	attached chan *server.Session
+	handled  chan *tightbeam.Message
+}
+
+func (h *sessions) Attach(sess *server.Session) {
+	h.attached <- sess
+}
+
+func (h *sessions) Handle(sess *server.Session, m *tightbeam.Message) {
+	h.handled <- m
+}
+
+func (h *sessions) Detach(sess *server.Session) {}
+
+func TestSessions(t *testing.T) {
+	h := &sessions{attached: make(chan *server.Session, 1), handled: make(chan *tightbeam.Message, 1)}
+	srv := server.New(server.Config{
+		Sessions: h,
+		Auth: server.AuthenticatorFunc(func(nick, user, pass string) (string, error) {
+			return "acct-" + pass, nil
+		}),
+	})
+	defer srv.Close()
+
+	c := dial(t, srv)
+	c.send("PASS secret")
+	c.send("NICK alice")
+	c.send("USER averylongusername 0 * :Alice")
+
+	sess := <-h.attached
+	if sess.User() != "averylongusername" || sess.Account() != "acct-secret" || sess.Nick() != "alice" {
+		t.Fatalf("session user %q, account %q, nick %q", sess.User(), sess.Account(), sess.Nick())
+	}
+
+	c.send("PRIVMSG #chan :hi")
+	if m := <-h.handled; m.String() != "PRIVMSG #chan hi" {
+		t.Fatalf("Handle() got %q", m)
+	}
+
+	c.send("PING :x")
+	c.expect(":tightbeam.local PONG tightbeam.local x")
+
+	plain := server.New(server.Config{})
+	defer plain.Close()
+
+	short := dial(t, plain)
+	short.send("NICK bob")
+	short.send("USER averylongusername 0 * :Bob")
+	short.expect(":tightbeam.local 001 bob :Welcome to the tightbeam Network, bob!averylongu@localhost")
+}
+
+func TestChannel(t *testing.T) {
+	srv := server.New(server.Config{})
+	defer srv.Close()
+
+	alice := register(t, srv, "alice")
+	bob := register(t, srv, "bob")
+
+	alice.send("JOIN #Chan,bad")
+	alice.expect(":alice!alice@localhost JOIN #Chan")
+	alice.expectNames("#Chan", "@alice")
+	alice.expect(":tightbeam.local 476 alice bad *")
+
+	bob.send("JOIN #chan")
+	bob.expect(":bob!bob@localhost JOIN #Chan")
+	bob.expectNames("#Chan", "@alice", "bob")
+	alice.expect(":bob!bob@localhost JOIN #Chan")
+
+	bob.send("TOPIC #chan :mine")
+	bob.expect(":tightbeam.local 482 bob #Chan *")
+	bob.send("TOPIC #chan")
+	bob.expect(":tightbeam.local 331 bob #Chan *")
+	alice.send("TOPIC #chan :the topic")
+	alice.expect(":alice!alice@localhost TOPIC #Chan :the topic")
+	bob.expect(":alice!alice@localhost TOPIC #Chan :the topic")
+	bob.send("TOPIC #chan")
+	bob.expect(":tightbeam.local 332 bob #Chan :the topic")
+	bob.expect(":tightbeam.local 333 bob #Chan alice!alice@localhost *")
+
+	alice.send("MODE #chan")
+	alice.expect(":tightbeam.local 324 alice #Chan +nt")
+	alice.expect(":tightbeam.local 329 alice #Chan *")
+
+	bob.send("MODE #chan +m")
+	bob.expect(":tightbeam.local 482 bob #Chan *")
+
+	alice.send("MODE #chan +mk-t+x secret")
+	alice.expect(":tightbeam.local 472 alice x *")
+	alice.expect(":alice!alice@localhost MODE #Chan +mk-t secret")
+	bob.expect(":alice!alice@localhost MODE #Chan +mk-t secret")
+
+	bob.send("PRIVMSG #chan :muted")
+	bob.expect(":tightbeam.local 404 bob #Chan *")
+
+	alice.send("MODE #chan +v bob")
+	alice.expect(":alice!alice@localhost MODE #Chan +v bob")
+	bob.expect(":alice!alice@localhost MODE #Chan +v bob")
+
+	bob.send("PRIVMSG #chan :voiced")
+	alice.expect(":bob!bob@localhost PRIVMSG #chan voiced")
+
+	carol := register(t, srv, "carol")
+	carol.send("JOIN #chan")
+	carol.expect(":tightbeam.local 475 carol #Chan *")
+	carol.send("PRIVMSG #chan :outside")
+	carol.expect(":tightbeam.local 404 carol #Chan *")
+	carol.send("JOIN #chan secret")
+	carol.expect(":carol!carol@localhost JOIN #Chan")
+	carol.expect(":tightbeam.local 332 carol #Chan :the topic")
+	carol.expect(":tightbeam.local 333 carol **")
+	carol.expectNames("#Chan", "@alice", "+bob", "carol")
+	alice.expect(":carol!carol@localhost JOIN #Chan")
+	bob.expect(":carol!carol@localhost JOIN #Chan")
+
+	alice.send("MODE #chan -k+l wrong 3")
+	alice.expect(":alice!alice@localhost MODE #Chan -k+l * 3")
+	bob.expect("MODE #Chan -k+l * 3")
+	carol.expect("MODE #Chan -k+l * 3")
+
+	dave := register(t, srv, "dave")
+	dave.send("JOIN #chan")
+	dave.expect(":tightbeam.local 471 dave #Chan *")
+
+	alice.send("WHO #chan")
+	var who []string
+	for {
+		m := alice.next()
+		if m.Command == tightbeam.RPL_ENDOFWHO {
+			break
+		}
+		who = append(who, m.Params[5]+" "+m.Params[6]+" "+m.Params[7])
+	}
+	sort.Strings(who)
+	if s.Join(who, ",") != "alice H@ 0 Real alice,bob H+ 0 Real bob,carol H 0 Real carol" {
+		t.Fatalf("WHO #chan = %q", who)
+	}
+
+	alice.send("WHO carol")
+	alice.expect(":tightbeam.local 352 alice * carol localhost tightbeam.local carol H :0 Real carol")
+	alice.expect(":tightbeam.local 315 alice carol *")
+
+	bob.send("NICK robert")
+	bob.expect(":bob!bob@localhost NICK robert")
+	alice.expect(":bob!bob@localhost NICK robert")
+	carol.expect(":bob!bob@localhost NICK robert")
+
+	alice.send("NAMES #chan")
+	alice.expectNames("#Chan", "@alice", "+robert", "carol")
+
+	carol.send("KICK #chan robert")
+	carol.expect(":tightbeam.local 482 carol #Chan *")
+	alice.send("KICK #chan robert,nobody :out")
+	alice.expect(":alice!alice@localhost KICK #Chan robert out")
+	alice.expect(":tightbeam.local 441 alice nobody #Chan *")
+	bob.expect(":alice!alice@localhost KICK #Chan robert out")
+	carol.expect(":alice!alice@localhost KICK #Chan robert out")
+
+	bob.send("PART #chan")
+	bob.expect(":tightbeam.local 442 robert #Chan *")
+
+	carol.send("PART #chan :later")
+	carol.expect(":carol!carol@localhost PART #Chan later")
+	alice.expect(":carol!carol@localhost PART #Chan later")
+
+	alice.send("PART #chan")
+	alice.expect(":alice!alice@localhost PART #Chan")
+	alice.send("PART #chan")
+	alice.expect(":tightbeam.local 403 alice #chan *")
+
+	alice.send("JOIN #chan")
+	alice.expect("JOIN #chan")
+	alice.expectNames("#chan", "@alice")
+}
+
+func TestChannelSecret(t *testing.T) {
+	srv := server.New(server.Config{})
+	defer srv.Close()
+
+	alice := register(t, srv, "alice")
+	bob := register(t, srv, "bob")
+
+	alice.send("JOIN #hidden")
+	alice.expect("JOIN #hidden")
+	alice.expectNames("#hidden", "@alice")
+	alice.send("MODE #hidden +s")
+	alice.expect("MODE #hidden +s")
+
+	bob.send("NAMES #hidden")
+	bob.expect(":tightbeam.local 366 bob #hidden *")
+
+	alice.send("NAMES #hidden")
+	alice.expect(":tightbeam.local 353 alice @ #hidden @alice")
+	alice.expect(":tightbeam.local 366 alice #hidden *")
+
+	alice.send("JOIN 0")
+	alice.expect(":alice!alice@localhost PART #hidden")
+}
+
+func TestBans(t *testing.T) {
+	srv := server.New(server.Config{})
+	defer srv.Close()
+
+	alice := register(t, srv, "alice")
+	bob := register(t, srv, "bob")
+
+	alice.send("JOIN #chan")
+	alice.expect("JOIN #chan")
+	alice.expectNames("#chan", "@alice")
+	bob.send("JOIN #chan")
+	bob.expect("JOIN #chan")
+	bob.expectNames("#chan", "@alice", "bob")
+	alice.expect("JOIN #chan")
+
+	bob.send("MODE #chan +b bob")
+	bob.expect(":tightbeam.local 482 bob #chan *")
+
+	alice.send("MODE #chan +b bob")
+	alice.expect(":alice!alice@localhost MODE #chan +b bob!*@*")
+	bob.expect(":alice!alice@localhost MODE #chan +b bob!*@*")
+
+	alice.send("MODE #chan +b BOB!*@*")
+	alice.send("MODE #chan +b $a:account")
+	alice.expect(":alice!alice@localhost MODE #chan +b $a:account")
+	bob.expect(":alice!alice@localhost MODE #chan +b $a:account")
+
+	bob.send("MODE #chan b")
+	bob.expect(":tightbeam.local 367 bob #chan bob!*@*")
+	bob.expect(":tightbeam.local 367 bob #chan $a:account")
+	bob.expect(":tightbeam.local 368 bob #chan *")
+
+	bob.send("PRIVMSG #chan :banned")
+	bob.expect(":tightbeam.local 404 bob #chan *")
+
+	bob.send("PART #chan")
+	bob.expect("PART #chan")
+	alice.expect("PART #chan")
+
+	bob.send("JOIN #chan")
+	bob.expect(":tightbeam.local 474 bob #chan *")
+
+	alice.send("MODE #chan -b+v Bob!*@* bob")
+	alice.expect(":tightbeam.local 441 alice bob #chan *")
+	alice.expect(":alice!alice@localhost MODE #chan -b Bob!*@*")
+
+	bob.send("JOIN #chan")
+	bob.expect("JOIN #chan")
+	bob.expectNames("#chan", "@alice", "bob")
+	alice.expect("JOIN #chan")
+
+	alice.send("MODE #chan +b *!*@localhost")
+	alice.expect("MODE #chan +b *!*@localhost")
+	bob.expect("MODE #chan +b *!*@localhost")
+
+	alice.send("PRIVMSG #chan :ops may talk")
+	bob.expect(":alice!alice@localhost PRIVMSG #chan :ops may talk")
+
+	alice.send("MODE #chan +v bob")
+	alice.expect("MODE #chan +v bob")
+	bob.expect("MODE #chan +v bob")
+
+	bob.send("PRIVMSG #chan :voiced may talk")
+	alice.expect(":bob!bob@localhost PRIVMSG #chan :voiced may talk")
+}
+
+func TestKeyArgument(t *testing.T) {
+	srv := server.New(server.Config{})
+	defer srv.Close()
+
+	alice := register(t, srv, "alice")
+	bob := register(t, srv, "bob")
+
+	alice.send("JOIN #chan")
+	alice.expect("JOIN #chan")
+	alice.expectNames("#chan", "@alice")
+	bob.send("JOIN #chan")
+	bob.expect("JOIN #chan")
+	bob.expectNames("#chan", "@alice", "bob")
+	alice.expect("JOIN #chan")
+
+	alice.send("MODE #chan -k+v oldkey bob")
+	alice.expect(":alice!alice@localhost MODE #chan +v bob")
+	bob.expect(":alice!alice@localhost MODE #chan +v bob")
+
+	alice.send("MODE #chan +k")
+	alice.send("MODE #chan +k bad,key")
+	alice.send("MODE #chan +l nope")
+	alice.send("MODE #chan +ko")
+	alice.sync()
+
+	alice.send("MODE #chan")
+	alice.expect(":tightbeam.local 324 alice #chan +nt")
+	alice.expect(":tightbeam.local 329 alice #chan *")
+}
+
+func TestStaleMembers(t *testing.T) {
+	store := server.NewMemoryStore()
+
+	ch := server.NewChannel("#old")
+	ch.Members["ghost"] = "o"
+	store.Put("#old", ch)
+
+	srv := server.New(server.Config{Channels: store})
+	defer srv.Close()
+
+	alice := register(t, srv, "alice")
+	alice.send("JOIN #old")
+	alice.expect(":alice!alice@localhost JOIN #old")
+	alice.expectNames("#old", "alice")
+
+	ch.Members["alice"] = "o"
+
+	alice.send("MODE #old +o ghost")
+	alice.expect(":tightbeam.local 441 alice ghost #old *")
+	alice.send("MODE #old -v ghost")
+	alice.expect(":tightbeam.local 441 alice ghost #old *")
+
+	alice.send("KICK #old ghost")
+	alice.expect(":tightbeam.local 441 alice ghost #old *")
+
+	alice.send("WHO #old")
+	alice.expect(":tightbeam.local 352 alice #old alice localhost tightbeam.local alice H@ :0 Real alice")
+	alice.expect(":tightbeam.local 315 alice #old *")
+
+	alice.send("NAMES #old")
+	alice.expectNames("#old", "@alice")
+
+	alice.send("PRIVMSG #old :anyone?")
+	alice.send("TOPIC #old :still here")
+	alice.expect(":alice!alice@localhost TOPIC #old :still here")
+
+	alice.send("NICK alicia")
+	alice.expect(":alice!alice@localhost NICK alicia")
+
+	alice.send("PART #old")
+	alice.expect(":alicia!alice@localhost PART #old")
+
+	if ch, ok := store.Get("#old"); !ok || len(ch.Members) != 1 {
+		t.Fatalf("store after PART = %+v, %v", ch, ok)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := server.NewMemoryStore()
+
+	if _, ok := store.Get("#a"); ok || len(store.List()) != 0 {
+		t.Fatal("new store is not empty")
+	}
+
+	a, b := server.NewChannel("#a"), server.NewChannel("#b")
+	store.Put("#a", a)
+	store.Put("#b", b)
+	store.Put("#b", b)
+
+	if got, ok := store.Get("#a"); !ok || got != a {
+		t.Fatalf("Get(#a) = %v, %v", got, ok)
+	}
+
+	if len(store.List()) != 2 {
+		t.Fatalf("List() = %v", store.List())
+	}
+
+	store.Delete("#a")
+	store.Delete("#missing")
+
+	if _, ok := store.Get("#a"); ok || len(store.List()) != 1 {
+		t.Fatal("Delete(#a) did not remove the channel")
+	}
+}
+
+func TestChannelModeString(t *testing.T) {
+	for _, tt := range []struct {
+		modes  map[byte]bool
+		key    string
+		limit  int
+		want   string
+		params []string
+	}{
+		{map[byte]bool{}, "", 0, "+", nil},
+		{map[byte]bool{'t': true, 'n': true, 'm': false}, "", 0, "+nt", nil},
+		{map[byte]bool{'s': true, 'm': true}, "key", 5, "+mskl", []string{"key", "5"}},
+	} {
+		ch := server.NewChannel("#c")
+		ch.Modes, ch.Key, ch.Limit = tt.modes, tt.key, tt.limit
+
+		modes, params := ch.ModeString()
+		if modes != tt.want || s.Join(params, " ") != s.Join(tt.params, " ") {
+			t.Errorf("ModeString() = %q %q, want %q %q", modes, params, tt.want, tt.params)
+		}
+	}
+}
+
+func TestChannelBanned(t *testing.T) {
+	ch := server.NewChannel("#c")
+	ch.Bans = []string{"bad!*@*", "*!*@*.example", "$a:acct"}
+
+	for _, tt := range []struct {
+		prefix string
+		banned bool
+	}{
+		{"bad!u@host", true},
+		{"BAD!u@host", true},
+		{"good!u@host.example", true},
+		{"good!u@example", false},
+		{"good!u@host", false},
+	} {
+		if banned := ch.Banned(tightbeam.ParsePrefix(tt.prefix), tightbeam.CaseMappingASCII); banned != tt.banned {
+			t.Errorf("Banned(%q) = %v, want %v", tt.prefix, banned, tt.banned)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+type SessionHandler interface {
+	Attach(s *Session)
+	Handle(s *Session, m *tightbeam.Message)
+	Detach(s *Session)
+}
+
+type Session struct {
+	c *conn
+}
+
+func (s *Session) Nick() string {
+	s.c.srv.lock.Lock()
+	defer s.c.srv.lock.Unlock()
+
+	return s.c.nick
+}
+
+func (s *Session) SetNick(nick string) {
+	s.c.srv.lock.Lock()
+	defer s.c.srv.lock.Unlock()
+
+	s.c.nick = nick
+}
+
+func (s *Session) User() string {
+	return s.c.user
+}
+
+func (s *Session) Account() string {
+	return s.c.account
+}
+
+func (s *Session) Realname() string {
+	return s.c.realname
+}
+
+func (s *Session) Host() string {
+	return s.c.host
+}
+
+func (s *Session) Send(m *tightbeam.Message) {
+	s.c.srv.lock.Lock()
+	defer s.c.srv.lock.Unlock()
+
+	s.c.send(m)
+}
+
+func (s *Session) Numeric(command string, params ...string) {
+	s.c.srv.lock.Lock()
+	defer s.c.srv.lock.Unlock()
+
+	s.c.numeric(command, params...)
+}
+
+func (s *Session) Close(reason string) {
+	s.c.close(reason)
+}
+
+func (s *Session) Done() <-chan struct{} {
+	return s.c.closed
+}
+
+func sessionLocal(m *tightbeam.Message) bool {
+	switch m.Command {
+	case "PING", "PONG", "QUIT", "CAP", "PASS", "USER":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,178 @@
+package throttle
+
+import (
+	"errors"
+	s "strings"
+	"sync"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+var ErrorMaskTooBroad = errors.New("throttle: Mask matches everyone")
+
+type State struct {
+	Tokens  float64
+	Updated time.Time
+	Until   time.Time
+	Strikes int
+}
+
+type Store interface {
+	Get(key string) (State, bool)
+	Put(key string, st State)
+	Delete(key string)
+	Keys() []string
+
+	Ignore(mask string, until time.Time) error
+	Unignore(mask string)
+	Ignores() map[string]time.Time
+	MatchIgnore(from *tightbeam.Prefix, casemap tightbeam.CaseMapping, now time.Time) (time.Time, bool)
+}
+
+type MemoryStore struct {
+	lock   sync.Mutex
+	states map[string]State
+
+	ignores   map[string]*ignore
+	byHost    map[string]map[string]*ignore
+	wildcards map[string]*ignore
+}
+
+type ignore struct {
+	mask  *tightbeam.Mask
+	until time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states:    map[string]State{},
+		ignores:   map[string]*ignore{},
+		byHost:    map[string]map[string]*ignore{},
+		wildcards: map[string]*ignore{},
+	}
+}
+
+func (m *MemoryStore) Get(key string) (State, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	st, ok := m.states[key]
+	return st, ok
+}
+
+func (m *MemoryStore) Put(key string, st State) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.states[key] = st
+}
+
+func (m *MemoryStore) Delete(key string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.states, key)
+}
+
+func (m *MemoryStore) Keys() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ret := make([]string, 0, len(m.states))
+	for k := range m.states {
+		ret = append(ret, k)
+	}
+
+	return ret
+}
+
+func (m *MemoryStore) Ignore(mask string, until time.Time) error {
+	parsed := tightbeam.ParseMask(mask)
+	if matchesEveryone(parsed) {
+		return ErrorMaskTooBroad
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	key := s.ToLower(parsed.String())
+	m.unignore(key)
+
+	ig := &ignore{mask: parsed, until: until}
+	m.ignores[key] = ig
+
+	if host, ok := exactHost(parsed); ok {
+		if m.byHost[host] == nil {
+			m.byHost[host] = map[string]*ignore{}
+		}
+		m.byHost[host][key] = ig
+	} else {
+		m.wildcards[key] = ig
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) Unignore(mask string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.unignore(s.ToLower(tightbeam.ParseMask(mask).String()))
+}
+
+func (m *MemoryStore) unignore(key string) {
+	ig, ok := m.ignores[key]
+	if !ok {
+		return
+	}
+
+	delete(m.ignores, key)
+	delete(m.wildcards, key)
+
+	if host, ok := exactHost(ig.mask); ok {
+		delete(m.byHost[host], key)
+		if len(m.byHost[host]) == 0 {
+			delete(m.byHost, host)
+		}
+	}
+}
+
+func (m *MemoryStore) Ignores() map[string]time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ret := make(map[string]time.Time, len(m.ignores))
+	for key, ig := range m.ignores {
+		ret[key] = ig.until
+	}
+
+	return ret
+}
+
+func (m *MemoryStore) MatchIgnore(from *tightbeam.Prefix, casemap tightbeam.CaseMapping, now time.Time) (time.Time, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, candidates := range []map[string]*ignore{m.byHost[s.ToLower(from.Host)], m.wildcards} {
+		for _, ig := range candidates {
+			if now.Before(ig.until) && ig.mask.MatchCase(from, casemap) {
+				return ig.until, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func exactHost(m *tightbeam.Mask) (string, bool) {
+	if m.ExtBan != "" || s.ContainsAny(m.Host, "*?") {
+		return "", false
+	}
+
+	return s.ToLower(m.Host), true
+}
+
+func matchesEveryone(m *tightbeam.Mask) bool {
+	return m.ExtBan == "" && s.Trim(m.Name, "*") == "" && s.Trim(m.User, "*") == "" && s.Trim(m.Host, "*") == ""
+}
@@ -0,0 +1,84 @@
+package throttle_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/throttle"
+)
+
+func TestMemoryStoreIgnores(t *testing.T) {
+	st := throttle.NewMemoryStore()
+	now := time.Unix(1000, 0)
+	casemap := tightbeam.CaseMappingRFC1459
+
+	for _, mask := range []string{"*", "*!*@*", "**!*@**", "", "!@"} {
+		if err := st.Ignore(mask, now.Add(time.Hour)); err != throttle.ErrorMaskTooBroad {
+			t.Errorf("Ignore(%q) = %v, want ErrorMaskTooBroad", mask, err)
+		}
+	}
+
+	if err := st.Ignore("*!*@Bad.Example", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Ignore("spam*!*@*", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Ignore("nick!*@*.other", now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		prefix  string
+		ignored bool
+	}{
+		{"a!u@bad.example", true},
+		{"a!u@BAD.EXAMPLE", true},
+		{"a!u@sub.bad.example", false},
+		{"Spammer!u@h", true},
+		{"ham!u@h", false},
+		{"nick!u@x.other", false},
+	} {
+		_, ok := st.MatchIgnore(tightbeam.ParsePrefix(tt.prefix), casemap, now)
+		if ok != tt.ignored {
+			t.Errorf("MatchIgnore(%q) = %v, want %v", tt.prefix, ok, tt.ignored)
+		}
+	}
+
+	if err := st.Ignore("*!*@bad.example", now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if until, _ := st.MatchIgnore(tightbeam.ParsePrefix("a!u@bad.example"), casemap, now); !until.Equal(now.Add(2 * time.Hour)) {
+		t.Fatalf("MatchIgnore() after replacing = %v", until)
+	}
+
+	st.Unignore("*!*@BAD.example")
+	st.Unignore("spam*")
+	if _, ok := st.MatchIgnore(tightbeam.ParsePrefix("spam!u@bad.example"), casemap, now); ok {
+		t.Fatalf("MatchIgnore() after Unignore, ignores = %v", st.Ignores())
+	}
+
+	if ignores := st.Ignores(); len(ignores) != 1 || !ignores["nick!*@*.other"].Equal(now.Add(-time.Second)) {
+		t.Fatalf("Ignores() = %v", ignores)
+	}
+}
+
+func TestMemoryStoreStates(t *testing.T) {
+	st := throttle.NewMemoryStore()
+	st.Put("a", throttle.State{Tokens: 1})
+	st.Put("b", throttle.State{Strikes: 2})
+	st.Delete("a")
+
+	if _, ok := st.Get("a"); ok {
+		t.Fatal("Get() found a deleted key")
+	}
+
+	if got, ok := st.Get("b"); !ok || got.Strikes != 2 {
+		t.Fatalf("Get(b) = %+v, %v", got, ok)
+	}
+
+	if keys := st.Keys(); len(keys) != 1 || keys[0] != "b" {
+		t.Fatalf("Keys() = %v", keys)
+	}
+}
@@ -0,0 +1,252 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+const (
+	userKeyPrefix    = "u:"
+	accountKeyPrefix = "a:"
+	channelKeyPrefix = "c:"
+)
+
+type Limit struct {
+	Rate     int
+	Per      time.Duration
+	Cooldown time.Duration
+}
+
+func (l Limit) enabled() bool {
+	return l.Rate > 0 && l.Per > 0
+}
+
+type Config struct {
+	User    Limit
+	Channel Limit
+
+	IgnoreAfter int
+	IgnoreFor   time.Duration
+
+	Match func(m *tightbeam.Message) bool
+
+	Store Store
+	Now   func() time.Time
+}
+
+type Reason int
+
+const (
+	Allowed Reason = iota
+	Ignored
+	UserLimited
+	ChannelLimited
+)
+
+type Decision struct {
+	Allowed    bool
+	Reason     Reason
+	RetryAfter time.Duration
+}
+
+type Throttle struct {
+	config Config
+
+	lock      sync.Mutex
+	lastPrune time.Time
+}
+
+func New(config Config) *Throttle {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return &Throttle{config: config}
+}
+
+func (t *Throttle) Allow(from *tightbeam.Prefix, account string, channel string, casemap tightbeam.CaseMapping) Decision {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.config.Now()
+	t.prune(now)
+
+	if until, ok := t.config.Store.MatchIgnore(from, casemap, now); ok {
+		return Decision{Reason: Ignored, RetryAfter: until.Sub(now)}
+	}
+
+	userKey := userKeyPrefix + casemap.Fold(from.User+"@"+from.Host)
+	if account != "" {
+		userKey = accountKeyPrefix + casemap.Fold(account)
+	}
+
+	var userState, channelState State
+	channelKey := channelKeyPrefix + casemap.Fold(channel)
+
+	if t.config.User.enabled() {
+		var wait time.Duration
+		userState, wait = t.check(userKey, t.config.User, now)
+		if wait > 0 {
+			if t.config.IgnoreAfter > 0 && userState.Strikes >= t.config.IgnoreAfter {
+				t.config.Store.Ignore(tightbeam.BanMask(from, tightbeam.BanHost).String(), now.Add(t.config.IgnoreFor))
+				userState.Strikes = 0
+			}
+			t.config.Store.Put(userKey, userState)
+
+			return Decision{Reason: UserLimited, RetryAfter: wait}
+		}
+	}
+
+	if channel != "" && t.config.Channel.enabled() {
+		var wait time.Duration
+		channelState, wait = t.check(channelKey, t.config.Channel, now)
+		if wait > 0 {
+			t.config.Store.Put(channelKey, channelState)
+
+			return Decision{Reason: ChannelLimited, RetryAfter: wait}
+		}
+
+		channelState.Tokens--
+		t.config.Store.Put(channelKey, channelState)
+	}
+
+	if t.config.User.enabled() {
+		userState.Tokens--
+		t.config.Store.Put(userKey, userState)
+	}
+
+	return Decision{Allowed: true}
+}
+
+func (t *Throttle) check(key string, limit Limit, now time.Time) (State, time.Duration) {
+	st, ok := t.config.Store.Get(key)
+	if !ok {
+		st = State{Tokens: float64(limit.Rate), Updated: now}
+	}
+
+	if now.Before(st.Until) {
+		return st, st.Until.Sub(now)
+	}
+
+	if now.Sub(st.Until) >= limit.Per {
+		st.Strikes = 0
+	}
+
+	st.Tokens += float64(limit.Rate) * float64(now.Sub(st.Updated)) / float64(limit.Per)
+	if st.Tokens > float64(limit.Rate) {
+		st.Tokens = float64(limit.Rate)
+	}
+	st.Updated = now
+
+	if st.Tokens >= 1 {
+		return st, 0
+	}
+
+	st.Strikes++
+
+	wait := time.Duration((1 - st.Tokens) * float64(limit.Per) / float64(limit.Rate))
+	if limit.Cooldown > wait {
+		wait = limit.Cooldown
+	}
+	st.Until = now.Add(wait)
+
+	return st, wait
+}
+
+func (t *Throttle) Ignore(mask string, d time.Duration) error {
+	return t.config.Store.Ignore(mask, t.config.Now().Add(d))
+}
+
+func (t *Throttle) Unignore(mask string) {
+	t.config.Store.Unignore(mask)
+}
+
+func (t *Throttle) Ignored(from *tightbeam.Prefix, casemap tightbeam.CaseMapping) bool {
+	_, ok := t.config.Store.MatchIgnore(from, casemap, t.config.Now())
+	return ok
+}
+
+func (t *Throttle) Ignores() map[string]time.Time {
+	return t.config.Store.Ignores()
+}
+
+func (t *Throttle) Prune() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.lastPrune = time.Time{}
+	t.prune(t.config.Now())
+}
+
+func (t *Throttle) prune(now time.Time) {
+	interval := t.config.User.Per
+	if t.config.Channel.Per > interval {
+		interval = t.config.Channel.Per
+	}
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+
+	if now.Sub(t.lastPrune) < interval {
+		return
+	}
+	t.lastPrune = now
+
+	for mask, until := range t.config.Store.Ignores() {
+		if !now.Before(until) {
+			t.config.Store.Unignore(mask)
+		}
+	}
+
+	for _, key := range t.config.Store.Keys() {
+		st, ok := t.config.Store.Get(key)
+		if !ok || now.Before(st.Until) {
+			continue
+		}
+
+		if now.Sub(st.Updated) >= interval {
+			t.config.Store.Delete(key)
+		}
+	}
+}
+
+func (t *Throttle) Handler(next tightbeam.Handler) tightbeam.Handler {
+	return tightbeam.HandlerFunc(func(c *tightbeam.Client, m *tightbeam.Message) {
+		switch m.Command {
+		case "PRIVMSG", "NOTICE", "TAGMSG":
+		default:
+			next.Handle(c, m)
+			return
+		}
+
+		if m.Prefix == nil || m.Prefix.Name == "" || m.Prefix.User == "" || m.Prefix.Host == "" || len(m.Params) == 0 || t.config.Match != nil && !t.config.Match(m) {
+			next.Handle(c, m)
+			return
+		}
+
+		isupport := c.ISupport()
+		casemap := isupport.CaseMapping()
+
+		if casemap.Equal(m.Prefix.Name, c.CurrentNick()) {
+			next.Handle(c, m)
+			return
+		}
+
+		channel := ""
+		if isupport.IsChannel(m.Params[0]) {
+			channel = m.Params[0]
+		}
+
+		account, _ := m.Tags.GetTag("account")
+
+		if t.Allow(m.Prefix, account, channel, casemap).Allowed {
+			next.Handle(c, m)
+		}
+	})
+}
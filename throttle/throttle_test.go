@@ -0,0 +1,260 @@
+package throttle_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/throttle"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+var casemap = tightbeam.CaseMappingRFC1459
+
+func TestTokenBucket(t *testing.T) {
+	clk := &clock{now: time.Unix(1000, 0)}
+	th := throttle.New(throttle.Config{
+		User: throttle.Limit{Rate: 2, Per: 10 * time.Second},
+		Now:  clk.Now,
+	})
+
+	alice := tightbeam.ParsePrefix("alice!a@alice.example")
+
+	for n := 0; n < 2; n++ {
+		if d := th.Allow(alice, "", "", casemap); !d.Allowed {
+			t.Fatalf("Allow() #%d = %+v", n, d)
+		}
+	}
+
+	d := th.Allow(alice, "", "", casemap)
+	if d.Allowed || d.Reason != throttle.UserLimited || d.RetryAfter != 5*time.Second {
+		t.Fatalf("Allow() over the limit = %+v", d)
+	}
+
+	clk.Advance(2 * time.Second)
+	if d := th.Allow(alice, "", "", casemap); d.Allowed || d.RetryAfter != 3*time.Second {
+		t.Fatalf("Allow() while limited = %+v", d)
+	}
+
+	clk.Advance(3 * time.Second)
+	if d := th.Allow(alice, "", "", casemap); !d.Allowed {
+		t.Fatalf("Allow() after refill = %+v", d)
+	}
+
+	if d := th.Allow(tightbeam.ParsePrefix("other!a@alice.example"), "", "", casemap); d.Allowed {
+		t.Fatalf("Allow() from the same user@host = %+v", d)
+	}
+
+	if d := th.Allow(tightbeam.ParsePrefix("bob!b@bob.example"), "", "", casemap); !d.Allowed {
+		t.Fatalf("Allow() from another user = %+v", d)
+	}
+
+	for n := 0; n < 2; n++ {
+		th.Allow(tightbeam.ParsePrefix("n1!u@h1"), "Acct", "", casemap)
+	}
+	if d := th.Allow(tightbeam.ParsePrefix("n2!u@h2"), "acct", "", casemap); d.Allowed {
+		t.Fatalf("Allow() from the same account = %+v", d)
+	}
+}
+
+func TestChannelLimit(t *testing.T) {
+	clk := &clock{now: time.Unix(1000, 0)}
+	th := throttle.New(throttle.Config{
+		User:    throttle.Limit{Rate: 5, Per: time.Minute},
+		Channel: throttle.Limit{Rate: 2, Per: time.Minute},
+		Now:     clk.Now,
+	})
+
+	th.Allow(tightbeam.ParsePrefix("a!a@a"), "", "#Chan", casemap)
+	th.Allow(tightbeam.ParsePrefix("b!b@b"), "", "#chan", casemap)
+
+	d := th.Allow(tightbeam.ParsePrefix("c!c@c"), "", "#CHAN", casemap)
+	if d.Allowed || d.Reason != throttle.ChannelLimited || d.RetryAfter != 30*time.Second {
+		t.Fatalf("Allow() over the channel limit = %+v", d)
+	}
+
+	if d := th.Allow(tightbeam.ParsePrefix("c!c@c"), "", "#other", casemap); !d.Allowed {
+		t.Fatalf("Allow() in another channel = %+v", d)
+	}
+
+	if d := th.Allow(tightbeam.ParsePrefix("c!c@c"), "", "", casemap); !d.Allowed {
+		t.Fatalf("Allow() in private = %+v", d)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	clk := &clock{now: time.Unix(1000, 0)}
+	th := throttle.New(throttle.Config{
+		User: throttle.Limit{Rate: 1, Per: 10 * time.Second, Cooldown: time.Minute},
+		Now:  clk.Now,
+	})
+
+	alice := tightbeam.ParsePrefix("alice!a@alice.example")
+	th.Allow(alice, "", "", casemap)
+
+	if d := th.Allow(alice, "", "", casemap); d.Allowed || d.RetryAfter != time.Minute {
+		t.Fatalf("Allow() over the limit = %+v", d)
+	}
+
+	clk.Advance(20 * time.Second)
+	if d := th.Allow(alice, "", "", casemap); d.Allowed || d.RetryAfter != 40*time.Second {
+		t.Fatalf("Allow() during cooldown = %+v", d)
+	}
+
+	clk.Advance(40 * time.Second)
+	if d := th.Allow(alice, "", "", casemap); !d.Allowed {
+		t.Fatalf("Allow() after cooldown = %+v", d)
+	}
+}
+
+func TestStrikes(t *testing.T) {
+	clk := &clock{now: time.Unix(1000, 0)}
+	store := throttle.NewMemoryStore()
+	config := throttle.Config{
+		User:        throttle.Limit{Rate: 1, Per: 10 * time.Second},
+		IgnoreAfter: 2,
+		IgnoreFor:   time.Hour,
+		Store:       store,
+		Now:         clk.Now,
+	}
+	th := throttle.New(config)
+
+	alice := tightbeam.ParsePrefix("alice!a@alice.example")
+
+	for n := 0; n < 3; n++ {
+		th.Allow(alice, "", "", casemap)
+		th.Allow(alice, "", "", casemap)
+		clk.Advance(30 * time.Second)
+	}
+
+	if th.Ignored(alice, casemap) {
+		t.Fatal("strikes spread over time led to an ignore")
+	}
+
+	th.Allow(alice, "", "", casemap)
+	th.Allow(alice, "", "", casemap)
+	clk.Advance(10 * time.Second)
+	th.Allow(alice, "", "", casemap)
+	th.Allow(alice, "", "", casemap)
+
+	if !th.Ignored(tightbeam.ParsePrefix("other!x@alice.example"), casemap) {
+		t.Fatalf("Ignored() after repeated strikes, ignores = %v", th.Ignores())
+	}
+
+	if _, ok := th.Ignores()["*!*@alice.example"]; !ok {
+		t.Fatalf("Ignores() = %v", th.Ignores())
+	}
+
+	d := throttle.New(config).Allow(alice, "", "", casemap)
+	if d.Allowed || d.Reason != throttle.Ignored || d.RetryAfter != time.Hour {
+		t.Fatalf("Allow() from a new Throttle on the same store = %+v", d)
+	}
+
+	clk.Advance(time.Hour)
+	th.Prune()
+
+	if th.Ignored(alice, casemap) || len(th.Ignores()) != 0 {
+		t.Fatalf("ignore not expired, ignores = %v", th.Ignores())
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	clk := &clock{now: time.Unix(1000, 0)}
+	th := throttle.New(throttle.Config{Now: clk.Now})
+
+	for _, mask := range []string{"*", "*!*@*", "*!*"} {
+		if err := th.Ignore(mask, time.Hour); err != throttle.ErrorMaskTooBroad {
+			t.Errorf("Ignore(%q) = %v, want ErrorMaskTooBroad", mask, err)
+		}
+	}
+
+	if err := th.Ignore("*!*@*.bad", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	spammer := tightbeam.ParsePrefix("q!w@x.bad")
+	if d := th.Allow(spammer, "", "", casemap); d.Reason != throttle.Ignored || d.RetryAfter != time.Minute {
+		t.Fatalf("Allow() from an ignored mask = %+v", d)
+	}
+
+	th.Unignore("*!*@*.bad")
+	if d := th.Allow(spammer, "", "", casemap); !d.Allowed {
+		t.Fatalf("Allow() after Unignore = %+v", d)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := tightbeamtest.NewServer(t)
+	clk := &clock{now: time.Unix(1000, 0)}
+	th := throttle.New(throttle.Config{
+		User:        throttle.Limit{Rate: 1, Per: time.Hour},
+		IgnoreAfter: 1,
+		IgnoreFor:   time.Hour,
+		Now:         clk.Now,
+	})
+
+	received := make(chan string, 16)
+	next := tightbeam.HandlerFunc(func(c *tightbeam.Client, m *tightbeam.Message) {
+		switch m.Command {
+		case "PRIVMSG", "NOTICE":
+			received <- m.Trailing()
+		}
+	})
+
+	c := tightbeam.NewClient(srv.Conn(), tightbeam.ClientConfig{Nick: "bot", Handler: th.Handler(next)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	srv.Register("bot")
+
+	for _, line := range []string{
+		":alice!a@alice.example PRIVMSG #c one",
+		":alice!a@alice.example PRIVMSG #c two",
+		":irc.example NOTICE * server1",
+		":irc.example NOTICE * server2",
+		":irc.example NOTICE * server3",
+		":bot!b@bot.example PRIVMSG #c self1",
+		":bot!b@bot.example PRIVMSG #c self2",
+		"NOTICE * noprefix",
+	} {
+		srv.Send(line)
+	}
+
+	srv.Send("PING :sync")
+	srv.Expect("PONG sync")
+	close(received)
+
+	var got []string
+	for text := range received {
+		got = append(got, text)
+	}
+
+	want := []string{"one", "server1", "server2", "server3", "self1", "self2", "noprefix"}
+	if len(got) != len(want) {
+		t.Fatalf("handled %q, want %q", got, want)
+	}
+	for n := range want {
+		if got[n] != want[n] {
+			t.Fatalf("handled %q, want %q", got, want)
+		}
+	}
+
+	if ignores := th.Ignores(); len(ignores) != 1 {
+		t.Fatalf("Ignores() = %v", ignores)
+	}
+}
@@ -0,0 +1,164 @@
+package tightbeamtest
+
+import (
+	"fmt"
+	"regexp"
+	s "strings"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+type Pattern struct {
+	source string
+	tags   map[string]*matcher
+	prefix *matcher
+	cmd    string
+	params []*matcher
+	rest   bool
+}
+
+type matcher struct {
+	source string
+	re     *regexp.Regexp
+}
+
+func MustPattern(pattern string) *Pattern {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		panic(err.Error())
+	}
+	return p
+}
+
+func ParsePattern(pattern string) (*Pattern, error) {
+	m, err := tightbeam.ParseMessage(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pattern{
+		source: pattern,
+		cmd:    m.Command,
+	}
+
+	params := m.Params
+	if len(params) > 0 && params[len(params)-1] == "**" {
+		params, p.rest = params[:len(params)-1], true
+	}
+
+	for _, param := range params {
+		pm, err := newMatcher(param)
+		if err != nil {
+			return nil, err
+		}
+		p.params = append(p.params, pm)
+	}
+
+	if m.Prefix != nil && m.Prefix.Name != "" {
+		if p.prefix, err = newMatcher(m.Prefix.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(m.Tags) > 0 {
+		p.tags = map[string]*matcher{}
+		for k, v := range m.Tags {
+			if v == "" {
+				p.tags[k] = nil
+				continue
+			}
+
+			if p.tags[k], err = newMatcher(string(v)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Pattern) String() string {
+	return p.source
+}
+
+func (p *Pattern) Match(m *tightbeam.Message) bool {
+	return p.Diff(m) == ""
+}
+
+func (p *Pattern) Diff(m *tightbeam.Message) string {
+	var diffs []string
+
+	if p.cmd != "*" && !s.EqualFold(p.cmd, m.Command) {
+		diffs = append(diffs, fmt.Sprintf("command: want %q, got %q", p.cmd, m.Command))
+	}
+
+	if p.prefix != nil {
+		got := ""
+		if m.Prefix != nil && m.Prefix.Name != "" {
+			got = m.Prefix.String()
+		}
+
+		if !p.prefix.match(got) {
+			diffs = append(diffs, fmt.Sprintf("prefix: want %q, got %q", p.prefix.source, got))
+		}
+	}
+
+	for _, k := range sortedKeys(p.tags) {
+		got, ok := m.Tags.GetTag(k)
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("tag %s: missing", k))
+			continue
+		}
+
+		if want := p.tags[k]; want != nil && !want.match(got) {
+			diffs = append(diffs, fmt.Sprintf("tag %s: want %q, got %q", k, want.source, got))
+		}
+	}
+
+	if len(m.Params) < len(p.params) || (!p.rest && len(m.Params) != len(p.params)) {
+		diffs = append(diffs, fmt.Sprintf("params: want %d, got %d", len(p.params), len(m.Params)))
+	}
+
+	for n, want := range p.params {
+		if n >= len(m.Params) {
+			break
+		}
+
+		if !want.match(m.Params[n]) {
+			diffs = append(diffs, fmt.Sprintf("param %d: want %q, got %q", n, want.source, m.Params[n]))
+		}
+	}
+
+	return s.Join(diffs, "\n")
+}
+
+func newMatcher(pattern string) (*matcher, error) {
+	ret := &matcher{source: pattern}
+
+	if len(pattern) > 1 && pattern[0] == '/' && pattern[len(pattern)-1] == '/' {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, err
+		}
+		ret.re = re
+	}
+
+	return ret, nil
+}
+
+func (m *matcher) match(v string) bool {
+	if m.re != nil {
+		return m.re.MatchString(v)
+	}
+
+	return tightbeam.MatchWildcardExact(m.source, v)
+}
+
+func sortedKeys(m map[string]*matcher) []string {
+	t := tightbeam.Tags{}
+	for k := range m {
+		t[k] = ""
+	}
+
+	return t.Keys()
+}
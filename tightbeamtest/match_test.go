@@ -0,0 +1,82 @@
+package tightbeamtest_test
+
+import (
+	"testing"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+func TestParsePatternErrors(t *testing.T) {
+	for _, pattern := range []string{
+		"",
+		"PRIVMSG /[/",
+		":/(/ PRIVMSG #c hi",
+		"@msgid=/a**/ PRIVMSG #c hi",
+	} {
+		if _, err := tightbeamtest.ParsePattern(pattern); err == nil {
+			t.Errorf("ParsePattern(%q) succeeded", pattern)
+		}
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		line    string
+		match   bool
+	}{
+		{"PRIVMSG #c hi", "PRIVMSG #c :hi", true},
+		{"privmsg #c hi", "PRIVMSG #c :hi", true},
+		{"PRIVMSG #c hi", "PRIVMSG #c hi there", false},
+		{"* #c hi", "NOTICE #c hi", true},
+		{"PRIVMSG * *", "PRIVMSG #c :hi there", true},
+		{"PRIVMSG #c", "PRIVMSG #c :hi", false},
+		{"PRIVMSG **", "PRIVMSG #c :hi", true},
+		{"PRIVMSG #c **", "PRIVMSG #c", true},
+		{"PRIVMSG #c ? **", "PRIVMSG #c", false},
+		{"PRIVMSG #c h?", "PRIVMSG #c hi", true},
+		{"PRIVMSG #c Hi", "PRIVMSG #c hi", false},
+		{"PRIVMSG #C *", "PRIVMSG #c hi", false},
+		{"PRIVMSG #c H*", "PRIVMSG #c hi", false},
+		{"PRIVMSG #c /^h[a-z]+$/", "PRIVMSG #c hello", true},
+		{"PRIVMSG #c /^h[a-z]+$/", "PRIVMSG #c :hello there", false},
+		{":alice!*@* PRIVMSG #c hi", ":alice!a@host PRIVMSG #c hi", true},
+		{":alice!*@* PRIVMSG #c hi", ":bob!a@host PRIVMSG #c hi", false},
+		{":alice!*@* PRIVMSG #c hi", "PRIVMSG #c hi", false},
+		{":Alice!*@* PRIVMSG #c hi", ":alice!a@host PRIVMSG #c hi", false},
+		{":/^al/ PRIVMSG #c hi", ":alice!a@host PRIVMSG #c hi", true},
+		{"@msgid PRIVMSG #c hi", "@msgid=abc PRIVMSG #c hi", true},
+		{"@msgid PRIVMSG #c hi", "PRIVMSG #c hi", false},
+		{"@msgid=a* PRIVMSG #c hi", "@msgid=abc PRIVMSG #c hi", true},
+		{"@msgid=A* PRIVMSG #c hi", "@msgid=abc PRIVMSG #c hi", false},
+		{"@time=/^2024-/ PRIVMSG #c hi", "@time=2024-01-01T00:00:00Z PRIVMSG #c hi", true},
+		{"@time=/^2024-/ PRIVMSG #c hi", "@time=2025-01-01T00:00:00Z PRIVMSG #c hi", false},
+	} {
+		p, err := tightbeamtest.ParsePattern(tt.pattern)
+		if err != nil {
+			t.Errorf("ParsePattern(%q): %v", tt.pattern, err)
+			continue
+		}
+
+		m := tightbeam.MustParseMessage(tt.line)
+		if match := p.Match(m); match != tt.match {
+			t.Errorf("%q.Match(%q) = %v, want %v\n%s", tt.pattern, tt.line, match, tt.match, p.Diff(m))
+		}
+	}
+}
+
+func TestPatternDiff(t *testing.T) {
+	p := tightbeamtest.MustPattern("@msgid=/^x/ :alice!*@* PRIVMSG #c /^hi/")
+	diff := p.Diff(tightbeam.MustParseMessage("@msgid=y :bob!b@h NOTICE #c bye extra"))
+
+	want := `command: want "PRIVMSG", got "NOTICE"
+prefix: want "alice!*@*", got "bob!b@h"
+tag msgid: want "/^x/", got "y"
+params: want 2, got 3
+param 1: want "/^hi/", got "bye"`
+
+	if diff != want {
+		t.Fatalf("Diff() =\n%s\nwant\n%s", diff, want)
+	}
+}
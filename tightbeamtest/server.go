@@ -0,0 +1,229 @@
+package tightbeamtest
+
+import (
+	"fmt"
+	"net"
+	s "strings"
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+const DefaultTimeout = 2 * time.Second
+
+type Server struct {
+	Name    string
+	Timeout time.Duration
+
+	t      testing.TB
+	conn   net.Conn
+	client net.Conn
+	reader *tightbeam.Reader
+	writer *tightbeam.Writer
+}
+
+func NewServer(t testing.TB) *Server {
+	server, client := net.Pipe()
+
+	srv := newServer(t, server)
+	srv.client = client
+	t.Cleanup(func() { client.Close() })
+
+	return srv
+}
+
+func newServer(t testing.TB, conn net.Conn) *Server {
+	srv := &Server{
+		Name:    "irc.test",
+		Timeout: DefaultTimeout,
+		t:       t,
+		conn:    conn,
+		reader:  tightbeam.NewReader(conn),
+		writer:  tightbeam.NewWriter(conn),
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return srv
+}
+
+func (srv *Server) Conn() net.Conn {
+	return srv.client
+}
+
+func (srv *Server) Close() error {
+	return srv.conn.Close()
+}
+
+func (srv *Server) Next() *tightbeam.Message {
+	srv.t.Helper()
+
+	srv.conn.SetReadDeadline(time.Now().Add(srv.Timeout))
+	defer srv.conn.SetReadDeadline(time.Time{})
+
+	line, err := srv.reader.ReadLine()
+	if err != nil {
+		srv.t.Fatalf("tightbeamtest: reading from client: %v", err)
+		return nil
+	}
+
+	m, err := tightbeam.ParseMessage(line)
+	if err != nil {
+		srv.t.Fatalf("tightbeamtest: client sent unparseable line %q: %v", line, err)
+		return nil
+	}
+
+	return m
+}
+
+func (srv *Server) Expect(pattern string) *tightbeam.Message {
+	srv.t.Helper()
+
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		srv.t.Fatalf("tightbeamtest: bad pattern %q: %v", pattern, err)
+		return nil
+	}
+
+	return srv.ExpectPattern(p)
+}
+
+func (srv *Server) ExpectPattern(p *Pattern) *tightbeam.Message {
+	srv.t.Helper()
+
+	m := srv.Next()
+	if diff := p.Diff(m); diff != "" {
+		srv.t.Fatalf("tightbeamtest: unexpected message\n  want: %s\n   got: %s\n%s", p, m, indent(diff))
+	}
+
+	return m
+}
+
+func (srv *Server) ExpectMessage(want *tightbeam.Message) *tightbeam.Message {
+	srv.t.Helper()
+
+	m := srv.Next()
+	if !want.Equal(m) {
+		srv.t.Fatalf("tightbeamtest: unexpected message\n  want: %s\n   got: %s", want.Canonical(), m.Canonical())
+	}
+
+	return m
+}
+
+func (srv *Server) ExpectClosed() {
+	srv.t.Helper()
+
+	srv.conn.SetReadDeadline(time.Now().Add(srv.Timeout))
+	defer srv.conn.SetReadDeadline(time.Time{})
+
+	line, err := srv.reader.ReadLine()
+	if err == nil {
+		srv.t.Fatalf("tightbeamtest: expected connection to close, got %q", line)
+	}
+}
+
+func (srv *Server) Send(line string) *Server {
+	srv.t.Helper()
+
+	if _, err := tightbeam.ParseMessage(line); err != nil {
+		srv.t.Fatalf("tightbeamtest: bad line %q: %v", line, err)
+		return srv
+	}
+
+	srv.conn.SetWriteDeadline(time.Now().Add(srv.Timeout))
+	defer srv.conn.SetWriteDeadline(time.Time{})
+
+	if err := srv.writer.WriteLine(line); err != nil {
+		srv.t.Fatalf("tightbeamtest: writing to client: %v", err)
+	}
+
+	return srv
+}
+
+func (srv *Server) Sendf(format string, args ...interface{}) *Server {
+	srv.t.Helper()
+
+	return srv.Send(fmt.Sprintf(format, args...))
+}
+
+func (srv *Server) SendMessage(m *tightbeam.Message) *Server {
+	srv.t.Helper()
+
+	return srv.Send(m.String())
+}
+
+func (srv *Server) Numeric(numeric, nick string, params ...string) *Server {
+	srv.t.Helper()
+
+	return srv.SendMessage(&tightbeam.Message{
+		Prefix:  &tightbeam.Prefix{Name: srv.Name},
+		Command: numeric,
+		Params:  append([]string{nick}, params...),
+	})
+}
+
+func (srv *Server) Register(nick string) *Server {
+	srv.t.Helper()
+
+	srv.Expect("NICK " + nick)
+	srv.Expect("USER * * * *")
+
+	srv.Numeric(tightbeam.RPL_WELCOME, nick, "Welcome to the test network "+nick)
+	srv.Numeric(tightbeam.RPL_ISUPPORT, nick, "CASEMAPPING=rfc1459", "are supported by this server")
+	srv.Numeric(tightbeam.ERR_NOMOTD, nick, "MOTD File is missing")
+
+	return srv
+}
+
+type Listener struct {
+	t testing.TB
+	l net.Listener
+}
+
+func Listen(t testing.TB) *Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("tightbeamtest: listen: %v", err)
+		return nil
+	}
+
+	t.Cleanup(func() { l.Close() })
+
+	return &Listener{t: t, l: l}
+}
+
+func (l *Listener) Addr() string {
+	return l.l.Addr().String()
+}
+
+func (l *Listener) Accept() *Server {
+	l.t.Helper()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		conn, err := l.l.Accept()
+		ch <- result{conn, err}
+	}()
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			l.t.Fatalf("tightbeamtest: accept: %v", r.err)
+			return nil
+		}
+		return newServer(l.t, r.conn)
+	case <-time.After(DefaultTimeout):
+		l.t.Fatalf("tightbeamtest: timed out waiting for client to connect")
+		return nil
+	}
+}
+
+func indent(v string) string {
+	return "    " + s.ReplaceAll(v, "\n", "\n    ")
+}
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type Dialer struct {
+	NetDial   func(ctx context.Context, network, addr string) (net.Conn, error)
+	TLSConfig *tls.Config
+	Header    http.Header
+	Protocols []string
+}
+
+var DefaultDialer = &Dialer{}
+
+func Dial(ctx context.Context, rawurl string) (*Conn, error) {
+	return DefaultDialer.Dial(ctx, rawurl)
+}
+
+func (d *Dialer) Dial(ctx context.Context, rawurl string) (*Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	secure := false
+	switch u.Scheme {
+	case "ws", "http":
+	case "wss", "https":
+		secure = true
+	default:
+		return nil, ErrorBadHandshake
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		if secure {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	netDial := d.NetDial
+	if netDial == nil {
+		netDial = (&net.Dialer{}).DialContext
+	}
+
+	conn, err := netDial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if secure {
+		config := d.TLSConfig.Clone()
+		if config == nil {
+			config = &tls.Config{}
+		}
+
+		if config.ServerName == "" {
+			config.ServerName = u.Hostname()
+		}
+
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		conn = tlsConn
+	}
+
+	ws, err := d.handshake(ctx, conn, u)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ws, nil
+}
+
+func (d *Dialer) handshake(ctx context.Context, conn net.Conn, u *url.URL) (*Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	protocols := d.Protocols
+	if len(protocols) == 0 {
+		protocols = []string{ProtocolBinary, ProtocolText}
+	}
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Host:       u.Host,
+	}
+
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+
+	for k, v := range d.Header {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	for _, p := range protocols {
+		req.Header.Add("Sec-WebSocket-Protocol", p)
+	}
+
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!headerContains(resp.Header["Upgrade"], "websocket") ||
+		!headerContains(resp.Header["Connection"], "upgrade") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, ErrorBadHandshake
+	}
+
+	protocol := resp.Header.Get("Sec-WebSocket-Protocol")
+	if protocol != "" && !headerContains(protocols, protocol) {
+		return nil, ErrorBadHandshake
+	}
+
+	if protocol == "" {
+		protocol = ProtocolBinary
+	}
+
+	return newConn(conn, r, true, protocol), nil
+}
@@ -0,0 +1,264 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	s "strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	ProtocolText   = "text.ircv3.net"
+	ProtocolBinary = "binary.ircv3.net"
+
+	MaxMessageSize = 16384
+
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+var (
+	ErrorBadHandshake = errors.New("websocket: Bad handshake")
+
+	ErrorProtocol = errors.New("websocket: Protocol error")
+
+	ErrorMessageTooBig = errors.New("websocket: Message too big")
+)
+
+type Conn struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	client   bool
+	protocol string
+
+	writeLock sync.Mutex
+	closeOnce sync.Once
+}
+
+func newConn(conn net.Conn, r *bufio.Reader, client bool, protocol string) *Conn {
+	return &Conn{
+		conn:     conn,
+		r:        r,
+		client:   client,
+		protocol: protocol,
+	}
+}
+
+func (c *Conn) Protocol() string {
+	return c.protocol
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) ReadLine() (string, error) {
+	var msg []byte
+	started := false
+
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return "", err
+		}
+
+		switch op {
+		case opPing:
+			c.writeFrame(opPong, payload)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.close(payload)
+			return "", io.EOF
+		case opText, opBinary:
+			if started {
+				return "", ErrorProtocol
+			}
+			started = true
+		case opContinuation:
+			if !started {
+				return "", ErrorProtocol
+			}
+		default:
+			return "", ErrorProtocol
+		}
+
+		msg = append(msg, payload...)
+		if len(msg) > MaxMessageSize {
+			return "", ErrorMessageTooBig
+		}
+
+		if !fin {
+			continue
+		}
+
+		line := s.TrimRight(string(msg), "\r\n")
+		if line == "" {
+			msg, started = nil, false
+			continue
+		}
+
+		return line, nil
+	}
+}
+
+func (c *Conn) WriteLine(line string) error {
+	if c.protocol == ProtocolText {
+		return c.writeFrame(opText, []byte(s.ToValidUTF8(line, string(utf8.RuneError))))
+	}
+
+	return c.writeFrame(opBinary, []byte(line))
+}
+
+func (c *Conn) Close() error {
+	return c.close([]byte{0x03, 0xe8})
+}
+
+func (c *Conn) close(payload []byte) error {
+	var err error
+
+	c.closeOnce.Do(func() {
+		c.writeFrame(opClose, payload)
+		err = c.conn.Close()
+	})
+
+	return err
+}
+
+func (c *Conn) readFrame() (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := head[0]&0x80 != 0
+	op := head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+
+	if head[0]&0x70 != 0 || masked == c.client {
+		return false, 0, nil, ErrorProtocol
+	}
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if op >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, ErrorProtocol
+	}
+
+	if length > MaxMessageSize {
+		return false, 0, nil, ErrorMessageTooBig
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		for n := range payload {
+			payload[n] ^= mask[n%4]
+		}
+	}
+
+	return fin, op, payload, nil
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	buf := make([]byte, 0, len(payload)+14)
+	buf = append(buf, 0x80|op)
+
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+
+	switch {
+	case len(payload) < 126:
+		buf = append(buf, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		buf = append(buf, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(buf[len(buf)-2:], uint16(len(payload)))
+	default:
+		buf = append(buf, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(buf[len(buf)-8:], uint64(len(payload)))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+
+		buf = append(buf, mask[:]...)
+		for n, b := range payload {
+			buf = append(buf, b^mask[n%4])
+		}
+	} else {
+		buf = append(buf, payload...)
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(values []string, token string) bool {
+	for _, v := range values {
+		for _, part := range s.Split(v, ",") {
+			if s.EqualFold(s.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
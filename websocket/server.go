@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+type Handler struct {
+	Serve       func(t tightbeam.Transport)
+	CheckOrigin func(r *http.Request) bool
+}
+
+func NewHandler(serve func(t tightbeam.Transport)) *Handler {
+	return &Handler{Serve: serve}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header["Upgrade"], "websocket") ||
+		!headerContains(r.Header["Connection"], "upgrade") {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing websocket key", http.StatusBadRequest)
+		return
+	}
+
+	checkOrigin := h.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+
+	if !checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	protocol := ""
+	switch {
+	case headerContains(r.Header["Sec-Websocket-Protocol"], ProtocolBinary):
+		protocol = ProtocolBinary
+	case headerContains(r.Header["Sec-Websocket-Protocol"], ProtocolText):
+		protocol = ProtocolText
+	case len(r.Header["Sec-Websocket-Protocol"]) > 0:
+		http.Error(w, "unsupported websocket subprotocol", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if protocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + protocol + "\r\n"
+	}
+	resp += "\r\n"
+
+	if _, err := rw.WriteString(resp); err != nil {
+		conn.Close()
+		return
+	}
+
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	if protocol == "" {
+		protocol = ProtocolBinary
+	}
+
+	h.Serve(newConn(conn, rw.Reader, false, protocol))
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return u.Host == r.Host
+}
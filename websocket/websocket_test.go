@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	s "strings"
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/server"
+)
+
+func dialTest(t *testing.T, url string, protocols ...string) *Conn {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	d := &Dialer{Protocols: protocols}
+
+	ws, err := d.Dial(ctx, "ws"+s.TrimPrefix(url, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	ws.conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	return ws
+}
+
+func TestLoopback(t *testing.T) {
+	lines := make(chan string, 1)
+	closed := make(chan struct{}, 1)
+
+	hs := httptest.NewServer(NewHandler(func(tr tightbeam.Transport) {
+		defer tr.Close()
+
+		for {
+			line, err := tr.ReadLine()
+			if err != nil {
+				closed <- struct{}{}
+				return
+			}
+
+			lines <- line
+			tr.WriteLine("echo " + line)
+		}
+	}))
+	defer hs.Close()
+
+	for _, protocol := range []string{ProtocolBinary, ProtocolText} {
+		ws := dialTest(t, hs.URL, protocol)
+		if ws.Protocol() != protocol {
+			t.Fatalf("Protocol() = %q, want %q", ws.Protocol(), protocol)
+		}
+
+		long := s.Repeat("x", 1000)
+		if err := ws.WriteLine("PRIVMSG #chan :" + long); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := <-lines; got != "PRIVMSG #chan :"+long {
+			t.Fatalf("server got %q", got)
+		}
+
+		got, err := ws.ReadLine()
+		if err != nil || got != "echo PRIVMSG #chan :"+long {
+			t.Fatalf("client got %q, %v", got, err)
+		}
+
+		ws.Close()
+
+		select {
+		case <-closed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("server did not see the connection close")
+		}
+	}
+}
+
+func TestServeTransport(t *testing.T) {
+	srv := server.New(server.Config{MOTD: []string{"hello"}})
+	defer srv.Close()
+
+	hs := httptest.NewServer(NewHandler(srv.ServeTransport))
+	defer hs.Close()
+
+	ws := dialTest(t, hs.URL)
+
+	for _, line := range []string{"NICK alice", "USER alice 0 * :Alice", "JOIN #ws"} {
+		if err := ws.WriteLine(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []string{"001", "002", "003", "004", "005", "375", "372", "376", "JOIN", "353", "366"} {
+		line, err := ws.ReadLine()
+		for err == nil && want != "005" && s.Contains(line, " 005 ") {
+			line, err = ws.ReadLine()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := tightbeam.ParseMessage(line)
+		if err != nil || m.Command != want {
+			t.Fatalf("got %q, want %s", line, want)
+		}
+	}
+}
+
+func TestCloseFrame(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	srv := newConn(a, bufio.NewReader(a), false, ProtocolBinary)
+	client := newConn(b, bufio.NewReader(b), true, ProtocolBinary)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := srv.ReadLine()
+		done <- err
+	}()
+
+	if err := client.writeFrame(opClose, []byte{0x03, 0xe8}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, op, payload, err := client.readFrame()
+	if err != nil || op != opClose || string(payload) != "\x03\xe8" {
+		t.Fatalf("got op %#x payload %q, %v", op, payload, err)
+	}
+
+	if _, op, _, err := client.readFrame(); err == nil {
+		t.Fatalf("got a second frame with op %#x after close", op)
+	}
+
+	if err := <-done; err != io.EOF {
+		t.Fatalf("ReadLine() = %v, want EOF", err)
+	}
+}
@@ -0,0 +1,214 @@
+package tightbeam
+
+import (
+	"errors"
+	"strconv"
+	s "strings"
+	"time"
+)
+
+var ErrorInvalidWhoxToken = errors.New("irc: WHOX token must be 1 to 3 digits")
+
+type WhoxField byte
+
+const (
+	WhoxToken    WhoxField = 't'
+	WhoxChannel  WhoxField = 'c'
+	WhoxUser     WhoxField = 'u'
+	WhoxIP       WhoxField = 'i'
+	WhoxHost     WhoxField = 'h'
+	WhoxServer   WhoxField = 's'
+	WhoxNick     WhoxField = 'n'
+	WhoxFlags    WhoxField = 'f'
+	WhoxHops     WhoxField = 'd'
+	WhoxIdle     WhoxField = 'l'
+	WhoxAccount  WhoxField = 'a'
+	WhoxOpLevel  WhoxField = 'o'
+	WhoxRealname WhoxField = 'r'
+)
+
+const whoxFieldOrder = "tcuihsnfdlaor"
+
+type WhoEntry struct {
+	Channel  string
+	User     string
+	IP       string
+	Host     string
+	Server   string
+	Nick     string
+	Flags    string
+	Hops     int
+	Idle     time.Duration
+	Account  string
+	OpLevel  string
+	Realname string
+
+	Away bool
+	Oper bool
+}
+
+type WhoQuery struct {
+	Mask   string
+	Token  string
+	Fields string
+	Whox   bool
+
+	CaseMapping CaseMapping
+}
+
+func NewWhoQuery(isupport ISupport, mask, token string, fields ...WhoxField) (*WhoQuery, error) {
+	if len(token) < 1 || len(token) > 3 {
+		return nil, ErrorInvalidWhoxToken
+	}
+
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return nil, ErrorInvalidWhoxToken
+		}
+	}
+
+	want := map[WhoxField]bool{
+		WhoxToken:   true,
+		WhoxChannel: true,
+		WhoxUser:    true,
+		WhoxHost:    true,
+		WhoxServer:  true,
+		WhoxNick:    true,
+		WhoxFlags:   true,
+	}
+
+	for _, f := range fields {
+		want[f] = true
+	}
+
+	buf := &s.Builder{}
+	for n := 0; n < len(whoxFieldOrder); n++ {
+		if want[WhoxField(whoxFieldOrder[n])] {
+			buf.WriteByte(whoxFieldOrder[n])
+		}
+	}
+
+	return &WhoQuery{
+		Mask:   mask,
+		Token:  token,
+		Fields: buf.String(),
+		Whox:   isupport.Has("WHOX"),
+
+		CaseMapping: isupport.CaseMapping(),
+	}, nil
+}
+
+func (q *WhoQuery) Message() *Message {
+	if !q.Whox {
+		return &Message{Command: "WHO", Params: []string{q.Mask}}
+	}
+
+	return &Message{
+		Command: "WHO",
+		Params:  []string{q.Mask, "%" + q.Fields + "," + q.Token},
+	}
+}
+
+func (q *WhoQuery) Decode(m *Message) (*WhoEntry, bool) {
+	switch m.Command {
+	case RPL_WHOREPLY:
+		return decodeWhoReply(m)
+	case RPL_WHOSPCRPL:
+		if !q.Whox {
+			return nil, false
+		}
+
+		return q.decodeWhox(m)
+	}
+
+	return nil, false
+}
+
+func (q *WhoQuery) Done(m *Message) bool {
+	return m.Command == RPL_ENDOFWHO && len(m.Params) > 1 && q.CaseMapping.Equal(m.Params[1], q.Mask)
+}
+
+func decodeWhoReply(m *Message) (*WhoEntry, bool) {
+	if len(m.Params) < 8 {
+		return nil, false
+	}
+
+	e := &WhoEntry{
+		Channel: m.Params[1],
+		User:    m.Params[2],
+		Host:    m.Params[3],
+		Server:  m.Params[4],
+		Nick:    m.Params[5],
+		Flags:   m.Params[6],
+	}
+
+	hopsReal := s.SplitN(m.Params[7], " ", 2)
+	e.Hops, _ = strconv.Atoi(hopsReal[0])
+	if len(hopsReal) == 2 {
+		e.Realname = hopsReal[1]
+	}
+
+	e.finish()
+
+	return e, true
+}
+
+func (q *WhoQuery) decodeWhox(m *Message) (*WhoEntry, bool) {
+	params := m.Params[1:]
+	if len(params) != len(q.Fields) || len(params) == 0 || params[0] != q.Token {
+		return nil, false
+	}
+
+	e := &WhoEntry{}
+
+	for n := 1; n < len(params); n++ {
+		v := params[n]
+
+		switch WhoxField(q.Fields[n]) {
+		case WhoxChannel:
+			e.Channel = v
+		case WhoxUser:
+			e.User = v
+		case WhoxIP:
+			e.IP = v
+		case WhoxHost:
+			e.Host = v
+		case WhoxServer:
+			e.Server = v
+		case WhoxNick:
+			e.Nick = v
+		case WhoxFlags:
+			e.Flags = v
+		case WhoxHops:
+			e.Hops, _ = strconv.Atoi(v)
+		case WhoxIdle:
+			idle, _ := strconv.Atoi(v)
+			e.Idle = time.Duration(idle) * time.Second
+		case WhoxAccount:
+			if v != "0" {
+				e.Account = v
+			}
+		case WhoxOpLevel:
+			e.OpLevel = v
+		case WhoxRealname:
+			e.Realname = v
+		}
+	}
+
+	e.finish()
+
+	return e, true
+}
+
+func (e *WhoEntry) finish() {
+	if e.Channel == "*" {
+		e.Channel = ""
+	}
+
+	if e.IP == "255.255.255.255" {
+		e.IP = ""
+	}
+
+	e.Away = s.HasPrefix(e.Flags, "G")
+	e.Oper = s.ContainsRune(e.Flags, '*')
+}
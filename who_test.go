@@ -0,0 +1,106 @@
+package tightbeam_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+)
+
+func whoISupport(tokens ...string) tightbeam.ISupport {
+	i := tightbeam.ISupport{}
+	i.Update(&tightbeam.Message{Command: tightbeam.RPL_ISUPPORT, Params: append(append([]string{"me"}, tokens...), "are supported")})
+
+	return i
+}
+
+func TestNewWhoQuery(t *testing.T) {
+	for _, tt := range []struct {
+		isupport tightbeam.ISupport
+		token    string
+		fields   []tightbeam.WhoxField
+		want     string
+		err      error
+	}{
+		{whoISupport("WHOX"), "1", nil, "WHO #chan %tcuhsnf,1", nil},
+		{whoISupport("WHOX"), "42", []tightbeam.WhoxField{tightbeam.WhoxRealname, tightbeam.WhoxAccount, tightbeam.WhoxIP}, "WHO #chan %tcuihsnfar,42", nil},
+		{whoISupport("WHOX"), "999", []tightbeam.WhoxField{tightbeam.WhoxIdle, tightbeam.WhoxHops, tightbeam.WhoxOpLevel}, "WHO #chan %tcuhsnfdlo,999", nil},
+		{whoISupport(), "1", []tightbeam.WhoxField{tightbeam.WhoxAccount}, "WHO #chan", nil},
+		{whoISupport("WHOX"), "", nil, "", tightbeam.ErrorInvalidWhoxToken},
+		{whoISupport("WHOX"), "1000", nil, "", tightbeam.ErrorInvalidWhoxToken},
+		{whoISupport("WHOX"), "a1", nil, "", tightbeam.ErrorInvalidWhoxToken},
+	} {
+		q, err := tightbeam.NewWhoQuery(tt.isupport, "#chan", tt.token, tt.fields...)
+		if err != tt.err {
+			t.Errorf("NewWhoQuery(%q, %q) error = %v, want %v", tt.token, tt.fields, err, tt.err)
+			continue
+		}
+
+		if err == nil && q.Message().String() != tt.want {
+			t.Errorf("NewWhoQuery(%q, %q).Message() = %q, want %q", tt.token, tt.fields, q.Message().String(), tt.want)
+		}
+	}
+}
+
+func TestWhoQueryDecode(t *testing.T) {
+	whox, _ := tightbeam.NewWhoQuery(whoISupport("WHOX"), "#chan", "7", tightbeam.WhoxIP, tightbeam.WhoxHops, tightbeam.WhoxIdle, tightbeam.WhoxAccount, tightbeam.WhoxOpLevel, tightbeam.WhoxRealname)
+	plain, _ := tightbeam.NewWhoQuery(whoISupport(), "#chan", "7")
+
+	for _, tt := range []struct {
+		q    *tightbeam.WhoQuery
+		line string
+		want *tightbeam.WhoEntry
+	}{
+		{
+			plain, ":srv 352 me #chan ~al host.example srv.example alice H@ :2 Alice Liddell",
+			&tightbeam.WhoEntry{Channel: "#chan", User: "~al", Host: "host.example", Server: "srv.example", Nick: "alice", Flags: "H@", Hops: 2, Realname: "Alice Liddell"},
+		},
+		{
+			plain, ":srv 352 me * bob h s bob G* :0",
+			&tightbeam.WhoEntry{User: "bob", Host: "h", Server: "s", Nick: "bob", Flags: "G*", Away: true, Oper: true},
+		},
+		{plain, ":srv 352 me #chan u h s n", nil},
+		{plain, ":srv 354 me 7 #chan u h s n H", nil},
+		{
+			whox, ":srv 354 me 7 #chan u 192.0.2.1 h s alice H 3 60 acct n/a :Real Name",
+			&tightbeam.WhoEntry{Channel: "#chan", User: "u", IP: "192.0.2.1", Host: "h", Server: "s", Nick: "alice", Flags: "H", Hops: 3, Idle: time.Minute, Account: "acct", OpLevel: "n/a", Realname: "Real Name"},
+		},
+		{
+			whox, ":srv 354 me 7 * u 255.255.255.255 h s bob G 0 0 0 n/a :",
+			&tightbeam.WhoEntry{User: "u", Host: "h", Server: "s", Nick: "bob", Flags: "G", Away: true, OpLevel: "n/a"},
+		},
+		{whox, ":srv 354 me 8 #chan u 192.0.2.1 h s alice H 3 60 acct n/a :Real Name", nil},
+		{whox, ":srv 354 me 7 #chan u h s alice H", nil},
+		{whox, ":srv 315 me #chan :End of WHO", nil},
+	} {
+		got, ok := tt.q.Decode(tightbeam.MustParseMessage(tt.line))
+
+		if ok != (tt.want != nil) || ok && *got != *tt.want {
+			t.Errorf("Decode(%q) = %+v, %v, want %+v", tt.line, got, ok, tt.want)
+		}
+	}
+}
+
+func TestWhoQueryDone(t *testing.T) {
+	q, _ := tightbeam.NewWhoQuery(whoISupport("CASEMAPPING=rfc1459"), "#Chan[1]", "1")
+
+	for _, tt := range []struct {
+		line string
+		done bool
+	}{
+		{":srv 315 me #Chan[1] :End of WHO", true},
+		{":srv 315 me #chan{1} :End of WHO", true},
+		{":srv 315 me #chan2 :End of WHO", false},
+		{":srv 315 me", false},
+		{":srv 352 me #Chan[1] u h s n H :0 r", false},
+	} {
+		if done := q.Done(tightbeam.MustParseMessage(tt.line)); done != tt.done {
+			t.Errorf("Done(%q) = %v, want %v", tt.line, done, tt.done)
+		}
+	}
+
+	q, _ = tightbeam.NewWhoQuery(whoISupport("CASEMAPPING=ascii"), "#Chan[1]", "1")
+	if q.Done(tightbeam.MustParseMessage(":srv 315 me #chan{1} :End of WHO")) {
+		t.Error("Done() folded {} under ascii casemapping")
+	}
+}
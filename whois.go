@@ -0,0 +1,158 @@
+package tightbeam
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	s "strings"
+	"time"
+)
+
+var (
+	ErrorNoSuchNick = errors.New("irc: No such nick")
+
+	ErrorNoSuchServer = errors.New("irc: No such server")
+)
+
+type WhoisInfo struct {
+	Nick     string
+	User     string
+	Host     string
+	Realname string
+
+	Server     string
+	ServerInfo string
+
+	Idle   time.Duration
+	SignOn time.Time
+
+	Channels        []string
+	ChannelPrefixes map[string]string
+	Account         string
+	Away            string
+
+	ActualHost string
+	ActualIP   string
+
+	Operator   bool
+	Secure     bool
+	Registered bool
+
+	Unknown []*Message
+}
+
+func (c *Client) Whois(ctx context.Context, nick string) (*WhoisInfo, error) {
+	isupport := c.ISupport()
+	casemap := isupport.CaseMapping()
+
+	info := &WhoisInfo{Nick: nick}
+	result := make(chan error, 1)
+
+	col := c.addCollector(func(m *Message) bool {
+		if !isNumeric(m.Command) || len(m.Params) < 2 || !casemap.Equal(m.Params[1], nick) {
+			return false
+		}
+
+		switch m.Command {
+		case ERR_NOSUCHNICK:
+			result <- ErrorNoSuchNick
+			return true
+		case ERR_NOSUCHSERVER:
+			result <- ErrorNoSuchServer
+			return true
+		case RPL_ENDOFWHOIS:
+			result <- nil
+			return true
+		}
+
+		info.add(m, isupport)
+
+		return false
+	})
+	defer c.removeCollector(col)
+
+	if err := c.Send(&Message{Command: "WHOIS", Params: []string{nick}}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return nil, err
+		}
+		return info, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, ErrorClientClosed
+	}
+}
+
+func (w *WhoisInfo) add(m *Message, isupport ISupport) {
+	p := m.Params
+
+	switch m.Command {
+	case RPL_WHOISUSER:
+		if len(p) < 6 {
+			break
+		}
+		w.Nick, w.User, w.Host, w.Realname = p[1], p[2], p[3], p[5]
+	case RPL_WHOISSERVER:
+		if len(p) < 4 {
+			break
+		}
+		w.Server, w.ServerInfo = p[2], p[3]
+	case RPL_WHOISOPERATOR:
+		w.Operator = true
+	case RPL_WHOISIDLE:
+		if len(p) < 4 {
+			break
+		}
+		if idle, err := strconv.ParseInt(p[2], 10, 64); err == nil {
+			w.Idle = time.Duration(idle) * time.Second
+		}
+		if signon, err := strconv.ParseInt(p[3], 10, 64); err == nil && len(p) > 4 {
+			w.SignOn = time.Unix(signon, 0)
+		}
+	case RPL_WHOISCHANNELS:
+		_, symbols := isupport.PrefixModes()
+
+		for _, name := range s.Fields(m.Trailing()) {
+			prefixes := ""
+			for len(name) > 1 && s.IndexByte(symbols, name[0]) >= 0 && (!isupport.IsChannel(name) || isupport.IsChannel(name[1:])) {
+				prefixes, name = prefixes+name[:1], name[1:]
+			}
+
+			w.Channels = append(w.Channels, name)
+
+			if prefixes != "" {
+				if w.ChannelPrefixes == nil {
+					w.ChannelPrefixes = map[string]string{}
+				}
+				w.ChannelPrefixes[name] = prefixes
+			}
+		}
+	case RPL_WHOISACCOUNT:
+		if len(p) < 4 {
+			break
+		}
+		w.Account = p[2]
+	case RPL_WHOISACTUALLY:
+		switch len(p) {
+		case 4:
+			w.ActualIP = p[2]
+		case 5:
+			w.ActualHost, w.ActualIP = p[2], p[3]
+		default:
+			w.Unknown = append(w.Unknown, m)
+		}
+	case RPL_WHOISSECURE:
+		w.Secure = true
+	case RPL_WHOISREGNICK:
+		w.Registered = true
+	case RPL_AWAY:
+		w.Away = m.Trailing()
+	default:
+		w.Unknown = append(w.Unknown, m)
+	}
+}
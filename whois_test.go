@@ -0,0 +1,159 @@
+package tightbeam_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SamStrongTalks/tightbeam"
+	"github.com/SamStrongTalks/tightbeam/tightbeamtest"
+)
+
+func whoisClient(t *testing.T, isupport ...string) (*tightbeamtest.Server, *tightbeam.Client) {
+	t.Helper()
+
+	srv := tightbeamtest.NewServer(t)
+	c := tightbeam.NewClient(srv.Conn(), tightbeam.ClientConfig{Nick: "bot"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go c.Run(ctx)
+
+	srv.Register("bot")
+	if len(isupport) > 0 {
+		srv.Numeric(tightbeam.RPL_ISUPPORT, "bot", append(isupport, "are supported by this server")...)
+	}
+	srv.Send("PING :sync")
+	srv.Expect("PONG sync")
+
+	return srv, c
+}
+
+type whoisResult struct {
+	info *tightbeam.WhoisInfo
+	err  error
+}
+
+func startWhois(ctx context.Context, c *tightbeam.Client, nick string) chan whoisResult {
+	ret := make(chan whoisResult, 1)
+
+	go func() {
+		info, err := c.Whois(ctx, nick)
+		ret <- whoisResult{info, err}
+	}()
+
+	return ret
+}
+
+func TestWhois(t *testing.T) {
+	srv, c := whoisClient(t, "PREFIX=(qaohv)~&@%+", "CHANTYPES=#&")
+
+	result := startWhois(context.Background(), c, "Alice")
+	srv.Expect("WHOIS Alice")
+
+	srv.Send(":alice!a@h PRIVMSG bot :not part of the reply")
+	srv.Numeric(tightbeam.RPL_WHOISUSER, "bot", "alice", "~al", "host.example", "*", "Alice Liddell")
+	srv.Numeric(tightbeam.RPL_WHOISSERVER, "bot", "alice", "irc.example.com", "Example server")
+	srv.Numeric(tightbeam.RPL_WHOISOPERATOR, "bot", "alice", "is an IRC operator")
+	srv.Numeric(tightbeam.RPL_WHOISIDLE, "bot", "ALICE", "90", "1600000000", "seconds idle, signon time")
+	srv.Numeric(tightbeam.RPL_WHOISCHANNELS, "bot", "alice", "~&#admin @#ops &local +#v #plain &#&both")
+	srv.Numeric(tightbeam.RPL_WHOISCHANNELS, "bot", "alice", "%#more")
+	srv.Numeric(tightbeam.RPL_WHOISACCOUNT, "bot", "alice", "alice_acct", "is logged in as")
+	srv.Numeric(tightbeam.RPL_WHOISACTUALLY, "bot", "alice", "real.example", "192.0.2.1", "is actually using host")
+	srv.Numeric(tightbeam.RPL_WHOISSECURE, "bot", "alice", "is using a secure connection")
+	srv.Numeric(tightbeam.RPL_WHOISREGNICK, "bot", "alice", "has identified for this nick")
+	srv.Numeric(tightbeam.RPL_AWAY, "bot", "alice", "gone fishing")
+	srv.Numeric("276", "bot", "alice", "has client certificate fingerprint abc")
+	srv.Numeric(tightbeam.RPL_WHOISUSER, "bot", "bob", "b", "other", "*", "Not Alice")
+	srv.Numeric(tightbeam.RPL_ENDOFWHOIS, "bot", "alice", "End of WHOIS")
+
+	r := <-result
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+
+	info := r.info
+	if info.Nick != "alice" || info.User != "~al" || info.Host != "host.example" || info.Realname != "Alice Liddell" {
+		t.Errorf("user = %q %q %q %q", info.Nick, info.User, info.Host, info.Realname)
+	}
+
+	if info.Server != "irc.example.com" || info.ServerInfo != "Example server" {
+		t.Errorf("server = %q %q", info.Server, info.ServerInfo)
+	}
+
+	if info.Idle != 90*time.Second || !info.SignOn.Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("idle = %v, signon = %v", info.Idle, info.SignOn)
+	}
+
+	if want := []string{"#admin", "#ops", "&local", "#v", "#plain", "#&both", "#more"}; !reflect.DeepEqual(info.Channels, want) {
+		t.Errorf("Channels = %q, want %q", info.Channels, want)
+	}
+
+	if want := map[string]string{"#admin": "~&", "#ops": "@", "#v": "+", "#&both": "&", "#more": "%"}; !reflect.DeepEqual(info.ChannelPrefixes, want) {
+		t.Errorf("ChannelPrefixes = %q, want %q", info.ChannelPrefixes, want)
+	}
+
+	if info.Account != "alice_acct" || info.ActualHost != "real.example" || info.ActualIP != "192.0.2.1" || info.Away != "gone fishing" {
+		t.Errorf("account %q, actual %q %q, away %q", info.Account, info.ActualHost, info.ActualIP, info.Away)
+	}
+
+	if !info.Operator || !info.Secure || !info.Registered {
+		t.Errorf("operator %v, secure %v, registered %v", info.Operator, info.Secure, info.Registered)
+	}
+
+	if len(info.Unknown) != 1 || info.Unknown[0].Command != "276" {
+		t.Errorf("Unknown = %v", info.Unknown)
+	}
+}
+
+func TestWhoisDefaultPrefixes(t *testing.T) {
+	srv, c := whoisClient(t)
+
+	result := startWhois(context.Background(), c, "alice")
+	srv.Expect("WHOIS alice")
+	srv.Numeric(tightbeam.RPL_WHOISCHANNELS, "bot", "alice", "@#a +&b #c")
+	srv.Numeric(tightbeam.RPL_ENDOFWHOIS, "bot", "alice", "End of WHOIS")
+
+	r := <-result
+	if r.err != nil || !reflect.DeepEqual(r.info.Channels, []string{"#a", "&b", "#c"}) || len(r.info.ChannelPrefixes) != 2 {
+		t.Fatalf("Whois() = %+v, %v", r.info, r.err)
+	}
+}
+
+func TestWhoisErrors(t *testing.T) {
+	srv, c := whoisClient(t)
+
+	result := startWhois(context.Background(), c, "nobody")
+	srv.Expect("WHOIS nobody")
+	srv.Numeric(tightbeam.ERR_NOSUCHNICK, "bot", "nobody", "No such nick/channel")
+
+	if r := <-result; r.err != tightbeam.ErrorNoSuchNick {
+		t.Fatalf("Whois() error = %v, want ErrorNoSuchNick", r.err)
+	}
+
+	result = startWhois(context.Background(), c, "far.example")
+	srv.Expect("WHOIS far.example")
+	srv.Numeric(tightbeam.ERR_NOSUCHSERVER, "bot", "far.example", "No such server")
+
+	if r := <-result; r.err != tightbeam.ErrorNoSuchServer {
+		t.Fatalf("Whois() error = %v, want ErrorNoSuchServer", r.err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result = startWhois(ctx, c, "slow")
+	srv.Expect("WHOIS slow")
+	cancel()
+
+	if r := <-result; r.err != context.Canceled {
+		t.Fatalf("Whois() error = %v, want context.Canceled", r.err)
+	}
+
+	result = startWhois(context.Background(), c, "gone")
+	srv.Expect("WHOIS gone")
+	c.Close()
+
+	if r := <-result; r.err != tightbeam.ErrorClientClosed {
+		t.Fatalf("Whois() error = %v, want ErrorClientClosed", r.err)
+	}
+}